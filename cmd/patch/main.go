@@ -0,0 +1,209 @@
+// Command patch is a CLI tool to patch an installed game build and inspect patch reports
+// Usage: go run cmd/patch/main.go [command] [flags]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"HyLauncher/internal/patch"
+	"HyLauncher/pkg/model"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	command := os.Args[1]
+
+	switch command {
+	case "apply":
+		patchCmd(os.Args[2:], false)
+	case "dry-run":
+		patchCmd(os.Args[2:], true)
+	case "report":
+		reportCmd(os.Args[2:])
+	case "restore":
+		restoreCmd(os.Args[2:])
+	case "help", "-h", "--help":
+		printUsage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
+		printUsage()
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Println(`Hytale Game Patch Tool
+
+Usage: patch <command> [flags]
+
+Commands:
+  apply     Patch client and server of an installed build
+  dry-run   Show what apply would change without writing anything
+  report    Print the last saved patch report
+  restore   Restore the original client and server from backup
+  help      Show this help message
+
+Examples:
+  # Preview the changes for the active auto-updated build
+  patch dry-run --branch=release --version=auto
+
+  # Patch a pinned build and keep the report with an instance
+  patch apply --version=8 --instance=default --domain=auth.example.net
+
+  # Show the last report as JSON
+  patch report --instance=default --json`)
+}
+
+type targetFlags struct {
+	branch   *string
+	version  *string
+	instance *string
+}
+
+func addTargetFlags(fs *flag.FlagSet) targetFlags {
+	return targetFlags{
+		branch:   fs.String("branch", "release", "Game branch (release, pre-release)"),
+		version:  fs.String("version", "auto", "Installed build: auto, latest or a build number"),
+		instance: fs.String("instance", "", "Instance whose logs folder receives the report (default: launcher logs)"),
+	}
+}
+
+func (t targetFlags) request() model.InstanceModel {
+	return model.InstanceModel{
+		InstanceID:   *t.instance,
+		Branch:       *t.branch,
+		BuildVersion: *t.version,
+	}
+}
+
+func patchCmd(args []string, dryRun bool) {
+	fs := flag.NewFlagSet("patch", flag.ExitOnError)
+	target := addTargetFlags(fs)
+	domain := fs.String("domain", patch.DefaultTargetDomain, "Domain to point the game at")
+	jsonOutput := fs.Bool("json", false, "Output the report as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	report, err := patch.PatchGame(context.Background(), target.request(), *domain, patch.PatchOptions{DryRun: dryRun}, nil)
+	if report != nil {
+		if *jsonOutput {
+			output, _ := json.MarshalIndent(report, "", "  ")
+			fmt.Println(string(output))
+		} else {
+			printReport(report)
+			fmt.Printf("Report saved to: %s\n", patch.PatchReportPath(*target.instance, dryRun))
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Patching failed: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func reportCmd(args []string) {
+	fs := flag.NewFlagSet("report", flag.ExitOnError)
+	instance := fs.String("instance", "", "Instance to read the report of (default: launcher logs)")
+	dryRun := fs.Bool("dry-run", false, "Show the last dry-run report instead")
+	jsonOutput := fs.Bool("json", false, "Output the report as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	report, err := patch.LoadPatchReport(*instance, *dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read report: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		output, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(output))
+		return
+	}
+	printReport(report)
+}
+
+func restoreCmd(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	target := addTargetFlags(fs)
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := patch.RestoreOriginalGame(target.request()); err != nil {
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println("Original files restored")
+}
+
+func printReport(report *patch.PatchReport) {
+	mode := "APPLIED"
+	if report.DryRun {
+		mode = "DRY RUN"
+	}
+
+	fmt.Println()
+	fmt.Println(strings.Repeat("=", 61))
+	fmt.Printf("PATCH REPORT (%s)\n", mode)
+	fmt.Println(strings.Repeat("=", 61))
+	fmt.Printf("Branch: %s\n", report.Branch)
+	fmt.Printf("Version: %s (build %d)\n", report.Version, report.Build)
+	fmt.Printf("Domain: %s\n", report.Domain)
+	fmt.Printf("Rules: v%d from %s\n", report.RulesVersion, report.RulesSource)
+	fmt.Printf("Timestamp: %s\n", report.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Println()
+
+	for _, f := range report.Files {
+		state := "unchanged"
+		switch {
+		case f.Error != "":
+			state = "error: " + f.Error
+		case f.UpToDate:
+			state = "up to date"
+		case f.Written && f.FromBackup:
+			state = "re-patched from backup"
+		case f.Written:
+			state = "written"
+		case len(f.Changes) > 0:
+			state = "would change"
+		}
+		fmt.Printf("%s [%s] %s\n", f.Target, state, f.Path)
+
+		for _, c := range f.Changes {
+			where := fmt.Sprintf("0x%x", c.Offset)
+			if c.Entry != "" {
+				where = c.Entry + "@" + where
+			}
+			fmt.Printf("  %-24s %-16s %s x%d\n", c.Rule, c.Encoding, where, c.Matches)
+			fmt.Printf("    - %s\n", c.Before)
+			fmt.Printf("    + %s\n", c.After)
+		}
+	}
+	fmt.Println()
+
+	fmt.Println("RULES:")
+	for _, r := range report.Rules {
+		fmt.Printf("  %-24s %-7s %d\n", r.ID, r.Target, r.Matches)
+	}
+	if len(report.Skipped) > 0 {
+		fmt.Printf("  Skipped for this build: %s\n", strings.Join(report.Skipped, ", "))
+	}
+	fmt.Println(strings.Repeat("=", 61))
+}
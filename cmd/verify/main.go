@@ -3,15 +3,19 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 
+	"HyLauncher/internal/patch"
 	"HyLauncher/internal/verify"
+	"HyLauncher/pkg/model"
 )
 
 func main() {
@@ -27,6 +31,12 @@ func main() {
 		verifyCmd(os.Args[2:])
 	case "generate":
 		generateCmd(os.Args[2:])
+	case "repair":
+		repairCmd(os.Args[2:])
+	case "history":
+		historyCmd(os.Args[2:])
+	case "diff":
+		diffCmd(os.Args[2:])
 	case "restore":
 		restoreCmd(os.Args[2:])
 	case "backup":
@@ -48,6 +58,9 @@ Usage: verify <command> [flags]
 Commands:
   verify    Verify game files against a manifest
   generate  Generate a manifest from an existing installation
+  repair    Verify and restore every damaged file
+  history   List or export past verification reports
+  diff      Show which files changed between two reports
   restore   Restore a file from backup
   backup    Create a backup of specified files
   help      Show this help message
@@ -59,9 +72,39 @@ Examples:
   # Generate manifest from clean installation
   verify generate --game-dir="C:\HytaleClean" --version=2026.02.001 --output=manifest_2026.02.001.json
 
+  # Verify against the official signed manifest of release build 8
+  verify verify --branch=release --build=8
+
+  # Verify against a manifest generated on this machine
+  verify verify --version=2026.02.001 --allow-local
+
+  # Generate a manifest of the client files only
+  verify generate --game-dir="..." --version=2026.02.001 --include="Client/**"
+
+  # Re-hash everything, e.g. after suspected disk corruption
+  verify verify --version=2026.02.001 --deep
+
+  # Repair damaged files of release build 8 without reinstalling
+  verify repair --game-dir="..." --version=2026.02.001 --branch=release --build=8
+
+  # Export the latest report for a support ticket
+  verify verify --version=2026.02.001 --format=html > report.html
+
+  # Show what changed since the last run that passed
+  verify diff --game-dir="..."
+
   # Restore a modified file
   verify restore --file="Client/HytaleClient.jar" --game-dir="..."
 
+  # Save the whole install as a restore point, and go back to it later
+  verify backup --game-dir="..." --name=before-mods
+  verify restore --game-dir="..." --point=before-mods --clean
+
+  # List restore points, or keep only the last 5 automatic ones within 20 GB
+  # (add --manual to prune the restore points you made too)
+  verify backup --list
+  verify backup --prune --keep-last=5 --max-size=20GB
+
 Flags for 'verify':`)
 	flag.PrintDefaults()
 }
@@ -73,21 +116,37 @@ func verifyCmd(args []string) {
 	manifestPath := fs.String("manifest", "", "Path to manifest file (optional, auto-detected if not specified)")
 	skipVerify := fs.Bool("skip-verify", false, "Skip verification (or set HYTALE_SKIP_VERIFY=1)")
 	noBackup := fs.Bool("no-backup", false, "Disable automatic backup of modified files")
-	backupDir := fs.String("backup-dir", "", "Directory for backups (default: gameDir/.backups)")
+	backupDir := fs.String("backup-dir", "", "Backup store (default: launcher_dir/backups)")
 	ignore := fs.String("ignore", "", "Comma-separated list of file patterns to ignore")
-	jsonOutput := fs.Bool("json", false, "Output results as JSON")
+	jsonOutput := fs.Bool("json", false, "Output results as JSON (same as --format=json)")
+	format := fs.String("format", "text", "Output format: text, json, junit, sarif or html")
+	noHistory := fs.Bool("no-history", false, "Don't store the report in the verification history")
 	verbose := fs.Bool("v", false, "Verbose output with progress")
+	deep := fs.Bool("deep", false, "Hash every file, ignoring the verification cache (or set HYTALE_DEEP_VERIFY=1)")
+	noCache := fs.Bool("no-cache", false, "Neither read nor update the verification cache")
+	workers := fs.Int("workers", 0, "Number of files hashed at once (default: CPU count)")
+	branch := fs.String("branch", "release", "Game branch of the official manifest")
+	build := fs.Int("build", 0, "Build number, verifies against the official manifest of the build")
+	allowLocal := fs.Bool("allow-local", false, "Trust unsigned or locally generated manifests")
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
 		os.Exit(1)
 	}
 
+	outFormat := outputFormat(*format, *jsonOutput)
+
+	if *version == "" && *build > 0 {
+		*version = strconv.Itoa(*build)
+	}
 	if *version == "" {
 		fmt.Fprintf(os.Stderr, "Error: --version is required\n")
 		fs.Usage()
 		os.Exit(1)
 	}
+	if *manifestPath == "" && *build > 0 {
+		*manifestPath = fetchBuildManifest(*branch, *build)
+	}
 
 	// Expand environment variables in paths
 	*gameDir = os.ExpandEnv(*gameDir)
@@ -98,18 +157,11 @@ func verifyCmd(args []string) {
 		*backupDir = os.ExpandEnv(*backupDir)
 	}
 
-	// Parse ignore list
-	var ignoreList []string
-	if *ignore != "" {
-		ignoreList = strings.Split(*ignore, ",")
-		for i := range ignoreList {
-			ignoreList[i] = strings.TrimSpace(ignoreList[i])
-		}
-	}
+	ignoreList := splitList(*ignore)
 
 	// Setup progress callback
 	var progressCallback func(current, total int64, fileName string)
-	if *verbose {
+	if *verbose && outFormat == "" {
 		progressCallback = func(current, total int64, fileName string) {
 			percent := float64(current) / float64(total) * 100
 			fmt.Printf("\r  Hashing %s: %.1f%% (%s / %s)",
@@ -124,18 +176,23 @@ func verifyCmd(args []string) {
 	}
 
 	options := verify.Options{
-		GameDir:          *gameDir,
-		Version:          *version,
-		ManifestPath:     *manifestPath,
-		SkipVerify:       *skipVerify,
-		CreateBackups:    !*noBackup,
-		BackupDir:        *backupDir,
-		IgnoreList:       ignoreList,
-		ProgressCallback: progressCallback,
-		ProgressInterval: 100 * 1024 * 1024, // 100 MB
-	}
-
-	if !*jsonOutput {
+		GameDir:            *gameDir,
+		Version:            *version,
+		ManifestPath:       *manifestPath,
+		SkipVerify:         *skipVerify,
+		CreateBackups:      !*noBackup,
+		BackupDir:          *backupDir,
+		IgnoreList:         ignoreList,
+		ProgressCallback:   progressCallback,
+		ProgressInterval:   100 * 1024 * 1024, // 100 MB
+		Workers:            *workers,
+		DeepVerify:         *deep,
+		NoCache:            *noCache,
+		AllowLocalManifest: *allowLocal,
+		NoHistory:          *noHistory,
+	}
+
+	if outFormat == "" {
 		fmt.Printf("Verifying game files...\n")
 		fmt.Printf("Game directory: %s\n", *gameDir)
 		fmt.Printf("Version: %s\n", *version)
@@ -146,7 +203,7 @@ func verifyCmd(args []string) {
 
 	report, err := verify.VerifyWithOptions(options)
 	if err != nil {
-		if *jsonOutput {
+		if outFormat == verify.FormatJSON {
 			errorJSON, _ := json.Marshal(map[string]string{"error": err.Error()})
 			fmt.Println(string(errorJSON))
 		} else {
@@ -155,9 +212,11 @@ func verifyCmd(args []string) {
 		os.Exit(1)
 	}
 
-	if *jsonOutput {
-		output, _ := json.MarshalIndent(report, "", "  ")
-		fmt.Println(string(output))
+	if outFormat != "" {
+		if err := verify.WriteReport(os.Stdout, report, outFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", err)
+			os.Exit(1)
+		}
 	} else {
 		printReport(report)
 	}
@@ -177,6 +236,10 @@ func generateCmd(args []string) {
 	version := fs.String("version", "", "Game version (required)")
 	output := fs.String("output", "", "Output manifest file path (default: launcher_dir/manifests/manifest_<version>.json)")
 	ignore := fs.String("ignore", "", "Comma-separated list of file patterns to ignore")
+	include := fs.String("include", "", "Comma-separated globs of files to hash (default: all files, ** matches directories)")
+	exclude := fs.String("exclude", "", "Comma-separated globs of files to leave out, added to the launcher's own files")
+	workers := fs.Int("workers", 0, "Number of files hashed at once (default: CPU count)")
+	official := fs.Bool("official", false, "Leave out the generated marker, for a manifest that will be signed and published")
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
@@ -194,24 +257,24 @@ func generateCmd(args []string) {
 		*output = os.ExpandEnv(*output)
 	}
 
-	// Parse ignore list
-	var ignoreList []string
-	if *ignore != "" {
-		ignoreList = strings.Split(*ignore, ",")
-		for i := range ignoreList {
-			ignoreList[i] = strings.TrimSpace(ignoreList[i])
-		}
-	}
-
 	fmt.Printf("Generating manifest from: %s\n", *gameDir)
 	fmt.Printf("Version: %s\n", *version)
 	fmt.Println("Scanning files...")
 
-	manifest, err := verify.GenerateManifest(*gameDir, *version, ignoreList)
+	manifest, err := verify.GenerateManifestWithOptions(*gameDir, verify.ManifestOptions{
+		Version: *version,
+		Include: splitList(*include),
+		Exclude: append(append([]string{}, verify.DefaultExcludes...), splitList(*exclude)...),
+		Ignore:  splitList(*ignore),
+		Workers: *workers,
+	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to generate manifest: %v\n", err)
 		os.Exit(1)
 	}
+	if *official {
+		manifest.Generated = false
+	}
 
 	// Determine output path
 	outputPath := *output
@@ -230,23 +293,299 @@ func generateCmd(args []string) {
 		os.Exit(1)
 	}
 
+	roles := make(map[verify.FileRole]int)
+	for _, info := range manifest.Files {
+		roles[info.Role]++
+	}
+
 	fmt.Printf("Manifest saved to: %s\n", outputPath)
 	fmt.Printf("Files included: %d\n", len(manifest.Files))
+	for _, role := range []verify.FileRole{verify.RoleExecutable, verify.RoleLibrary, verify.RoleConfig, verify.RoleAsset} {
+		fmt.Printf("  %s: %d\n", role, roles[role])
+	}
+	fmt.Println("Note: the manifest is only trusted once signed, or with --allow-local")
+}
+
+// outputFormat checks the --format flag, "" means the readable text report
+func outputFormat(name string, jsonOutput bool) verify.ReportFormat {
+	if jsonOutput {
+		return verify.FormatJSON
+	}
+	if name == "" || name == "text" {
+		return ""
+	}
+	format, err := verify.ParseReportFormat(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return format
+}
+
+// fetchBuildManifest downloads the official manifest of a build, exiting on failure
+func fetchBuildManifest(branch string, build int) string {
+	path, err := patch.FetchBuildManifest(context.Background(), branch, build)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to fetch manifest for build %d: %v\n", build, err)
+		os.Exit(1)
+	}
+	return path
+}
+
+// splitList parses a comma-separated flag value
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	list := strings.Split(value, ",")
+	for i := range list {
+		list[i] = strings.TrimSpace(list[i])
+	}
+	return list
+}
+
+func repairCmd(args []string) {
+	fs := flag.NewFlagSet("repair", flag.ExitOnError)
+	gameDir := fs.String("game-dir", getDefaultGameDir(), "Path to game installation directory")
+	version := fs.String("version", "", "Game version to verify against (required)")
+	manifestPath := fs.String("manifest", "", "Path to manifest file (optional, auto-detected if not specified)")
+	backupDir := fs.String("backup-dir", "", "Backup store (default: launcher_dir/backups)")
+	branch := fs.String("branch", "release", "Game branch, for downloading files")
+	build := fs.Int("build", 0, "Build number, for the official manifest and downloading files (default: backups only)")
+	allowLocal := fs.Bool("allow-local", false, "Trust unsigned or locally generated manifests")
+	jsonOutput := fs.Bool("json", false, "Output results as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *version == "" && *build > 0 {
+		*version = strconv.Itoa(*build)
+	}
+	if *version == "" {
+		fmt.Fprintf(os.Stderr, "Error: --version is required\n")
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *manifestPath == "" && *build > 0 {
+		*manifestPath = fetchBuildManifest(*branch, *build)
+	}
+
+	options := verify.Options{
+		GameDir:            os.ExpandEnv(*gameDir),
+		Version:            *version,
+		ManifestPath:       os.ExpandEnv(*manifestPath),
+		CreateBackups:      true,
+		BackupDir:          os.ExpandEnv(*backupDir),
+		DeepVerify:         true,
+		AllowLocalManifest: *allowLocal,
+	}
+
+	report, err := verify.VerifyWithOptions(options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Verification failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	var sources []verify.RepairSource
+	if *build > 0 {
+		sources = patch.RepairSources(model.InstanceModel{Branch: *branch, BuildVersion: strconv.Itoa(*build)})
+	}
+
+	repair, err := verify.Repair(context.Background(), options, report, sources)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Repair failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		output, _ := json.MarshalIndent(repair, "", "  ")
+		fmt.Println(string(output))
+	} else {
+		for _, f := range repair.Files {
+			if f.Repaired {
+				fmt.Printf("  [REPAIRED] %s (from %s)\n", f.Path, f.Source)
+			} else {
+				fmt.Printf("  [FAILED] %s\n", f.Path)
+				fmt.Printf("         %s\n", f.Error)
+			}
+		}
+		fmt.Printf("Repaired: %d, failed: %d\n", repair.Repaired, repair.Failed)
+		printReport(repair.Verification)
+	}
+
+	if repair.Verification.OverallStatus == verify.StatusFailed {
+		os.Exit(2)
+	}
+}
+
+func historyCmd(args []string) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	gameDir := fs.String("game-dir", getDefaultGameDir(), "Path to game installation directory")
+	historyDir := fs.String("history-dir", "", "Directory of stored reports (default: gameDir/.verify-history)")
+	show := fs.Int("show", 0, "Print the report with this number from the list (1 = newest)")
+	format := fs.String("format", "text", "Output format of --show: text, json, junit, sarif or html")
+	jsonOutput := fs.Bool("json", false, "Output the list as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	dir := resolveHistoryDir(*gameDir, *historyDir)
+	if *show > 0 {
+		outFormat := outputFormat(*format, false)
+		report := loadHistoryReport(dir, strconv.Itoa(*show))
+		if outFormat == "" {
+			printReport(report)
+			return
+		}
+		if err := verify.WriteReport(os.Stdout, report, outFormat); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to write report: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	entries, err := verify.ListHistory(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read history: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		output, _ := json.MarshalIndent(entries, "", "  ")
+		fmt.Println(string(output))
+		return
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("No reports in %s\n", dir)
+		return
+	}
+	fmt.Printf("Reports in %s:\n", dir)
+	for i, e := range entries {
+		fmt.Printf("  %3d  %s  %-8s  version %s  failed %d, warnings %d (missing %d, modified %d, extra %d)\n",
+			i+1,
+			e.Timestamp.Format("2006-01-02 15:04:05"),
+			e.OverallStatus,
+			e.Version,
+			e.Summary.Failed,
+			e.Summary.Warnings,
+			e.Summary.MissingFiles,
+			e.Summary.ModifiedFiles,
+			e.Summary.ExtraFiles)
+	}
+}
+
+func diffCmd(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	gameDir := fs.String("game-dir", getDefaultGameDir(), "Path to game installation directory")
+	historyDir := fs.String("history-dir", "", "Directory of stored reports (default: gameDir/.verify-history)")
+	from := fs.String("from", "", "Older report, a number from 'history' or a report file (default: last report that passed)")
+	to := fs.String("to", "1", "Newer report, a number from 'history' or a report file")
+	jsonOutput := fs.Bool("json", false, "Output the changes as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
+		os.Exit(1)
+	}
+
+	dir := resolveHistoryDir(*gameDir, *historyDir)
+	newer := loadHistoryReport(dir, *to)
+
+	var older *verify.Report
+	if *from != "" {
+		older = loadHistoryReport(dir, *from)
+	} else {
+		var err error
+		older, err = verify.LastGoodReport(dir, newer.Timestamp)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v, pass --from\n", err)
+			os.Exit(1)
+		}
+	}
+
+	diff := verify.DiffReports(older, newer)
+
+	if *jsonOutput {
+		output, _ := json.MarshalIndent(diff, "", "  ")
+		fmt.Println(string(output))
+		return
+	}
+
+	fmt.Printf("From: %s  %s  version %s\n", diff.From.Format("2006-01-02 15:04:05"), diff.FromStatus, diff.FromVersion)
+	fmt.Printf("To:   %s  %s  version %s\n", diff.To.Format("2006-01-02 15:04:05"), diff.ToStatus, diff.ToVersion)
+	fmt.Println()
+
+	if len(diff.Changes) == 0 {
+		fmt.Println("No files changed")
+		return
+	}
+	for _, c := range diff.Changes {
+		fmt.Printf("  [%s] %s\n", strings.ToUpper(string(c.Change)), c.Path)
+		if c.After != nil && c.After.Status != verify.StatusOK {
+			fmt.Printf("         %s\n", c.After.Message)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("Added: %d, removed: %d, modified: %d, status changed: %d\n",
+		diff.Count(verify.ChangeAdded),
+		diff.Count(verify.ChangeRemoved),
+		diff.Count(verify.ChangeModified),
+		diff.Count(verify.ChangeStatus))
+}
+
+// resolveHistoryDir returns the history directory of a game dir
+func resolveHistoryDir(gameDir, historyDir string) string {
+	if historyDir != "" {
+		return os.ExpandEnv(historyDir)
+	}
+	return verify.GetDefaultHistoryDir(os.ExpandEnv(gameDir))
+}
+
+// loadHistoryReport loads a report by its number in the history list, or
+// from a file, exiting on failure
+func loadHistoryReport(dir, ref string) *verify.Report {
+	path := os.ExpandEnv(ref)
+	if n, err := strconv.Atoi(ref); err == nil {
+		entries, err := verify.ListHistory(dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read history: %v\n", err)
+			os.Exit(1)
+		}
+		if n < 1 || n > len(entries) {
+			fmt.Fprintf(os.Stderr, "Error: no report %d, the history has %d\n", n, len(entries))
+			os.Exit(1)
+		}
+		path = entries[n-1].Path
+	}
+
+	report, err := verify.LoadReport(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load report: %v\n", err)
+		os.Exit(1)
+	}
+	return report
 }
 
 func restoreCmd(args []string) {
 	fs := flag.NewFlagSet("restore", flag.ExitOnError)
-	file := fs.String("file", "", "Relative path to file to restore (required)")
+	file := fs.String("file", "", "Relative path or glob of the files to restore (required without --point)")
 	gameDir := fs.String("game-dir", getDefaultGameDir(), "Path to game installation directory")
-	backupDir := fs.String("backup-dir", "", "Backup directory (default: gameDir/.backups)")
+	backupDir := fs.String("backup-dir", "", "Backup store (default: launcher_dir/backups)")
+	point := fs.String("point", "", "Restore point ID or name to restore the install from")
+	clean := fs.Bool("clean", false, "With --point, delete files the restore point does not hold")
+	jsonOutput := fs.Bool("json", false, "Output results as JSON")
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
 		os.Exit(1)
 	}
 
-	if *file == "" {
-		fmt.Fprintf(os.Stderr, "Error: --file is required\n")
+	if *file == "" && *point == "" {
+		fmt.Fprintf(os.Stderr, "Error: --file or --point is required\n")
 		fs.Usage()
 		os.Exit(1)
 	}
@@ -256,13 +595,14 @@ func restoreCmd(args []string) {
 		*backupDir = os.ExpandEnv(*backupDir)
 	}
 
-	fullPath := filepath.Join(*gameDir, *file)
-	if *backupDir == "" {
-		*backupDir = filepath.Join(*gameDir, ".backups")
+	if *point != "" {
+		restorePoint(verify.OpenBackupStore(*backupDir), *point, *gameDir, *file, *clean, *jsonOutput)
+		return
 	}
 
+	fullPath := filepath.Join(*gameDir, *file)
+
 	fmt.Printf("Restoring: %s\n", *file)
-	fmt.Printf("From backup directory: %s\n", *backupDir)
 
 	backupPath, err := verify.RestoreFile(fullPath, *backupDir)
 	if err != nil {
@@ -277,50 +617,239 @@ func restoreCmd(args []string) {
 	}
 }
 
+// restorePoint puts an install back to a restore point
+func restorePoint(store *verify.BackupStore, ref, gameDir, file string, clean, jsonOutput bool) {
+	point, err := store.FindRestorePoint(ref)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	options := verify.RestoreOptions{GameDir: gameDir, RemoveExtra: clean}
+	if file != "" {
+		options.Paths = splitList(file)
+	}
+
+	if !jsonOutput {
+		fmt.Printf("Restoring %s from restore point %s (%s)\n", gameDir, point.ID, point.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+
+	result, err := store.Restore(point, options)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Restore failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		output, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(output))
+	} else {
+		for _, path := range result.Restored {
+			fmt.Printf("  [RESTORED] %s\n", path)
+		}
+		for _, path := range result.Removed {
+			fmt.Printf("  [REMOVED] %s\n", path)
+		}
+		for path, msg := range result.Failed {
+			fmt.Printf("  [FAILED] %s\n", path)
+			fmt.Printf("         %s\n", msg)
+		}
+		fmt.Printf("Restored: %d, unchanged: %d, removed: %d, failed: %d\n",
+			len(result.Restored), result.Unchanged, len(result.Removed), len(result.Failed))
+	}
+
+	if len(result.Failed) > 0 {
+		os.Exit(2)
+	}
+}
+
 func backupCmd(args []string) {
 	fs := flag.NewFlagSet("backup", flag.ExitOnError)
-	file := fs.String("file", "", "Relative path to file to backup (required)")
+	file := fs.String("file", "", "Relative path to a single file to backup (default: restore point of the whole install)")
 	gameDir := fs.String("game-dir", getDefaultGameDir(), "Path to game installation directory")
-	backupDir := fs.String("backup-dir", "", "Backup directory (default: gameDir/.backups)")
+	backupDir := fs.String("backup-dir", "", "Backup store (default: launcher_dir/backups)")
+	name := fs.String("name", "", "Name of the new restore point")
+	version := fs.String("version", "", "Game version recorded in the restore point")
+	exclude := fs.String("exclude", "", "Comma-separated globs of files to leave out of the restore point")
+	list := fs.Bool("list", false, "List the restore points instead of creating one")
+	deletePoint := fs.String("delete", "", "Delete the restore point with this ID or name")
+	prune := fs.Bool("prune", false, "Only apply the retention policy")
+	keepLast := fs.Int("keep-last", 0, "Keep only this many restore points of each kind")
+	maxSize := fs.String("max-size", "", "Cap the store size, e.g. 20GB")
+	maxAge := fs.Duration("max-age", 0, "Drop restore points older than this, e.g. 720h")
+	manual := fs.Bool("manual", false, "Apply the retention policy to manual restore points too")
+	jsonOutput := fs.Bool("json", false, "Output results as JSON")
 
 	if err := fs.Parse(args); err != nil {
 		fmt.Fprintf(os.Stderr, "Error parsing flags: %v\n", err)
 		os.Exit(1)
 	}
 
-	if *file == "" {
-		fmt.Fprintf(os.Stderr, "Error: --file is required\n")
-		fs.Usage()
-		os.Exit(1)
-	}
-
 	*gameDir = os.ExpandEnv(*gameDir)
 	if *backupDir != "" {
 		*backupDir = os.ExpandEnv(*backupDir)
 	}
+	store := verify.OpenBackupStore(*backupDir)
 
-	fullPath := filepath.Join(*gameDir, *file)
-	if *backupDir == "" {
-		*backupDir = filepath.Join(*gameDir, ".backups")
+	policy := verify.RetentionPolicy{KeepLast: *keepLast, MaxAge: *maxAge, IncludeManual: *manual}
+	if *maxSize != "" {
+		size, err := parseSize(*maxSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --max-size: %v\n", err)
+			os.Exit(1)
+		}
+		policy.MaxSize = size
 	}
 
-	backupManager := verify.NewBackupManager(*backupDir, true)
+	switch {
+	case *list:
+		listRestorePoints(store, *jsonOutput)
+		return
+	case *deletePoint != "":
+		point, err := store.FindRestorePoint(*deletePoint)
+		if err == nil {
+			err = store.DeleteRestorePoint(point.ID)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Delete failed: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Deleted restore point %s\n", point.ID)
+		pruneStore(store, verify.RetentionPolicy{}, *jsonOutput)
+		return
+	case *prune:
+		if policy.IsZero() {
+			policy = verify.DefaultRetention
+			policy.IncludeManual = *manual
+		}
+		pruneStore(store, policy, *jsonOutput)
+		return
+	case *file != "":
+		fullPath := filepath.Join(*gameDir, *file)
+		backupManager := verify.NewBackupManager(*backupDir, *gameDir, true)
+
+		fmt.Printf("Creating backup of: %s\n", *file)
+
+		backupPath, err := backupManager.CreateBackup(fullPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Backup failed: %v\n", err)
+			os.Exit(1)
+		}
 
-	fmt.Printf("Creating backup of: %s\n", *file)
+		if backupPath == "" {
+			fmt.Println("File does not exist, nothing to backup")
+		} else {
+			fmt.Printf("Backup created: %s\n", backupPath)
+		}
+		return
+	}
 
-	backupPath, err := backupManager.CreateBackup(fullPath)
+	if !*jsonOutput {
+		fmt.Printf("Creating restore point of: %s\n", *gameDir)
+	}
+	point, err := store.CreateRestorePoint(*gameDir, verify.RestorePointOptions{
+		Name:    *name,
+		Version: *version,
+		Exclude: splitList(*exclude),
+	})
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Backup failed: %v\n", err)
 		os.Exit(1)
 	}
 
-	if backupPath == "" {
-		fmt.Println("File does not exist, nothing to backup")
+	if *jsonOutput {
+		output, _ := json.MarshalIndent(point, "", "  ")
+		fmt.Println(string(output))
 	} else {
-		fmt.Printf("Backup created: %s\n", backupPath)
+		fmt.Printf("Restore point created: %s (%d files, %s)\n", point.ID, len(point.Files), formatBytes(point.Size()))
+	}
+
+	if policy.IsZero() {
+		policy = verify.DefaultRetention
+	}
+	if _, err := store.Prune(policy); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to apply retention: %v\n", err)
+	}
+}
+
+// listRestorePoints prints the restore points of a store, newest first
+func listRestorePoints(store *verify.BackupStore, jsonOutput bool) {
+	points, err := store.ListRestorePoints()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to list restore points: %v\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		output, _ := json.MarshalIndent(points, "", "  ")
+		fmt.Println(string(output))
+		return
+	}
+
+	if len(points) == 0 {
+		fmt.Printf("No restore points in %s\n", store.Dir())
+		return
+	}
+	fmt.Printf("Restore points in %s:\n", store.Dir())
+	for _, p := range points {
+		label := p.Name
+		if p.Partial {
+			label = strings.TrimSpace(label + " (partial)")
+		}
+		fmt.Printf("  %s  %s  %-6s  %5d files  %10s  %s  %s\n",
+			p.ID,
+			p.CreatedAt.Format("2006-01-02 15:04:05"),
+			p.Kind,
+			len(p.Files),
+			formatBytes(p.Size()),
+			p.GameDir,
+			label)
+	}
+	if size, blobs, err := store.Usage(); err == nil {
+		fmt.Printf("Stored: %s in %d unique files\n", formatBytes(size), blobs)
 	}
 }
 
+// pruneStore applies a retention policy and removes unused contents
+func pruneStore(store *verify.BackupStore, policy verify.RetentionPolicy, jsonOutput bool) {
+	result, err := store.Prune(policy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Prune failed: %v\n", err)
+		os.Exit(1)
+	}
+
+	if jsonOutput {
+		output, _ := json.MarshalIndent(result, "", "  ")
+		fmt.Println(string(output))
+		return
+	}
+	for _, id := range result.Points {
+		fmt.Printf("  [PRUNED] %s\n", id)
+	}
+	fmt.Printf("Removed %d restore points and %d unused files, freed %s\n", len(result.Points), result.Blobs, formatBytes(result.Freed))
+}
+
+// parseSize parses a byte count with an optional KB, MB or GB suffix
+func parseSize(value string) (int64, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	multiplier := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(value, unit.suffix) {
+			value = strings.TrimSpace(strings.TrimSuffix(value, unit.suffix))
+			multiplier = unit.size
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(value, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", value)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
 func printReport(report *verify.Report) {
 	fmt.Println()
 	fmt.Println("=" + strings.Repeat("=", 60))
@@ -328,6 +857,13 @@ func printReport(report *verify.Report) {
 	fmt.Println("=" + strings.Repeat("=", 60))
 	fmt.Printf("Version: %s\n", report.Version)
 	fmt.Printf("Game Directory: %s\n", report.GameDir)
+	if report.Manifest != "" {
+		signed := "unsigned"
+		if report.ManifestSigned {
+			signed = "signed"
+		}
+		fmt.Printf("Manifest: %s (%s)\n", report.Manifest, signed)
+	}
 	fmt.Printf("Timestamp: %s\n", report.Timestamp.Format("2006-01-02 15:04:05"))
 	fmt.Printf("Overall Status: %s\n", report.OverallStatus)
 	fmt.Println()
@@ -339,6 +875,8 @@ func printReport(report *verify.Report) {
 	fmt.Printf("  Warnings: %d\n", report.Summary.Warnings)
 	fmt.Printf("  Missing Files: %d\n", report.Summary.MissingFiles)
 	fmt.Printf("  Modified Files: %d\n", report.Summary.ModifiedFiles)
+	fmt.Printf("  Extra Files: %d\n", report.Summary.ExtraFiles)
+	fmt.Printf("  Unchanged (cached): %d\n", report.Summary.CachedFiles)
 	fmt.Println()
 
 	// Show failed files
@@ -346,7 +884,7 @@ func printReport(report *verify.Report) {
 		fmt.Println("FAILED FILES:")
 		for _, f := range report.Files {
 			if f.Status == verify.StatusFailed {
-				fmt.Printf("  [FAIL] %s\n", f.Path)
+				fmt.Printf("  [FAIL] %s%s\n", f.Path, formatRole(f.Role))
 				fmt.Printf("         %s\n", f.Message)
 			}
 		}
@@ -354,17 +892,28 @@ func printReport(report *verify.Report) {
 	}
 
 	// Show warnings
-	if report.Summary.Warnings > 0 {
+	if report.Summary.Warnings > report.Summary.ExtraFiles {
 		fmt.Println("WARNINGS:")
 		for _, f := range report.Files {
-			if f.Status == verify.StatusWarning {
-				fmt.Printf("  [WARN] %s\n", f.Path)
+			if f.Status == verify.StatusWarning && !f.Extra {
+				fmt.Printf("  [WARN] %s%s\n", f.Path, formatRole(f.Role))
 				fmt.Printf("         %s\n", f.Message)
 			}
 		}
 		fmt.Println()
 	}
 
+	// Show files the manifest does not know
+	if report.Summary.ExtraFiles > 0 {
+		fmt.Println("EXTRA FILES:")
+		for _, f := range report.Files {
+			if f.Extra {
+				fmt.Printf("  [EXTRA] %s (%s)\n", f.Path, formatBytes(f.Size))
+			}
+		}
+		fmt.Println()
+	}
+
 	if report.OverallStatus == verify.StatusOK {
 		fmt.Println("All files verified successfully!")
 	} else if report.OverallStatus == verify.StatusWarning {
@@ -373,9 +922,19 @@ func printReport(report *verify.Report) {
 		fmt.Println("Verification FAILED. Some files are missing or corrupted.")
 		fmt.Println("Recommendation: Reinstall the game or restore from backup.")
 	}
+	if report.HistoryFile != "" {
+		fmt.Printf("Report saved to: %s\n", report.HistoryFile)
+	}
 	fmt.Println(strings.Repeat("=", 61))
 }
 
+func formatRole(role verify.FileRole) string {
+	if role == "" {
+		return ""
+	}
+	return " [" + string(role) + "]"
+}
+
 func formatBytes(bytes int64) string {
 	const (
 		KB = 1024
@@ -6,6 +6,7 @@ import (
 
 	"HyLauncher/internal/config"
 	"HyLauncher/internal/env"
+	"HyLauncher/internal/patch"
 	"HyLauncher/internal/progress"
 	"HyLauncher/internal/service"
 	"HyLauncher/pkg/hyerrors"
@@ -41,6 +42,7 @@ type App struct {
 	authSvc    *service.AuthService
 	newsSvc    *service.NewsService
 	serversSvc *service.ServersService
+	updateSvc  *service.UpdateService
 }
 
 func NewApp() *App {
@@ -63,6 +65,7 @@ func (a *App) Startup(ctx context.Context) {
 	}
 
 	a.launcherCfg = launcherCfg
+	a.applyDownloadSettings()
 
 	if launcherCfg.DiscordRPC {
 		discordAppID := config.GetDiscordAppID()
@@ -89,6 +92,9 @@ func (a *App) Startup(ctx context.Context) {
 	a.instance.BuildVersion = instanceCfg.Build
 	a.instance.InstanceID = instanceCfg.ID
 	a.instance.InstanceName = instanceCfg.Name
+	a.instance.JavaRuntime = instanceCfg.JavaRuntime
+	a.instance.JavaPath = instanceCfg.JavaPath
+	a.instance.Launch = instanceCfg.Launch
 
 	crashReporter, err := service.NewCrashReporter(
 		env.GetDefaultAppDir(),
@@ -104,6 +110,12 @@ func (a *App) Startup(ctx context.Context) {
 	a.gameSvc = service.NewGameService(a.ctx, a.progress, a.authSvc)
 	a.newsSvc = service.NewNewsService()
 	a.serversSvc = service.NewServersService()
+	a.updateSvc = service.NewUpdateService(a.ctx, a.gameSvc, func() model.InstanceModel {
+		return a.instance
+	}, func(status service.UpdateStatus) {
+		runtime.EventsEmit(a.ctx, "update-ready", status)
+	})
+	a.updateSvc.Start()
 
 	logger.Info("App started", "version", config.LauncherVersion)
 
@@ -111,4 +123,5 @@ func (a *App) Startup(ctx context.Context) {
 	go env.CreateFolders(a.instance.InstanceID)
 	go a.checkUpdateSilently()
 	go env.CleanupLauncher(a.instance)
+	go patch.TrimPatchCache()
 }
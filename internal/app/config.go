@@ -2,6 +2,7 @@ package app
 
 import (
 	"HyLauncher/internal/config"
+	"HyLauncher/internal/patch"
 	"HyLauncher/pkg/hyerrors"
 )
 
@@ -80,3 +81,31 @@ func (a *App) GetLocalGameVersion(instanceID string) (string, error) {
 	a.instanceCfg.Build = cfg.Build
 	return cfg.Build, nil
 }
+
+func (a *App) GetPatchCacheLimit() int {
+	return a.launcherCfg.PatchCacheMaxMB
+}
+
+// SetPatchCacheLimit sets the disk budget for cached patches in MB and trims the cache to it
+func (a *App) SetPatchCacheLimit(limitMB int) error {
+	if limitMB < 0 {
+		err := hyerrors.Validation("patch cache limit cannot be negative")
+		hyerrors.Report(err)
+		return err
+	}
+
+	err := config.UpdateLauncher(func(cfg *config.LauncherConfig) error {
+		cfg.PatchCacheMaxMB = limitMB
+		return nil
+	})
+	if err != nil {
+		appErr := hyerrors.WrapConfig(err, "failed to save patch cache limit").
+			WithContext("limitMB", limitMB)
+		hyerrors.Report(appErr)
+		return appErr
+	}
+
+	a.launcherCfg.PatchCacheMaxMB = limitMB
+	go patch.TrimPatchCache()
+	return nil
+}
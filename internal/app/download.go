@@ -0,0 +1,88 @@
+package app
+
+import (
+	"HyLauncher/internal/config"
+	"HyLauncher/pkg/download"
+	"HyLauncher/pkg/hyerrors"
+	"HyLauncher/pkg/logger"
+)
+
+type DownloadSettings struct {
+	LimitKBps   int    `json:"limitKBps"`
+	WindowStart string `json:"windowStart"`
+	WindowEnd   string `json:"windowEnd"`
+}
+
+func (a *App) GetDownloadSettings() DownloadSettings {
+	return DownloadSettings{
+		LimitKBps:   a.launcherCfg.DownloadLimitKBps,
+		WindowStart: a.launcherCfg.DownloadWindowStart,
+		WindowEnd:   a.launcherCfg.DownloadWindowEnd,
+	}
+}
+
+// SetDownloadLimit changes the download speed cap, running downloads slow down right away
+func (a *App) SetDownloadLimit(limitKBps int) error {
+	if limitKBps < 0 {
+		err := hyerrors.Validation("download limit cannot be negative")
+		hyerrors.Report(err)
+		return err
+	}
+
+	err := config.UpdateLauncher(func(cfg *config.LauncherConfig) error {
+		cfg.DownloadLimitKBps = limitKBps
+		return nil
+	})
+	if err != nil {
+		appErr := hyerrors.WrapConfig(err, "failed to save download limit").
+			WithContext("limitKBps", limitKBps)
+		hyerrors.Report(appErr)
+		return appErr
+	}
+
+	a.launcherCfg.DownloadLimitKBps = limitKBps
+	download.SetRateLimit(int64(limitKBps) * 1024)
+	return nil
+}
+
+// SetDownloadSchedule restricts large downloads to a daily "HH:MM" window.
+// Empty start and end remove the restriction.
+func (a *App) SetDownloadSchedule(start, end string) error {
+	schedule, err := download.ParseSchedule(start, end)
+	if err != nil {
+		appErr := hyerrors.Validation("invalid download schedule").
+			WithDetails(err.Error()).
+			WithContext("start", start).
+			WithContext("end", end)
+		hyerrors.Report(appErr)
+		return appErr
+	}
+
+	err = config.UpdateLauncher(func(cfg *config.LauncherConfig) error {
+		cfg.DownloadWindowStart = start
+		cfg.DownloadWindowEnd = end
+		return nil
+	})
+	if err != nil {
+		appErr := hyerrors.WrapConfig(err, "failed to save download schedule")
+		hyerrors.Report(appErr)
+		return appErr
+	}
+
+	a.launcherCfg.DownloadWindowStart = start
+	a.launcherCfg.DownloadWindowEnd = end
+	download.SetSchedule(schedule)
+	return nil
+}
+
+// applyDownloadSettings pushes the saved limit and schedule to the downloader
+func (a *App) applyDownloadSettings() {
+	download.SetRateLimit(int64(a.launcherCfg.DownloadLimitKBps) * 1024)
+
+	schedule, err := download.ParseSchedule(a.launcherCfg.DownloadWindowStart, a.launcherCfg.DownloadWindowEnd)
+	if err != nil {
+		logger.Warn("Ignoring invalid download schedule", "error", err)
+		return
+	}
+	download.SetSchedule(schedule)
+}
@@ -1,14 +1,22 @@
 package app
 
 import (
+	"HyLauncher/internal/game"
 	"HyLauncher/internal/patch"
+	"HyLauncher/internal/service"
+	"HyLauncher/internal/verify"
 	"HyLauncher/pkg/hyerrors"
 	"HyLauncher/pkg/logger"
+	"time"
 )
 
 type VersionsResponse struct {
 	Versions []int  `json:"versions"`
 	Error    string `json:"error,omitempty"`
+
+	// Stale is set when the list comes from the cached manifest because no source was reachable
+	Stale     bool   `json:"stale,omitempty"`
+	FetchedAt string `json:"fetchedAt,omitempty"`
 }
 
 type LaunchResponse struct {
@@ -75,11 +83,15 @@ func (a *App) downloadAndLaunchInternal(playerName string, serverIP string) Laun
 	if err := a.gameSvc.Launch(authPlayerName, a.instance, onGameExit, serverIP); err != nil {
 		// Show the window again if launch failed
 		a.ShowWindow()
-		appErr := hyerrors.GameCritical("failed to launch game").
-			WithDetails(err.Error()).
-			WithContext("player", authPlayerName).
-			WithContext("branch", a.instance.Branch).
-			WithContext("version", a.instance.BuildVersion)
+		// A game that died right away comes with its crash report
+		appErr, ok := err.(*hyerrors.Error)
+		if !ok {
+			appErr = hyerrors.GameCritical("failed to launch game").
+				WithDetails(err.Error()).
+				WithContext("branch", a.instance.Branch).
+				WithContext("version", a.instance.BuildVersion)
+		}
+		appErr.WithContext("player", authPlayerName)
 		hyerrors.Report(appErr)
 		return LaunchResponse{Success: false, Error: appErr.Error()}
 	}
@@ -88,17 +100,178 @@ func (a *App) downloadAndLaunchInternal(playerName string, serverIP string) Laun
 }
 
 func (a *App) GetReleaseVersions() VersionsResponse {
-	release, err := patch.ListAllVersions("release")
+	return listVersions("release")
+}
+
+func (a *App) GetPreReleaseVersions() VersionsResponse {
+	return listVersions("pre-release")
+}
+
+// GetManifestStatus reports whether version data is live or served from the offline cache
+func (a *App) GetManifestStatus() patch.ManifestStatus {
+	return patch.GetManifestStatus()
+}
+
+func listVersions(branch string) VersionsResponse {
+	versions, err := patch.ListAllVersions(branch)
 	if err != nil {
-		return VersionsResponse{Error: err.Error()}
+		return VersionsResponse{Error: err.Error(), Stale: true}
 	}
-	return VersionsResponse{Versions: release}
+
+	resp := VersionsResponse{Versions: versions}
+	if status := patch.GetManifestStatus(); status.Stale {
+		resp.Stale = true
+		if !status.FetchedAt.IsZero() {
+			resp.FetchedAt = status.FetchedAt.Format(time.RFC3339)
+		}
+	}
+	return resp
 }
 
-func (a *App) GetPreReleaseVersions() VersionsResponse {
-	release, err := patch.ListAllVersions("pre-release")
+// RollbackGameUpdate switches the current instance back to the build that was
+// installed before the last update
+func (a *App) RollbackGameUpdate() error {
+	if err := a.gameSvc.RollbackUpdate(a.instance.Branch); err != nil {
+		appErr := hyerrors.WrapGame(err, "failed to roll back game update").
+			WithContext("branch", a.instance.Branch)
+		hyerrors.Report(appErr)
+		return appErr
+	}
+	return nil
+}
+
+// RepairGame re-verifies every file of the current instance and restores the
+// damaged ones without a full reinstall
+func (a *App) RepairGame() (*verify.RepairReport, error) {
+	report, err := a.gameSvc.RepairGame(a.instance)
 	if err != nil {
-		return VersionsResponse{Error: err.Error()}
+		appErr := hyerrors.WrapGame(err, "failed to repair game files").
+			WithContext("branch", a.instance.Branch).
+			WithContext("version", a.instance.BuildVersion)
+		hyerrors.Report(appErr)
+		return nil, appErr
+	}
+	return report, nil
+}
+
+// CreateRestorePoint backs up the whole current instance under a name
+func (a *App) CreateRestorePoint(name string) (*verify.RestorePoint, error) {
+	point, err := a.gameSvc.CreateRestorePoint(a.instance, name)
+	if err != nil {
+		appErr := hyerrors.WrapGame(err, "failed to create restore point").
+			WithContext("branch", a.instance.Branch)
+		hyerrors.Report(appErr)
+		return nil, appErr
+	}
+	return point, nil
+}
+
+// GetRestorePoints lists the restore points of the current instance
+func (a *App) GetRestorePoints() ([]*verify.RestorePoint, error) {
+	points, err := a.gameSvc.RestorePoints(a.instance)
+	if err != nil {
+		appErr := hyerrors.WrapGame(err, "failed to list restore points").
+			WithContext("branch", a.instance.Branch)
+		hyerrors.Report(appErr)
+		return nil, appErr
+	}
+	return points, nil
+}
+
+// RestoreToPoint puts the current instance back to a restore point
+func (a *App) RestoreToPoint(id string) (*verify.RestoreResult, error) {
+	result, err := a.gameSvc.RestoreToPoint(a.instance, id)
+	if err != nil {
+		appErr := hyerrors.WrapGame(err, "failed to restore game files").
+			WithContext("branch", a.instance.Branch).
+			WithContext("point", id)
+		hyerrors.Report(appErr)
+		return nil, appErr
+	}
+	return result, nil
+}
+
+// GetVerifyHistory lists the past verification reports of the current instance
+func (a *App) GetVerifyHistory() ([]verify.HistoryEntry, error) {
+	entries, err := a.gameSvc.VerifyHistory(a.instance)
+	if err != nil {
+		appErr := hyerrors.WrapGame(err, "failed to read verification history").
+			WithContext("branch", a.instance.Branch)
+		hyerrors.Report(appErr)
+		return nil, appErr
+	}
+	return entries, nil
+}
+
+// GetVerifyChanges shows which game files changed since the last verification
+// that passed
+func (a *App) GetVerifyChanges() (*verify.ReportDiff, error) {
+	diff, err := a.gameSvc.VerifyChanges(a.instance)
+	if err != nil {
+		appErr := hyerrors.WrapGame(err, "failed to compare verification reports").
+			WithContext("branch", a.instance.Branch)
+		hyerrors.Report(appErr)
+		return nil, appErr
+	}
+	return diff, nil
+}
+
+// GetGameUpdateStatus reports whether an update was downloaded in the background
+func (a *App) GetGameUpdateStatus() service.UpdateStatus {
+	return a.updateSvc.Status()
+}
+
+// CheckGameUpdate looks for a new build now instead of waiting for the next poll
+func (a *App) CheckGameUpdate() {
+	a.updateSvc.CheckNow()
+}
+
+// PreviewGamePatch reports what patching the current instance would change
+// without touching any file
+func (a *App) PreviewGamePatch() (*patch.PatchReport, error) {
+	report, err := a.gameSvc.PatchGame(a.instance, true)
+	if err != nil {
+		appErr := hyerrors.WrapGame(err, "failed to preview game patch").
+			WithContext("branch", a.instance.Branch).
+			WithContext("version", a.instance.BuildVersion)
+		hyerrors.Report(appErr)
+		return report, appErr
+	}
+	return report, nil
+}
+
+// GetPatchReport returns the last patch report of the current instance
+func (a *App) GetPatchReport(dryRun bool) (*patch.PatchReport, error) {
+	report, err := patch.LoadPatchReport(a.instance.InstanceID, dryRun)
+	if err != nil {
+		return nil, hyerrors.WrapGame(err, "no patch report available").
+			WithContext("instance", a.instance.InstanceID)
+	}
+	return report, nil
+}
+
+// GetGameSessions lists the recorded game sessions of the current instance
+func (a *App) GetGameSessions() ([]*game.Session, error) {
+	sessions, err := a.gameSvc.GameSessions(a.instance.InstanceID)
+	if err != nil {
+		appErr := hyerrors.WrapFileSystem(err, "failed to list game sessions").
+			WithContext("instance", a.instance.InstanceID)
+		hyerrors.Report(appErr)
+		return nil, appErr
+	}
+	return sessions, nil
+}
+
+// GetGameSessionLog returns the captured game output of a session of the
+// current instance
+func (a *App) GetGameSessionLog(sessionID string) (string, error) {
+	log, err := a.gameSvc.GameSessionLog(a.instance.InstanceID, sessionID)
+	if err != nil {
+		appErr := hyerrors.WrapFileSystem(err, "failed to read game session log").
+			WithContext("instance", a.instance.InstanceID).
+			WithContext("session", sessionID)
+		hyerrors.Report(appErr)
+		return "", appErr
 	}
-	return VersionsResponse{Versions: release}
+	return log, nil
 }
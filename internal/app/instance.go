@@ -31,6 +31,9 @@ func (a *App) SelectInstance(instanceID string) error {
 	a.instance.InstanceName = instanceCfg.Name
 	a.instance.Branch = instanceCfg.Branch
 	a.instance.BuildVersion = instanceCfg.Build
+	a.instance.JavaRuntime = instanceCfg.JavaRuntime
+	a.instance.JavaPath = instanceCfg.JavaPath
+	a.instance.Launch = instanceCfg.Launch
 
 	return nil
 }
@@ -119,6 +122,9 @@ func (a *App) SyncInstanceState() error {
 	a.instance.InstanceName = instanceCfg.Name
 	a.instance.Branch = instanceCfg.Branch
 	a.instance.BuildVersion = instanceCfg.Build
+	a.instance.JavaRuntime = instanceCfg.JavaRuntime
+	a.instance.JavaPath = instanceCfg.JavaPath
+	a.instance.Launch = instanceCfg.Launch
 
 	return nil
 }
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"HyLauncher/internal/config"
+	"HyLauncher/internal/java"
+	"HyLauncher/pkg/hyerrors"
+)
+
+// GetJavaRuntimes lists the JREs installed by the launcher
+func (a *App) GetJavaRuntimes() []java.Runtime {
+	return a.gameSvc.JavaRuntimes()
+}
+
+// SetInstanceJavaRuntime pins the current instance to an installed JRE.
+// An empty version follows the branch again.
+func (a *App) SetInstanceJavaRuntime(version string) error {
+	if version != "" {
+		if err := a.gameSvc.VerifyJavaRuntime(version); err != nil {
+			return hyerrors.Validation("runtime is not installed or damaged").
+				WithContext("version", version).
+				WithDetails(err.Error())
+		}
+	}
+
+	err := config.UpdateInstance(a.instance.InstanceID, func(cfg *config.InstanceConfig) error {
+		cfg.JavaRuntime = version
+		return nil
+	})
+	if err != nil {
+		appErr := hyerrors.WrapConfig(err, "failed to pin java runtime").
+			WithContext("instance", a.instance.InstanceID).
+			WithContext("version", version)
+		hyerrors.Report(appErr)
+		return appErr
+	}
+
+	a.instance.JavaRuntime = version
+	a.instanceCfg.JavaRuntime = version
+	return nil
+}
+
+// SetInstanceJavaPath runs the current instance on a user-supplied JDK or JRE
+// once it passes a java -version probe. An empty path uses a managed JRE again.
+func (a *App) SetInstanceJavaPath(path string) (*java.JavaInfo, error) {
+	var info *java.JavaInfo
+	if path != "" {
+		var err error
+		info, err = a.gameSvc.ProbeJava(a.instance.Branch, path)
+		if err != nil {
+			return nil, hyerrors.Validation("java could not be used").
+				WithContext("path", path).
+				WithDetails(err.Error())
+		}
+	}
+
+	err := config.UpdateInstance(a.instance.InstanceID, func(cfg *config.InstanceConfig) error {
+		cfg.JavaPath = path
+		return nil
+	})
+	if err != nil {
+		appErr := hyerrors.WrapConfig(err, "failed to set java path").
+			WithContext("instance", a.instance.InstanceID).
+			WithContext("path", path)
+		hyerrors.Report(appErr)
+		return nil, appErr
+	}
+
+	a.instance.JavaPath = path
+	a.instanceCfg.JavaPath = path
+	return info, nil
+}
+
+// CleanJavaRuntimes removes the JREs no branch or instance uses
+func (a *App) CleanJavaRuntimes() []string {
+	return a.gameSvc.CleanJavaRuntimes()
+}
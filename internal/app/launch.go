@@ -0,0 +1,70 @@
+package app
+
+import (
+	"HyLauncher/internal/config"
+	"HyLauncher/internal/game"
+	"HyLauncher/pkg/hyerrors"
+	"HyLauncher/pkg/model"
+)
+
+// GetInstanceLaunchOptions returns the launch options of the current instance
+func (a *App) GetInstanceLaunchOptions() model.LaunchOptions {
+	return a.instance.Launch
+}
+
+// UpdateInstanceLaunchOptions validates and saves the launch options of the
+// current instance
+func (a *App) UpdateInstanceLaunchOptions(opts model.LaunchOptions) error {
+	if err := game.ValidateLaunchOptions(opts); err != nil {
+		return hyerrors.Validation("invalid launch options").
+			WithContext("instance", a.instance.InstanceID).
+			WithDetails(err.Error())
+	}
+
+	err := config.UpdateInstance(a.instance.InstanceID, func(cfg *config.InstanceConfig) error {
+		cfg.Launch = opts
+		return nil
+	})
+	if err != nil {
+		appErr := hyerrors.WrapConfig(err, "failed to update launch options").
+			WithContext("instance", a.instance.InstanceID)
+		hyerrors.Report(appErr)
+		return appErr
+	}
+
+	a.instance.Launch = opts
+	a.instanceCfg.Launch = opts
+	return nil
+}
+
+// SetInstanceMemory sets the JVM heap of the current instance in MB, 0 keeps
+// the JVM default
+func (a *App) SetInstanceMemory(minMB, maxMB int) error {
+	opts := a.instance.Launch
+	opts.MinMemoryMB = minMB
+	opts.MaxMemoryMB = maxMB
+	return a.UpdateInstanceLaunchOptions(opts)
+}
+
+// SetInstanceEnv sets or, with an empty value, removes an environment
+// override of the current instance
+func (a *App) SetInstanceEnv(key, value string) error {
+	opts := a.instance.Launch
+	env := make(map[string]string, len(opts.Env)+1)
+	for k, v := range opts.Env {
+		env[k] = v
+	}
+	if value == "" {
+		delete(env, key)
+	} else {
+		env[key] = value
+	}
+	opts.Env = env
+	return a.UpdateInstanceLaunchOptions(opts)
+}
+
+// ResetInstanceLaunchOptions drops every launch customisation of the current
+// instance
+func (a *App) ResetInstanceLaunchOptions() error {
+	return a.UpdateInstanceLaunchOptions(model.LaunchOptions{})
+}
@@ -3,8 +3,10 @@ package bootstrap
 import (
 	"crypto/ed25519"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"runtime"
+	"strings"
 )
 
 const (
@@ -54,19 +56,33 @@ func PlatformKey() string {
 	return runtime.GOOS + "/" + runtime.GOARCH
 }
 
+// ErrNoPublicKey means no signing key was embedded at build time
+var ErrNoPublicKey = errors.New("public key not embedded (build without -ldflags?)")
+
 // GetPublicKey parses and returns the Ed25519 public key from the hex-encoded build variable.
 func GetPublicKey() (ed25519.PublicKey, error) {
-	if Ed25519PublicKeyHex == "" {
-		return nil, fmt.Errorf("Ed25519 public key not embedded (build without -ldflags?)")
+	key, err := ParsePublicKey(Ed25519PublicKeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("Ed25519 %w", err)
+	}
+	return key, nil
+}
+
+// ParsePublicKey parses a hex-encoded Ed25519 public key. An empty key
+// returns ErrNoPublicKey.
+func ParsePublicKey(keyHex string) (ed25519.PublicKey, error) {
+	keyHex = strings.TrimSpace(keyHex)
+	if keyHex == "" {
+		return nil, ErrNoPublicKey
 	}
 
-	keyBytes, err := hex.DecodeString(Ed25519PublicKeyHex)
+	keyBytes, err := hex.DecodeString(keyHex)
 	if err != nil {
-		return nil, fmt.Errorf("invalid Ed25519 public key hex: %w", err)
+		return nil, fmt.Errorf("public key hex invalid: %w", err)
 	}
 
 	if len(keyBytes) != ed25519.PublicKeySize {
-		return nil, fmt.Errorf("Ed25519 public key wrong size: got %d, want %d", len(keyBytes), ed25519.PublicKeySize)
+		return nil, fmt.Errorf("public key wrong size: got %d, want %d", len(keyBytes), ed25519.PublicKeySize)
 	}
 
 	return ed25519.PublicKey(keyBytes), nil
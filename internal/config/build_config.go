@@ -22,6 +22,23 @@ var (
 	// GamePatchesURL is the base URL for game patch files
 	GamePatchesURL = ""
 
+	// PatchManifestPublicKeyHex is the hex-encoded Ed25519 public key used to verify
+	// the patches manifest.json signature (manifest.json.sig).
+	// Release builds refuse to patch without it; only dev builds (-tags dev)
+	// accept unsigned manifests.
+	PatchManifestPublicKeyHex = ""
+
+	// PatchRulesPublicKeyHex is the hex-encoded Ed25519 public key used to verify
+	// the client patch rule file signature (patch-rules.json.sig).
+	// When empty, the manifest key is used; with neither, only the builtin rules apply.
+	PatchRulesPublicKeyHex = ""
+
+	// VerifyManifestPublicKeyHex is the hex-encoded Ed25519 public key used to verify
+	// the per-build file verification manifests (<build>.json.sig).
+	// When empty, the manifest key is used; with neither, no manifest is trusted
+	// unless local manifests are explicitly allowed.
+	VerifyManifestPublicKeyHex = ""
+
 	// ServerLogoURL is the URL for the server logo image
 	ServerLogoURL = ""
 
@@ -138,6 +155,27 @@ func GetGamePatchesURL() string {
 	return GamePatchesURL
 }
 
+// GetPatchManifestPublicKeyHex returns the patch manifest signing key (hex)
+func GetPatchManifestPublicKeyHex() string {
+	return PatchManifestPublicKeyHex
+}
+
+// GetPatchRulesPublicKeyHex returns the patch rules signing key (hex)
+func GetPatchRulesPublicKeyHex() string {
+	if PatchRulesPublicKeyHex == "" {
+		return PatchManifestPublicKeyHex
+	}
+	return PatchRulesPublicKeyHex
+}
+
+// GetVerifyManifestPublicKeyHex returns the verification manifest signing key (hex)
+func GetVerifyManifestPublicKeyHex() string {
+	if VerifyManifestPublicKeyHex == "" {
+		return PatchManifestPublicKeyHex
+	}
+	return VerifyManifestPublicKeyHex
+}
+
 // GetServerLogoURL returns the server logo URL
 func GetServerLogoURL() string {
 	return ServerLogoURL
@@ -4,6 +4,8 @@ var launcherDefaults = LauncherConfig{
 	Nick:       "HyLauncher",
 	Instance:   "default",
 	DiscordRPC: true,
+
+	PatchCacheMaxMB: 8192,
 }
 
 var instanceDefaults = InstanceConfig{
@@ -0,0 +1,7 @@
+//go:build !dev
+
+package config
+
+// DevBuild is true for `wails dev` builds, which may run against unsigned
+// patch manifests when no signing key is embedded
+const DevBuild = false
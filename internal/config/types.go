@@ -1,9 +1,19 @@
 package config
 
+import "HyLauncher/pkg/model"
+
 type LauncherConfig struct {
 	Nick       string `toml:"nick"`
 	Instance   string `toml:"instance"`
 	DiscordRPC bool   `toml:"discord_rpc"`
+	// PatchCacheMaxMB caps the disk space kept for downloaded .pwr patches
+	PatchCacheMaxMB int `toml:"patch_cache_max_mb"`
+	// DownloadLimitKBps caps download speed, 0 means unlimited
+	DownloadLimitKBps int `toml:"download_limit_kbps"`
+	// DownloadWindowStart and DownloadWindowEnd ("HH:MM", local time) restrict
+	// large downloads to a daily window. Empty means any time.
+	DownloadWindowStart string `toml:"download_window_start,omitempty"`
+	DownloadWindowEnd   string `toml:"download_window_end,omitempty"`
 	// AzuriomAuthToken stores the encrypted authentication token for Azuriom
 	AzuriomAuthToken string `toml:"azuriom_auth_token,omitempty"`
 }
@@ -13,4 +23,10 @@ type InstanceConfig struct {
 	Name   string `toml:"name"`
 	Branch string `toml:"branch"`
 	Build  string `toml:"build"`
+	// JavaRuntime pins an installed JRE version instead of the branch's
+	JavaRuntime string `toml:"java_runtime,omitempty"`
+	// JavaPath runs the game on a user-supplied JDK or JRE
+	JavaPath string `toml:"java_path,omitempty"`
+	// Launch holds the instance's memory, argument and environment settings
+	Launch model.LaunchOptions `toml:"launch"`
 }
@@ -11,6 +11,9 @@ import (
 func CleanupLauncher(request model.InstanceModel) error {
 	cacheDir := GetCacheDir()
 
+	// Only the top level: leftover archives and flat-named patches from before
+	// the content-addressed patch cache. Patches and .part files in
+	// GetPatchCacheDir are kept for reuse and resume.
 	if err := cleanDirectoryWithFileExentsions(cacheDir, []string{".pwr", ".zip", ".tar.gz"}); err != nil {
 		logger.Warn("Failed to clean cache", "error", err)
 	}
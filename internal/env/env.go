@@ -62,6 +62,16 @@ func GetCacheDir() string {
 	return filepath.Join(GetDefaultAppDir(), "cache")
 }
 
+// GetManifestCacheDir holds the last good patch manifest for offline use
+func GetManifestCacheDir() string {
+	return filepath.Join(GetCacheDir(), "manifest")
+}
+
+// GetPatchCacheDir holds downloaded .pwr patches, named by content hash
+func GetPatchCacheDir() string {
+	return filepath.Join(GetCacheDir(), "patches")
+}
+
 func GetInstancesDir() string {
 	return filepath.Join(GetDefaultAppDir(), "instances")
 }
@@ -78,6 +88,17 @@ func GetInstanceUserDataDir(instance string) string {
 	return filepath.Join(GetInstanceDir(instance), "UserData")
 }
 
+// GetInstanceLogsDir is where the game writes its logs for an instance
+func GetInstanceLogsDir(instance string) string {
+	return filepath.Join(GetInstanceUserDataDir(instance), "Logs")
+}
+
+// GetInstanceSessionsDir is where the launcher keeps the captured output and
+// exit records of an instance's game sessions
+func GetInstanceSessionsDir(instance string) string {
+	return filepath.Join(GetInstanceDir(instance), "sessions")
+}
+
 func GetJREDir() string {
 	return filepath.Join(GetDefaultAppDir(), "shared", "jre")
 }
@@ -87,6 +108,10 @@ func GetSharedGamesDir() string {
 }
 
 func GetGameDir(branch string, version string) string {
+	// "auto" follows the active install slot
+	if version == "auto" {
+		version = resolveAutoSlot(branch)
+	}
 	return filepath.Join(GetSharedGamesDir(), branch, version)
 }
 
@@ -0,0 +1,105 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// AutoSlotPrefix names install slots created for the "auto" version: auto-<build>
+const AutoSlotPrefix = "auto-"
+
+// SlotPointer records which install slot the "auto" version of a branch points to.
+// Slots are plain directories under shared/games/<branch>/. The legacy "auto"
+// directory is a valid slot too.
+type SlotPointer struct {
+	Current  string `json:"current"`
+	Previous string `json:"previous,omitempty"`
+
+	// Verified is set once the current slot has launched successfully
+	Verified bool `json:"verified"`
+
+	// RejectedBuild is a build that was rolled back and should not be retried
+	RejectedBuild int `json:"rejectedBuild,omitempty"`
+}
+
+func GetSlotPointerPath(branch string) string {
+	return filepath.Join(GetSharedGamesDir(), branch, "slots.json")
+}
+
+// AutoSlotName returns the slot directory name for a build
+func AutoSlotName(build int) string {
+	return AutoSlotPrefix + strconv.Itoa(build)
+}
+
+// IsAutoSlot reports whether a version string names an install slot
+func IsAutoSlot(version string) bool {
+	rest, ok := strings.CutPrefix(version, AutoSlotPrefix)
+	if !ok {
+		return false
+	}
+	// auto-<build> or auto-<build>-<n> for a reinstall of the same build
+	build, _, _ := strings.Cut(rest, "-")
+	_, err := strconv.Atoi(build)
+	return err == nil
+}
+
+// LoadSlotPointer returns the slot pointer of a branch, or nil if the branch
+// still uses the legacy "auto" directory
+func LoadSlotPointer(branch string) (*SlotPointer, error) {
+	data, err := os.ReadFile(GetSlotPointerPath(branch))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ptr SlotPointer
+	if err := json.Unmarshal(data, &ptr); err != nil {
+		return nil, fmt.Errorf("parse slot pointer: %w", err)
+	}
+	if ptr.Current == "" {
+		return nil, nil
+	}
+	return &ptr, nil
+}
+
+// SaveSlotPointer writes the pointer with a rename so readers see either the
+// old or the new slot, never a partial file
+func SaveSlotPointer(branch string, ptr *SlotPointer) error {
+	path := GetSlotPointerPath(branch)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(ptr, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// resolveAutoSlot returns the directory name "auto" currently points to
+func resolveAutoSlot(branch string) string {
+	ptr, err := LoadSlotPointer(branch)
+	if err != nil || ptr == nil {
+		return "auto"
+	}
+	if filepath.Base(ptr.Current) != ptr.Current {
+		return "auto"
+	}
+	return ptr.Current
+}
@@ -0,0 +1,101 @@
+package game
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// CrashSignature is a known way for the game to fail, recognised in its output
+type CrashSignature struct {
+	ID      string `json:"id"`
+	Summary string `json:"summary"`
+	Hint    string `json:"hint,omitempty"`
+	pattern *regexp.Regexp
+}
+
+// crashSignatures are checked against every line the game prints
+var crashSignatures = []*CrashSignature{
+	{
+		ID:      "jvm_start",
+		Summary: "the Java runtime could not start",
+		Hint:    "check the instance's memory settings and JVM arguments",
+		pattern: regexp.MustCompile(`Could not create the Java Virtual Machine|Invalid (maximum|initial) heap size|Unrecognized VM option|Error occurred during initialization of VM`),
+	},
+	{
+		ID:      "out_of_memory",
+		Summary: "the game ran out of memory",
+		Hint:    "raise the instance's maximum memory or close other programs",
+		pattern: regexp.MustCompile(`java\.lang\.OutOfMemoryError|System\.OutOfMemoryException`),
+	},
+	{
+		ID:      "jvm_fatal",
+		Summary: "the Java runtime crashed",
+		Hint:    "verify the Java runtime or select another one for the instance",
+		pattern: regexp.MustCompile(`A fatal error has been detected by the Java Runtime Environment`),
+	},
+	{
+		ID:      "gpu_device_lost",
+		Summary: "the graphics driver stopped responding",
+		Hint:    "update the graphics driver and disable overclocking",
+		pattern: regexp.MustCompile(`VK_ERROR_DEVICE_LOST|DXGI_ERROR_DEVICE_(REMOVED|HUNG|RESET)|GL_OUT_OF_MEMORY`),
+	},
+	{
+		ID:      "graphics_init",
+		Summary: "the game could not initialise graphics",
+		Hint:    "update the graphics driver; on Linux try another SDL_VIDEODRIVER",
+		pattern: regexp.MustCompile(`(?i)SDL_Init failed|failed to create (the )?window|no suitable (graphics|GPU) (device|adapter)|VK_ERROR_INCOMPATIBLE_DRIVER`),
+	},
+	{
+		ID:      "unhandled_exception",
+		Summary: "the client hit an unhandled exception",
+		pattern: regexp.MustCompile(`Unhandled [Ee]xception|Fatal error\. Internal CLR error`),
+	},
+	{
+		ID:      "segfault",
+		Summary: "the game crashed with a memory access violation",
+		Hint:    "verify the game files",
+		pattern: regexp.MustCompile(`Segmentation fault|SIGSEGV|EXCEPTION_ACCESS_VIOLATION`),
+	},
+}
+
+// matchCrashSignature returns the signature a line of output shows, or nil
+func matchCrashSignature(line string) *CrashSignature {
+	for _, sig := range crashSignatures {
+		if sig.pattern.MatchString(line) {
+			return sig
+		}
+	}
+	return nil
+}
+
+// windowsExitCodes are NTSTATUS and CLR codes a crashed Windows process exits with
+var windowsExitCodes = map[uint32]string{
+	0xC0000005: "access violation",
+	0xC000001D: "illegal instruction",
+	0xC0000094: "integer division by zero",
+	0xC00000FD: "stack overflow",
+	0xC0000142: "DLL initialisation failed",
+	0xC0000374: "heap corruption",
+	0xC0000409: "stack buffer overrun",
+	0xE0434352: "unhandled .NET exception",
+	0x80131506: ".NET runtime fatal error",
+}
+
+// describeExit explains an exit code. -1 means the process was killed by a
+// signal, which the description then names.
+func describeExit(code int, state string) string {
+	switch {
+	case code == 0:
+		return "exited normally"
+	case code == -1:
+		return state
+	}
+	if desc, ok := windowsExitCodes[uint32(code)]; ok {
+		return fmt.Sprintf("exit code 0x%08X (%s)", uint32(code), desc)
+	}
+	if code > 128 && code < 160 {
+		// Shells and some runtimes report death by signal N as 128+N
+		return fmt.Sprintf("exit code %d (signal %d)", code, code-128)
+	}
+	return fmt.Sprintf("exit code %d", code)
+}
@@ -0,0 +1,156 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"HyLauncher/pkg/model"
+)
+
+// minHeapMB is the smallest -Xmx the game is allowed to start with
+const minHeapMB = 256
+
+// javaToolOptions is read by every JVM on startup, the only way to reach the
+// JVM the client starts on its own
+const javaToolOptions = "JAVA_TOOL_OPTIONS"
+
+// reservedGameArgs are set by the launcher and can't be overridden
+var reservedGameArgs = []string{
+	"--app-dir",
+	"--user-dir",
+	"--java-exec",
+	"--auth-mode",
+	"--uuid",
+	"--name",
+	"--identity-token",
+	"--session-token",
+	"--server",
+}
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidateLaunchOptions checks launch options before they are saved or used
+func ValidateLaunchOptions(opts model.LaunchOptions) error {
+	var errs []error
+
+	if opts.MinMemoryMB < 0 || opts.MaxMemoryMB < 0 {
+		errs = append(errs, errors.New("memory must not be negative"))
+	}
+	if opts.MaxMemoryMB > 0 && opts.MaxMemoryMB < minHeapMB {
+		errs = append(errs, fmt.Errorf("maximum memory must be at least %d MB", minHeapMB))
+	}
+	if opts.MinMemoryMB > 0 && opts.MaxMemoryMB > 0 && opts.MinMemoryMB > opts.MaxMemoryMB {
+		errs = append(errs, fmt.Errorf("minimum memory %d MB exceeds maximum %d MB", opts.MinMemoryMB, opts.MaxMemoryMB))
+	}
+
+	for _, arg := range opts.JVMArgs {
+		switch {
+		case !strings.HasPrefix(arg, "-"):
+			errs = append(errs, fmt.Errorf("JVM argument %q must start with '-'", arg))
+		case strings.ContainsAny(arg, " \t\r\n"):
+			// JAVA_TOOL_OPTIONS is split on whitespace
+			errs = append(errs, fmt.Errorf("JVM argument %q must not contain whitespace", arg))
+		case strings.HasPrefix(arg, "-Xmx") || strings.HasPrefix(arg, "-Xms"):
+			errs = append(errs, fmt.Errorf("JVM argument %q: use the memory settings instead", arg))
+		}
+	}
+
+	for _, arg := range opts.GameArgs {
+		if arg == "" {
+			errs = append(errs, errors.New("game arguments must not be empty"))
+			continue
+		}
+		flag, _, _ := strings.Cut(arg, "=")
+		for _, reserved := range reservedGameArgs {
+			if flag == reserved {
+				errs = append(errs, fmt.Errorf("game argument %s is set by the launcher", reserved))
+			}
+		}
+	}
+
+	for key, value := range opts.Env {
+		if !envKeyPattern.MatchString(key) {
+			errs = append(errs, fmt.Errorf("invalid environment variable name %q", key))
+		}
+		if strings.ContainsRune(value, 0) {
+			errs = append(errs, fmt.Errorf("environment variable %s contains a NUL byte", key))
+		}
+	}
+
+	if len(opts.Wrapper) > 0 {
+		if opts.Wrapper[0] == "" {
+			errs = append(errs, errors.New("wrapper command is empty"))
+		} else if _, err := exec.LookPath(opts.Wrapper[0]); err != nil {
+			errs = append(errs, fmt.Errorf("wrapper command %q not found", opts.Wrapper[0]))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// JVMOptions returns the JVM flags of launch options, heap settings first
+func JVMOptions(opts model.LaunchOptions) []string {
+	var flags []string
+	if opts.MinMemoryMB > 0 {
+		flags = append(flags, fmt.Sprintf("-Xms%dm", opts.MinMemoryMB))
+	}
+	if opts.MaxMemoryMB > 0 {
+		flags = append(flags, fmt.Sprintf("-Xmx%dm", opts.MaxMemoryMB))
+	}
+	return append(flags, opts.JVMArgs...)
+}
+
+// NewLaunchCommand builds the command that starts the client with the
+// launcher's args followed by the instance's game args, through the wrapper
+// if there is one. Environment overrides win over the launcher's defaults,
+// JVM flags are appended to any JAVA_TOOL_OPTIONS already set.
+func NewLaunchCommand(clientPath string, args []string, opts model.LaunchOptions) (*exec.Cmd, error) {
+	if err := ValidateLaunchOptions(opts); err != nil {
+		return nil, fmt.Errorf("launch options: %w", err)
+	}
+
+	args = append(append([]string{}, args...), opts.GameArgs...)
+
+	var cmd *exec.Cmd
+	if len(opts.Wrapper) > 0 {
+		wrapperArgs := append(append(append([]string{}, opts.Wrapper[1:]...), clientPath), args...)
+		cmd = exec.Command(opts.Wrapper[0], wrapperArgs...)
+	} else {
+		cmd = exec.Command(clientPath, args...)
+	}
+
+	SetSDLVideoDriver(cmd)
+
+	overrides := make([]string, 0, len(opts.Env)+1)
+	for key, value := range opts.Env {
+		overrides = append(overrides, key+"="+value)
+	}
+	withEnv(cmd, overrides...)
+
+	if flags := JVMOptions(opts); len(flags) > 0 {
+		value := strings.Join(flags, " ")
+		if current := lookupEnv(cmd, javaToolOptions); current != "" {
+			value = current + " " + value
+		}
+		withEnv(cmd, javaToolOptions+"="+value)
+	}
+
+	return cmd, nil
+}
+
+// lookupEnv returns a variable of the environment cmd will run with
+func lookupEnv(cmd *exec.Cmd, key string) string {
+	if len(cmd.Env) == 0 {
+		return os.Getenv(key)
+	}
+	for _, e := range cmd.Env {
+		if k, v, ok := strings.Cut(e, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
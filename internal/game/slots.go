@@ -0,0 +1,318 @@
+package game
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"HyLauncher/internal/env"
+	"HyLauncher/pkg/fileutil"
+	"HyLauncher/pkg/logger"
+)
+
+// linkedSlotMarker marks a slot whose files may share inodes with another slot
+const linkedSlotMarker = ".linked"
+
+// slotBookkeeping are the files and directories the launcher writes into the
+// root of a slot. They describe that slot alone, so a clone doesn't take them
+// over: a hardlinked copy would be rewritten for both slots at once.
+var slotBookkeeping = map[string]bool{
+	".version":          true,
+	".patch-state.json": true,
+	".verify-history":   true,
+	linkedSlotMarker:    true,
+}
+
+// PrepareSlot creates a fresh install slot for build and returns its name.
+// With seed set the slot starts as a copy of the active install so an
+// incremental patch can be applied to it; unchanged files are hardlinked.
+func PrepareSlot(branch string, build int, seed bool) (string, error) {
+	ptr, err := env.LoadSlotPointer(branch)
+	if err != nil {
+		logger.Warn("Failed to read slot pointer", "branch", branch, "error", err)
+	}
+
+	name := env.AutoSlotName(build)
+	if ptr != nil && (name == ptr.Current || name == ptr.Previous) {
+		// Reinstalling a build we already have a slot for, don't touch it
+		name = fmt.Sprintf("%s-%d", name, time.Now().Unix())
+	}
+
+	dir := env.GetGameDir(branch, name)
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("remove stale slot: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("create slot: %w", err)
+	}
+
+	if seed {
+		src := env.GetGameDir(branch, "auto")
+		logger.Info("Seeding install slot", "from", src, "to", dir)
+		if err := cloneSlot(src, dir); err != nil {
+			_ = os.RemoveAll(dir)
+			return "", fmt.Errorf("seed slot: %w", err)
+		}
+	}
+
+	return name, nil
+}
+
+// DiscardSlot removes a slot that never became active
+func DiscardSlot(branch, slot string) {
+	if ptr, _ := env.LoadSlotPointer(branch); ptr != nil && (slot == ptr.Current || slot == ptr.Previous) {
+		return
+	}
+	if err := os.RemoveAll(env.GetGameDir(branch, slot)); err != nil {
+		logger.Warn("Failed to remove install slot", "slot", slot, "error", err)
+	}
+}
+
+// ActivateSlot atomically points "auto" at slot and keeps the old one for rollback
+func ActivateSlot(branch, slot string) error {
+	old, err := env.LoadSlotPointer(branch)
+	if err != nil {
+		logger.Warn("Failed to read slot pointer", "branch", branch, "error", err)
+	}
+
+	next := &env.SlotPointer{Current: slot}
+	switch {
+	case old != nil:
+		next.RejectedBuild = old.RejectedBuild
+		if old.Current != slot {
+			next.Previous = old.Current
+		}
+	case fileutil.FileExists(filepath.Join(env.GetSharedGamesDir(), branch, "auto")):
+		// First update after migrating from the single "auto" directory
+		next.Previous = "auto"
+	}
+
+	if err := env.SaveSlotPointer(branch, next); err != nil {
+		return fmt.Errorf("save slot pointer: %w", err)
+	}
+
+	logger.Info("Activated install slot", "branch", branch, "current", next.Current, "previous", next.Previous)
+	PruneSlots(branch)
+	return nil
+}
+
+// RollbackSlot swaps "auto" back to the previous slot and remembers the
+// build that was rolled back so it isn't installed again
+func RollbackSlot(ctx context.Context, branch string) (string, error) {
+	ptr, err := env.LoadSlotPointer(branch)
+	if err != nil {
+		return "", fmt.Errorf("read slot pointer: %w", err)
+	}
+	if ptr == nil || ptr.Previous == "" {
+		return "", fmt.Errorf("no previous install to roll back to")
+	}
+
+	if err := CheckInstalled(ctx, branch, ptr.Previous); err != nil {
+		return "", fmt.Errorf("previous install is not usable: %w", err)
+	}
+
+	next := &env.SlotPointer{
+		Current:       ptr.Previous,
+		Previous:      ptr.Current,
+		Verified:      true,
+		RejectedBuild: readSlotBuild(env.GetGameDir(branch, ptr.Current)),
+	}
+	if err := env.SaveSlotPointer(branch, next); err != nil {
+		return "", fmt.Errorf("save slot pointer: %w", err)
+	}
+
+	logger.Info("Rolled back install slot", "branch", branch, "current", next.Current, "rejectedBuild", next.RejectedBuild)
+	return next.Current, nil
+}
+
+// MarkSlotVerified records that the current slot launched fine
+func MarkSlotVerified(branch string) {
+	ptr, err := env.LoadSlotPointer(branch)
+	if err != nil || ptr == nil || ptr.Verified {
+		return
+	}
+	ptr.Verified = true
+	if err := env.SaveSlotPointer(branch, ptr); err != nil {
+		logger.Warn("Failed to save slot pointer", "branch", branch, "error", err)
+	}
+}
+
+// PruneSlots removes install slots that are neither current nor previous
+func PruneSlots(branch string) {
+	ptr, err := env.LoadSlotPointer(branch)
+	if err != nil || ptr == nil {
+		return
+	}
+
+	branchDir := filepath.Join(env.GetSharedGamesDir(), branch)
+	entries, err := os.ReadDir(branchDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || name == ptr.Current || name == ptr.Previous {
+			continue
+		}
+		if name != "auto" && !env.IsAutoSlot(name) {
+			continue
+		}
+
+		logger.Info("Removing old install slot", "branch", branch, "slot", name)
+		if err := os.RemoveAll(filepath.Join(branchDir, name)); err != nil {
+			logger.Warn("Failed to remove install slot", "slot", name, "error", err)
+		}
+	}
+}
+
+// UnlinkSlot gives every file in a seeded slot its own copy. Needed before
+// tools that modify files in place, otherwise the previous slot changes too.
+func UnlinkSlot(dir string) error {
+	marker := filepath.Join(dir, linkedSlotMarker)
+	if !fileutil.FileExists(marker) {
+		return nil
+	}
+
+	logger.Info("Breaking hardlinks in install slot", "dir", dir)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || path == marker {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		tmp := path + ".unlink"
+		if err := fileutil.CopyFile(path, tmp); err != nil {
+			_ = os.Remove(tmp)
+			return err
+		}
+		_ = os.Chmod(tmp, info.Mode().Perm())
+		return os.Rename(tmp, path)
+	})
+	if err != nil {
+		return fmt.Errorf("unlink slot: %w", err)
+	}
+
+	return os.Remove(marker)
+}
+
+// cloneSlot copies src into dst, hardlinking files where the filesystem allows.
+// Files the auth patcher rewrites are copied from their .original backup so
+// the new slot starts from unpatched game files.
+func cloneSlot(src, dst string) error {
+	linked := false
+
+	err := filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if slotBookkeeping[filepath.ToSlash(rel)] {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case d.IsDir():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+
+		case d.Type()&fs.ModeSymlink != 0:
+			dest, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(dest, target)
+
+		case !d.Type().IsRegular():
+			return nil
+		}
+
+		name := d.Name()
+		if strings.HasSuffix(name, ".original") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		from := path
+		if fileutil.FileExists(path + ".original") {
+			from = path + ".original"
+		} else if !isPatchedFile(name) && os.Link(path, target) == nil {
+			linked = true
+			return nil
+		}
+
+		if err := fileutil.CopyFile(from, target); err != nil {
+			return err
+		}
+		return os.Chmod(target, info.Mode().Perm())
+	})
+	if err != nil {
+		return err
+	}
+
+	if linked {
+		return os.WriteFile(filepath.Join(dst, linkedSlotMarker), nil, 0644)
+	}
+	return nil
+}
+
+// isPatchedFile reports files that are modified in place after install
+func isPatchedFile(name string) bool {
+	switch name {
+	case "HytaleClient", "HytaleClient.exe", "HytaleServer.jar":
+		return true
+	}
+	return false
+}
+
+// WriteSlotBuild records the build installed in a slot. The file is replaced
+// rather than rewritten, in case it still shares its inode with another slot.
+func WriteSlotBuild(dir string, build int) error {
+	path := filepath.Join(dir, ".version")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.Itoa(build)), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func readSlotBuild(dir string) int {
+	data, err := os.ReadFile(filepath.Join(dir, ".version"))
+	if err != nil {
+		return 0
+	}
+	build, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return build
+}
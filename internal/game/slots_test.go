@@ -0,0 +1,73 @@
+package game
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCloneSlotKeepsBookkeepingPerSlot(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "slot-a")
+	dst := filepath.Join(t.TempDir(), "slot-b")
+
+	files := map[string]string{
+		"Client/data.bin":                  "game data",
+		".version":                         "5",
+		".patch-state.json":                "{}",
+		".verify-history/r.json":           "{}",
+		"Server/HytaleServer.jar":          "patched",
+		"Server/HytaleServer.jar.original": "unpatched",
+	}
+	for rel, data := range files {
+		path := filepath.Join(src, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := cloneSlot(src, dst); err != nil {
+		t.Fatalf("cloneSlot: %v", err)
+	}
+
+	for _, rel := range []string{".version", ".patch-state.json", ".verify-history"} {
+		if _, err := os.Stat(filepath.Join(dst, rel)); !os.IsNotExist(err) {
+			t.Errorf("%s was cloned into the new slot", rel)
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "Server", "HytaleServer.jar")); string(data) != "unpatched" {
+		t.Errorf("server jar = %q, want the unpatched original", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "Client", "data.bin")); string(data) != "game data" {
+		t.Errorf("Client/data.bin = %q", data)
+	}
+
+	if err := WriteSlotBuild(dst, 6); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSlotBuild(src); got != 5 {
+		t.Errorf("previous slot reports build %d after the new slot was written, want 5", got)
+	}
+	if got := readSlotBuild(dst); got != 6 {
+		t.Errorf("new slot reports build %d, want 6", got)
+	}
+}
+
+func TestWriteSlotBuildReplacesLinkedFile(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	if err := WriteSlotBuild(a, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Link(filepath.Join(a, ".version"), filepath.Join(b, ".version")); err != nil {
+		t.Skipf("hardlinks unsupported: %v", err)
+	}
+
+	if err := WriteSlotBuild(b, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := readSlotBuild(a); got != 1 {
+		t.Errorf("linked slot changed to build %d", got)
+	}
+}
@@ -0,0 +1,308 @@
+package game
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"HyLauncher/pkg/logger"
+)
+
+const (
+	// sessionLogMaxSize is where a session's log rotates, it keeps at most
+	// sessionLogMaxFiles of them
+	sessionLogMaxSize  = 16 << 20
+	sessionLogMaxFiles = 3
+	// sessionTailLines is how much output a crash record keeps
+	sessionTailLines = 200
+	// DefaultSessionKeep is how many sessions an instance keeps logs of
+	DefaultSessionKeep = 20
+)
+
+// Session records one run of the game
+type Session struct {
+	ID         string            `json:"id"`
+	InstanceID string            `json:"instance_id"`
+	Branch     string            `json:"branch"`
+	Version    string            `json:"version"`
+	LogFile    string            `json:"log_file"`
+	StartedAt  time.Time         `json:"started_at"`
+	EndedAt    time.Time         `json:"ended_at"`
+	Running    bool              `json:"running"`
+	ExitCode   int               `json:"exit_code"`
+	Exit       string            `json:"exit,omitempty"`
+	Abnormal   bool              `json:"abnormal"`
+	Signatures []*CrashSignature `json:"signatures,omitempty"`
+	// Tail is the end of the output, kept for abnormal exits
+	Tail []string `json:"tail,omitempty"`
+}
+
+// Uptime returns how long the session ran, or has been running
+func (s *Session) Uptime() time.Duration {
+	if s.Running || s.EndedAt.IsZero() {
+		return time.Since(s.StartedAt)
+	}
+	return s.EndedAt.Sub(s.StartedAt)
+}
+
+// Summary describes how the session ended in one line
+func (s *Session) Summary() string {
+	if s.Running {
+		return "running"
+	}
+	if len(s.Signatures) > 0 {
+		return fmt.Sprintf("%s, %s", s.Signatures[0].Summary, s.Exit)
+	}
+	return s.Exit
+}
+
+// SessionInfo describes the session a supervisor starts
+type SessionInfo struct {
+	InstanceID string
+	Branch     string
+	Version    string
+	// Dir holds the session logs and records
+	Dir string
+	// Keep is how many sessions Dir keeps, 0 uses DefaultSessionKeep
+	Keep int
+}
+
+// Supervisor runs the game process, captures its output into a rotating
+// session log and records how it exits
+type Supervisor struct {
+	cmd     *exec.Cmd
+	dir     string
+	session *Session
+	log     *rotatingFile
+	tail    *tailBuffer
+	stdout  *lineWriter
+	stderr  *lineWriter
+
+	mu   sync.Mutex
+	seen map[string]bool
+
+	done    chan struct{}
+	waitErr error
+}
+
+// Supervise starts cmd with its output captured. Stdout and Stderr of cmd
+// are replaced.
+func Supervise(cmd *exec.Cmd, info SessionInfo) (*Supervisor, error) {
+	if err := os.MkdirAll(info.Dir, 0755); err != nil {
+		return nil, fmt.Errorf("create session dir: %w", err)
+	}
+
+	keep := info.Keep
+	if keep <= 0 {
+		keep = DefaultSessionKeep
+	}
+	// Make room for the session that is about to start
+	pruneSessions(info.Dir, keep-1)
+
+	started := time.Now()
+	id := strings.ReplaceAll(started.Format("20060102_150405.000"), ".", "_")
+	logPath := filepath.Join(info.Dir, "game_"+id+".log")
+
+	log, err := openRotatingFile(logPath, sessionLogMaxSize, sessionLogMaxFiles)
+	if err != nil {
+		return nil, fmt.Errorf("open session log: %w", err)
+	}
+
+	s := &Supervisor{
+		cmd: cmd,
+		dir: info.Dir,
+		session: &Session{
+			ID:         id,
+			InstanceID: info.InstanceID,
+			Branch:     info.Branch,
+			Version:    info.Version,
+			LogFile:    logPath,
+			StartedAt:  started,
+			Running:    true,
+		},
+		log:  log,
+		tail: &tailBuffer{max: sessionTailLines},
+		seen: make(map[string]bool),
+		done: make(chan struct{}),
+	}
+	s.stdout = &lineWriter{onLine: s.line}
+	s.stderr = &lineWriter{onLine: s.line}
+	cmd.Stdout = s.stdout
+	cmd.Stderr = s.stderr
+
+	fmt.Fprintf(log, "# session %s: instance %s, %s build %s, started %s\n",
+		id, info.InstanceID, info.Branch, info.Version, started.Format(time.RFC3339))
+
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(log, "# failed to start: %v\n", err)
+		log.Close()
+		s.session.Running = false
+		s.session.EndedAt = time.Now()
+		s.session.ExitCode = -1
+		s.session.Exit = "failed to start"
+		s.session.Abnormal = true
+		s.save()
+		return nil, err
+	}
+	s.save()
+
+	go s.wait()
+	return s, nil
+}
+
+// line handles one line of game output
+func (s *Supervisor) line(line string) {
+	_, _ = s.log.Write([]byte(line + "\n"))
+	s.tail.add(line)
+
+	sig := matchCrashSignature(line)
+	if sig == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.seen[sig.ID] {
+		s.seen[sig.ID] = true
+		s.session.Signatures = append(s.session.Signatures, sig)
+	}
+}
+
+func (s *Supervisor) wait() {
+	err := s.cmd.Wait()
+	// Wait returns once the output is copied, so no more lines arrive
+	s.stdout.flush()
+	s.stderr.flush()
+
+	code, state := -1, "unknown"
+	if ps := s.cmd.ProcessState; ps != nil {
+		code, state = ps.ExitCode(), ps.String()
+	}
+
+	s.mu.Lock()
+	sess := s.session
+	sess.Running = false
+	sess.EndedAt = time.Now()
+	sess.ExitCode = code
+	sess.Exit = describeExit(code, state)
+	sess.Abnormal = code != 0
+	if sess.Abnormal {
+		sess.Tail = s.tail.Lines()
+	}
+	s.waitErr = err
+	s.mu.Unlock()
+
+	fmt.Fprintf(s.log, "# session %s ended %s after %s: %s\n",
+		sess.ID, sess.EndedAt.Format(time.RFC3339), sess.Uptime().Round(time.Second), sess.Summary())
+	if err := s.log.Close(); err != nil {
+		logger.Warn("Failed to close session log", "path", sess.LogFile, "error", err)
+	}
+	s.save()
+
+	logger.Info("Game session ended", "session", sess.ID, "exit", sess.Exit, "abnormal", sess.Abnormal, "uptime", sess.Uptime())
+	close(s.done)
+}
+
+// save writes the session record next to its log
+func (s *Supervisor) save() {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(s.session, "", "  ")
+	s.mu.Unlock()
+	if err == nil {
+		err = os.WriteFile(sessionRecordPath(s.dir, s.session.ID), data, 0644)
+	}
+	if err != nil {
+		logger.Warn("Failed to save game session", "session", s.session.ID, "error", err)
+	}
+}
+
+// Done is closed once the game exited and the session is recorded
+func (s *Supervisor) Done() <-chan struct{} {
+	return s.done
+}
+
+// Wait blocks until the game exits and returns the finished session and the
+// error of exec.Cmd.Wait
+func (s *Supervisor) Wait() (*Session, error) {
+	<-s.done
+	return s.session, s.waitErr
+}
+
+// Tail returns the last lines the game printed so far
+func (s *Supervisor) Tail() []string {
+	return s.tail.Lines()
+}
+
+func sessionRecordPath(dir, id string) string {
+	return filepath.Join(dir, "game_"+id+".json")
+}
+
+// ListSessions returns the recorded sessions in dir, newest first
+func ListSessions(dir string) ([]*Session, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "game_*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(matches)))
+
+	sessions := make([]*Session, 0, len(matches))
+	for _, path := range matches {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		var sess Session
+		if err := json.Unmarshal(data, &sess); err != nil {
+			continue
+		}
+		sessions = append(sessions, &sess)
+	}
+	return sessions, nil
+}
+
+// ReadSessionLog returns the captured output of a session, rotated parts
+// first
+func ReadSessionLog(dir, id string) (string, error) {
+	if strings.ContainsAny(id, `/\`) || strings.Contains(id, "..") {
+		return "", fmt.Errorf("invalid session id %q", id)
+	}
+	path := filepath.Join(dir, "game_"+id+".log")
+
+	var b strings.Builder
+	for i := sessionLogMaxFiles - 1; i >= 1; i-- {
+		if data, err := os.ReadFile(fmt.Sprintf("%s.%d", path, i)); err == nil {
+			b.Write(data)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	b.Write(data)
+	return b.String(), nil
+}
+
+// pruneSessions removes the logs and records of all but the newest keep
+// sessions in dir
+func pruneSessions(dir string, keep int) {
+	matches, err := filepath.Glob(filepath.Join(dir, "game_*.json"))
+	if err != nil || len(matches) <= keep {
+		return
+	}
+	sort.Strings(matches)
+
+	for _, record := range matches[:len(matches)-keep] {
+		base := strings.TrimSuffix(record, ".json")
+		logs, _ := filepath.Glob(base + ".log*")
+		for _, path := range append(logs, record) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				logger.Warn("Failed to remove old game session", "path", path, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package game
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSuperviseExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	tests := []struct {
+		name         string
+		script       string
+		wantAbnormal bool
+		wantWaitErr  bool
+		wantSig      string
+	}{
+		{"clean", "echo started", false, false, ""},
+		{"exit code", "echo started; exit 3", true, true, ""},
+		{"out of memory", "echo 'java.lang.OutOfMemoryError: Java heap space' >&2; exit 1", true, true, "out_of_memory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sup, err := Supervise(exec.Command("sh", "-c", tt.script), SessionInfo{InstanceID: "test", Branch: "release", Version: "8", Dir: dir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			sess, waitErr := sup.Wait()
+
+			if sess.Abnormal != tt.wantAbnormal || (waitErr != nil) != tt.wantWaitErr {
+				t.Fatalf("abnormal = %v, wait error = %v", sess.Abnormal, waitErr)
+			}
+			if sess.Running || sess.EndedAt.IsZero() {
+				t.Errorf("session not finished: %+v", sess)
+			}
+			if tt.wantSig != "" && (len(sess.Signatures) == 0 || sess.Signatures[0].ID != tt.wantSig) {
+				t.Errorf("signatures = %v, want %s", sess.Signatures, tt.wantSig)
+			}
+			if tt.wantAbnormal && len(sess.Tail) == 0 {
+				t.Error("abnormal exit kept no output tail")
+			}
+
+			sessions, err := ListSessions(dir)
+			if err != nil || len(sessions) != 1 || sessions[0].Abnormal != tt.wantAbnormal {
+				t.Fatalf("ListSessions = %v, %v", sessions, err)
+			}
+			log, err := ReadSessionLog(dir, sess.ID)
+			if err != nil || !strings.Contains(log, "started") && !strings.Contains(log, "OutOfMemoryError") {
+				t.Errorf("session log = %q, %v", log, err)
+			}
+		})
+	}
+}
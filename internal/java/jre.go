@@ -37,23 +37,39 @@ func GetJREVersionDir(version string) string {
 	return filepath.Join(env.GetJREDir(), version)
 }
 
-func FetchJREManifest(branch string) (*JREJSON, error) {
+// FetchJREManifest fetches the jre.json of a branch
+func FetchJREManifest(ctx context.Context, client *http.Client, branch string) (*JREJSON, error) {
 	url := fmt.Sprintf("%s/version/%s/jre.json", config.GetJREManifestURL(), branch)
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+
 	var jreData JREJSON
 	if err := json.NewDecoder(resp.Body).Decode(&jreData); err != nil {
 		return nil, err
 	}
+	if jreData.Version == "" {
+		return nil, fmt.Errorf("jre.json of %s has no version", branch)
+	}
 	return &jreData, nil
 }
 
+func (m *Manager) fetchManifest(ctx context.Context, branch string) (*JREJSON, error) {
+	return FetchJREManifest(ctx, m.client, branch)
+}
+
 func verifyJREVersion(version string) error {
-	javaBin := getJavaExecutablePathForVersion(version)
+	javaBin := javaExecutable(GetJREVersionDir(version))
 
 	if !fileutil.FileExistsNative(javaBin) {
 		return ErrJavaNotFound
@@ -66,52 +82,6 @@ func verifyJREVersion(version string) error {
 	return nil
 }
 
-func EnsureJRE(ctx context.Context, branch string, reporter *progress.Reporter) error {
-	logger.Info("Checking JRE", "branch", branch)
-
-	manifest, err := FetchJREManifest(branch)
-	if err != nil {
-		logger.Error("Failed to fetch JRE manifest", "branch", branch, "error", err)
-		return err
-	}
-
-	jreVersion := manifest.Version
-	jreDir := GetJREVersionDir(jreVersion)
-
-	logger.Info("JRE version required", "version", jreVersion)
-
-	if verifyJREVersion(jreVersion) == nil {
-		logger.Info("JRE already installed", "version", jreVersion)
-		if reporter != nil {
-			reporter.Report(progress.StageJRE, 100, fmt.Sprintf("JRE %s ready", jreVersion))
-		}
-		return nil
-	}
-
-	if reporter != nil {
-		reporter.Report(progress.StageJRE, 0, fmt.Sprintf("Installing JRE %s", jreVersion))
-	}
-
-	osName := env.GetOS()
-	arch := env.GetArch()
-	cacheDir := env.GetCacheDir()
-
-	logger.Info("Installing JRE", "version", jreVersion, "os", osName, "arch", arch)
-
-	if err := downloadAndInstallJRE(ctx, manifest, jreDir, cacheDir, osName, arch, reporter); err != nil {
-		logger.Error("Failed to install JRE", "version", jreVersion, "error", err)
-		_ = os.RemoveAll(jreDir)
-		return err
-	}
-
-	logger.Info("JRE installed successfully", "version", jreVersion)
-
-	if reporter != nil {
-		reporter.Report(progress.StageJRE, 100, fmt.Sprintf("JRE %s installed", jreVersion))
-	}
-	return nil
-}
-
 func downloadAndInstallJRE(ctx context.Context, manifest *JREJSON, jreDir, cacheDir, osName, arch string, reporter *progress.Reporter) error {
 	osData, ok := manifest.DownloadURL[osName]
 	if !ok {
@@ -198,41 +168,10 @@ func downloadAndInstallJRE(ctx context.Context, manifest *JREJSON, jreDir, cache
 	}
 
 	if runtime.GOOS != "windows" {
-		javaExec := getJavaExecutablePathForVersion(manifest.Version)
+		javaExec := javaExecutable(jreDir)
 		_ = os.Chmod(javaExec, 0755)
 	}
 
 	_ = os.Remove(cacheFile)
 	return nil
 }
-
-func VerifyJRE(branch string) error {
-	manifest, err := FetchJREManifest(branch)
-	if err != nil {
-		return err
-	}
-	return verifyJREVersion(manifest.Version)
-}
-
-func GetJavaExec(branch string) (string, error) {
-	manifest, err := FetchJREManifest(branch)
-	if err != nil {
-		return "", err
-	}
-
-	if err := verifyJREVersion(manifest.Version); err != nil {
-		return "", err
-	}
-
-	return getJavaExecutablePathForVersion(manifest.Version), nil
-}
-
-func getJavaExecutablePathForVersion(version string) string {
-	base := GetJREVersionDir(version)
-	if runtime.GOOS == "darwin" {
-		return filepath.Join(base, "Contents", "Home", "bin", "java")
-	} else if runtime.GOOS == "windows" {
-		return filepath.Join(base, "bin", "java.exe")
-	}
-	return filepath.Join(base, "bin", "java")
-}
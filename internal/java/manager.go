@@ -0,0 +1,425 @@
+package java
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"HyLauncher/internal/env"
+	"HyLauncher/internal/progress"
+	"HyLauncher/pkg/logger"
+)
+
+// indexVersion is bumped whenever the index format changes
+const indexVersion = 1
+
+// manifestTimeout bounds fetching jre.json
+const manifestTimeout = 15 * time.Second
+
+// ErrJavaTooOld means a custom Java is older than the runtime the branch needs
+var ErrJavaTooOld = errors.New("java too old")
+
+// Runtime is a JRE installed by the launcher
+type Runtime struct {
+	Version string `json:"version"`
+	Vendor  string `json:"vendor,omitempty"`
+	OS      string `json:"os"`
+	Arch    string `json:"arch"`
+	// TreeSHA256 covers every extracted file, see hashTree
+	TreeSHA256  string    `json:"tree_sha256"`
+	InstalledAt time.Time `json:"installed_at"`
+	LastUsed    time.Time `json:"last_used"`
+}
+
+// Dir returns the directory the runtime is installed in
+func (r *Runtime) Dir() string {
+	return GetJREVersionDir(r.Version)
+}
+
+// Exec returns the runtime's java executable
+func (r *Runtime) Exec() string {
+	return javaExecutable(r.Dir())
+}
+
+type runtimeIndex struct {
+	Version  int                 `json:"version"`
+	Runtimes map[string]*Runtime `json:"runtimes"`
+	// Required maps each branch to the runtime its jre.json last asked for
+	Required map[string]string `json:"required"`
+}
+
+// Selection says which Java an instance runs on. Path wins over Runtime,
+// which wins over the runtime the branch requires.
+type Selection struct {
+	Branch string
+	// Runtime pins an installed runtime version
+	Runtime string
+	// Path is a user-supplied JDK or JRE, or its java executable
+	Path string
+}
+
+// Manager installs and tracks the JREs in the shared JRE directory. It keeps
+// an index of the installed runtimes so launching works offline.
+type Manager struct {
+	dir    string
+	client *http.Client
+
+	mu    sync.Mutex
+	index *runtimeIndex
+}
+
+// NewManager returns a manager for the shared JRE directory
+func NewManager() *Manager {
+	return &Manager{
+		dir:    env.GetJREDir(),
+		client: &http.Client{Timeout: manifestTimeout},
+	}
+}
+
+func (m *Manager) indexPath() string {
+	return filepath.Join(m.dir, "index.json")
+}
+
+// load reads the index once. Callers hold mu.
+func (m *Manager) load() *runtimeIndex {
+	if m.index != nil {
+		return m.index
+	}
+	m.index = &runtimeIndex{
+		Version:  indexVersion,
+		Runtimes: make(map[string]*Runtime),
+		Required: make(map[string]string),
+	}
+
+	data, err := os.ReadFile(m.indexPath())
+	if err != nil {
+		return m.index
+	}
+	var index runtimeIndex
+	if err := json.Unmarshal(data, &index); err != nil || index.Version != indexVersion {
+		logger.Warn("Ignoring unreadable JRE index", "path", m.indexPath(), "error", err)
+		return m.index
+	}
+	if index.Runtimes != nil {
+		m.index.Runtimes = index.Runtimes
+	}
+	if index.Required != nil {
+		m.index.Required = index.Required
+	}
+	return m.index
+}
+
+// save writes the index atomically. Callers hold mu.
+func (m *Manager) save() error {
+	data, err := json.MarshalIndent(m.load(), "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode JRE index: %w", err)
+	}
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return fmt.Errorf("create JRE directory: %w", err)
+	}
+	tmp := m.indexPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write JRE index: %w", err)
+	}
+	if err := os.Rename(tmp, m.indexPath()); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace JRE index: %w", err)
+	}
+	return nil
+}
+
+// Runtimes lists the installed runtimes, newest version first
+func (m *Manager) Runtimes() []Runtime {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var list []Runtime
+	for _, r := range m.load().Runtimes {
+		list = append(list, *r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version > list[j].Version })
+	return list
+}
+
+// RequiredVersion returns the runtime version a branch needed when jre.json
+// was last fetched, "" if it never was
+func (m *Manager) RequiredVersion(branch string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.load().Required[branch]
+}
+
+// Ensure makes the Java of a selection available. A custom path must pass a
+// probe, a pinned runtime must be installed and run, and otherwise the
+// runtime the branch requires is installed if needed. When jre.json can't be
+// fetched the runtime the branch used last is kept.
+func (m *Manager) Ensure(ctx context.Context, sel Selection, reporter *progress.Reporter) error {
+	switch {
+	case sel.Path != "":
+		_, err := m.ProbeCustom(sel.Branch, sel.Path)
+		if err == nil && reporter != nil {
+			reporter.Report(progress.StageJRE, 100, "Custom Java ready")
+		}
+		return err
+	case sel.Runtime != "":
+		if err := m.check(sel.Runtime); err != nil {
+			return fmt.Errorf("pinned runtime %s: %w", sel.Runtime, err)
+		}
+		if reporter != nil {
+			reporter.Report(progress.StageJRE, 100, fmt.Sprintf("JRE %s ready", sel.Runtime))
+		}
+		return nil
+	}
+
+	logger.Info("Checking JRE", "branch", sel.Branch)
+
+	manifest, err := m.fetchManifest(ctx, sel.Branch)
+	if err != nil {
+		version := m.RequiredVersion(sel.Branch)
+		if version == "" || m.check(version) != nil {
+			logger.Error("Failed to fetch JRE manifest", "branch", sel.Branch, "error", err)
+			return err
+		}
+		logger.Warn("JRE manifest unavailable, using installed runtime", "branch", sel.Branch, "version", version, "error", err)
+		if reporter != nil {
+			reporter.Report(progress.StageJRE, 100, fmt.Sprintf("JRE %s ready", version))
+		}
+		return nil
+	}
+
+	version := manifest.Version
+	logger.Info("JRE version required", "version", version)
+
+	m.mu.Lock()
+	m.load().Required[sel.Branch] = version
+	saveErr := m.save()
+	m.mu.Unlock()
+	if saveErr != nil {
+		logger.Warn("Failed to save JRE index", "error", saveErr)
+	}
+
+	if err := m.adopt(version); err == nil {
+		logger.Info("JRE already installed", "version", version)
+		if reporter != nil {
+			reporter.Report(progress.StageJRE, 100, fmt.Sprintf("JRE %s ready", version))
+		}
+		return nil
+	}
+
+	if reporter != nil {
+		reporter.Report(progress.StageJRE, 0, fmt.Sprintf("Installing JRE %s", version))
+	}
+	if err := m.install(ctx, manifest, reporter); err != nil {
+		return err
+	}
+	if reporter != nil {
+		reporter.Report(progress.StageJRE, 100, fmt.Sprintf("JRE %s installed", version))
+	}
+	return nil
+}
+
+// JavaExec returns the java executable of a selection from the index alone,
+// so it works offline
+func (m *Manager) JavaExec(sel Selection) (string, error) {
+	if sel.Path != "" {
+		info, err := m.ProbeCustom(sel.Branch, sel.Path)
+		if err != nil {
+			return "", err
+		}
+		return info.Exec, nil
+	}
+
+	version := sel.Runtime
+	if version == "" {
+		version = m.RequiredVersion(sel.Branch)
+	}
+	if version == "" {
+		return "", fmt.Errorf("%w: no runtime installed for branch %s", ErrJavaNotFound, sel.Branch)
+	}
+	if err := m.check(version); err != nil {
+		return "", err
+	}
+
+	m.mu.Lock()
+	if r, ok := m.load().Runtimes[version]; ok {
+		r.LastUsed = time.Now()
+		_ = m.save()
+	}
+	m.mu.Unlock()
+
+	return javaExecutable(GetJREVersionDir(version)), nil
+}
+
+// ProbeCustom checks a user-supplied Java. It must run, and must not be older
+// than the runtime the branch requires when that is known.
+func (m *Manager) ProbeCustom(branch, path string) (*JavaInfo, error) {
+	info, err := ProbeJava(path)
+	if err != nil {
+		return nil, err
+	}
+	if required := m.RequiredVersion(branch); required != "" && info.Major() < majorVersion(required) {
+		return nil, fmt.Errorf("%w: %s is Java %s, branch %s needs %d or newer", ErrJavaTooOld, info.Exec, info.Version, branch, majorVersion(required))
+	}
+	return info, nil
+}
+
+// check makes sure a runtime is installed and its java runs
+func (m *Manager) check(version string) error {
+	m.mu.Lock()
+	_, known := m.load().Runtimes[version]
+	m.mu.Unlock()
+	if !known {
+		return fmt.Errorf("%w: runtime %s is not installed", ErrJavaNotFound, version)
+	}
+	return verifyJREVersion(version)
+}
+
+// Verify re-hashes an installed runtime and compares it with the index, to
+// find runtimes that were damaged or tampered with
+func (m *Manager) Verify(version string) error {
+	if err := m.check(version); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	want := m.load().Runtimes[version].TreeSHA256
+	m.mu.Unlock()
+
+	got, err := hashTree(GetJREVersionDir(version))
+	if err != nil {
+		return fmt.Errorf("hash runtime %s: %w", version, err)
+	}
+	if got != want {
+		return fmt.Errorf("%w: runtime %s does not match its recorded hash", ErrJavaBroken, version)
+	}
+	return nil
+}
+
+// adopt makes sure an installed runtime is in the index, recording runtimes
+// installed before the index existed
+func (m *Manager) adopt(version string) error {
+	if err := verifyJREVersion(version); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	_, known := m.load().Runtimes[version]
+	m.mu.Unlock()
+	if known {
+		return nil
+	}
+
+	logger.Info("Recording installed JRE", "version", version)
+	return m.record(version)
+}
+
+// record hashes and probes an installed runtime and adds it to the index
+func (m *Manager) record(version string) error {
+	dir := GetJREVersionDir(version)
+	sum, err := hashTree(dir)
+	if err != nil {
+		return fmt.Errorf("hash runtime %s: %w", version, err)
+	}
+
+	r := &Runtime{
+		Version:     version,
+		OS:          env.GetOS(),
+		Arch:        env.GetArch(),
+		TreeSHA256:  sum,
+		InstalledAt: time.Now(),
+		LastUsed:    time.Now(),
+	}
+	if info, err := ProbeJava(javaExecutable(dir)); err == nil {
+		r.Vendor = info.Vendor
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.load().Runtimes[version] = r
+	return m.save()
+}
+
+// install downloads and extracts the runtime of a manifest and records it
+func (m *Manager) install(ctx context.Context, manifest *JREJSON, reporter *progress.Reporter) error {
+	version := manifest.Version
+	jreDir := GetJREVersionDir(version)
+	osName := env.GetOS()
+	arch := env.GetArch()
+
+	logger.Info("Installing JRE", "version", version, "os", osName, "arch", arch)
+
+	if err := downloadAndInstallJRE(ctx, manifest, jreDir, env.GetCacheDir(), osName, arch, reporter); err != nil {
+		logger.Error("Failed to install JRE", "version", version, "error", err)
+		_ = os.RemoveAll(jreDir)
+		return err
+	}
+	if err := m.record(version); err != nil {
+		return fmt.Errorf("record JRE %s: %w", version, err)
+	}
+
+	logger.Info("JRE installed successfully", "version", version)
+	return nil
+}
+
+// Remove uninstalls a runtime
+func (m *Manager) Remove(version string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := os.RemoveAll(GetJREVersionDir(version)); err != nil {
+		return fmt.Errorf("remove runtime %s: %w", version, err)
+	}
+	delete(m.load().Runtimes, version)
+	return m.save()
+}
+
+// GC removes the runtimes no branch requires and keep doesn't list, along
+// with leftovers of interrupted installs. Returns the removed versions.
+func (m *Manager) GC(keep []string) ([]string, error) {
+	m.mu.Lock()
+	index := m.load()
+	used := make(map[string]bool)
+	for _, v := range index.Required {
+		used[v] = true
+	}
+	for _, v := range keep {
+		used[v] = true
+	}
+	m.mu.Unlock()
+
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read JRE directory: %w", err)
+	}
+
+	var removed []string
+	for _, e := range entries {
+		name := e.Name()
+		if !e.IsDir() || used[name] {
+			continue
+		}
+		if strings.HasSuffix(name, ".tmp") {
+			_ = os.RemoveAll(filepath.Join(m.dir, name))
+			continue
+		}
+		if err := m.Remove(name); err != nil {
+			logger.Warn("Failed to remove unused JRE", "version", name, "error", err)
+			continue
+		}
+		logger.Info("Removed unused JRE", "version", name)
+		removed = append(removed, name)
+	}
+	return removed, nil
+}
@@ -0,0 +1,134 @@
+package java
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"HyLauncher/internal/platform"
+)
+
+// probeTimeout bounds a java -version run, a broken JVM may hang
+const probeTimeout = 15 * time.Second
+
+// JavaInfo is what a Java executable reports about itself
+type JavaInfo struct {
+	Exec    string `json:"exec"`
+	Home    string `json:"home"`
+	Version string `json:"version"`
+	Vendor  string `json:"vendor"`
+	Arch    string `json:"arch"`
+}
+
+// Major returns the feature release of the version, 8 for "1.8.0_402"
+func (i *JavaInfo) Major() int {
+	return majorVersion(i.Version)
+}
+
+// ProbeJava runs java -version on a JDK or JRE directory or a java
+// executable and reads what it reports
+func ProbeJava(path string) (*JavaInfo, error) {
+	exe, err := findJavaExecutable(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	// Properties go to stderr together with the version banner
+	cmd := exec.CommandContext(ctx, exe, "-XshowSettings:properties", "-version")
+	platform.HideConsoleWindow(cmd)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s did not answer within %s", exe, probeTimeout)
+		}
+		return nil, fmt.Errorf("%s -version failed: %w", exe, err)
+	}
+
+	props := parseProperties(out.String())
+	info := &JavaInfo{
+		Exec:    exe,
+		Home:    props["java.home"],
+		Version: props["java.version"],
+		Vendor:  props["java.vendor"],
+		Arch:    props["os.arch"],
+	}
+	if info.Version == "" {
+		return nil, fmt.Errorf("%s did not report a Java version", exe)
+	}
+	return info, nil
+}
+
+// findJavaExecutable returns the java executable of path, which may be the
+// executable itself or a Java home
+func findJavaExecutable(path string) (string, error) {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrJavaNotFound, path)
+	}
+	if !stat.IsDir() {
+		return path, nil
+	}
+
+	for _, candidate := range []string{javaExecutable(path), filepath.Join(path, "bin", javaBinaryName())} {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("%w: no java executable in %s", ErrJavaNotFound, path)
+}
+
+// parseProperties reads the "key = value" lines of -XshowSettings:properties.
+// Multi-line values keep their first line.
+func parseProperties(output string) map[string]string {
+	props := make(map[string]string)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " = ")
+		if !ok || strings.ContainsAny(key, " \t") {
+			continue
+		}
+		if _, seen := props[key]; !seen {
+			props[key] = strings.TrimSpace(value)
+		}
+	}
+	return props
+}
+
+// majorVersion returns the feature release of a Java version string
+func majorVersion(version string) int {
+	version = strings.TrimPrefix(version, "1.")
+	end := strings.IndexFunc(version, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		version = version[:end]
+	}
+	major, _ := strconv.Atoi(version)
+	return major
+}
+
+func javaBinaryName() string {
+	if runtime.GOOS == "windows" {
+		return "java.exe"
+	}
+	return "java"
+}
+
+// javaExecutable returns where the java executable of a runtime directory is
+func javaExecutable(dir string) string {
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(dir, "Contents", "Home", "bin", "java")
+	}
+	return filepath.Join(dir, "bin", javaBinaryName())
+}
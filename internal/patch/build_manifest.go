@@ -0,0 +1,74 @@
+package patch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"HyLauncher/internal/env"
+	"HyLauncher/internal/verify"
+	"HyLauncher/pkg/logger"
+)
+
+// buildManifestLimit caps the size of a downloaded verification manifest
+const buildManifestLimit = 64 << 20
+
+// buildManifestPath is where the verification manifest of a build is cached
+func buildManifestPath(branch string, build int) string {
+	return filepath.Join(env.GetManifestCacheDir(), "verify", branch, fmt.Sprintf("build_%d.json", build))
+}
+
+// FetchBuildManifest returns the path of the official file verification
+// manifest of a build, downloading it from
+// <patches>/verify/<os>/<arch>/<branch>/<build>.json on first use. A build's
+// manifest never changes, so a cached copy that still checks out is used as is.
+func FetchBuildManifest(ctx context.Context, branch string, build int) (string, error) {
+	path := buildManifestPath(branch, build)
+	if _, _, err := verify.LoadTrustedManifest(path, false); err == nil {
+		return path, nil
+	}
+
+	baseURL, err := fetchPatchesConfigWithFallback()
+	if err != nil {
+		return "", fmt.Errorf("get patches URL: %w", err)
+	}
+	url := fmt.Sprintf("%s/verify/%s/%s/%s/%d.json", baseURL, env.GetOS(), env.GetArchForAPI(), branch, build)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	raw, err := fetchRuleAsset(ctx, client, url, buildManifestLimit)
+	if err != nil {
+		return "", fmt.Errorf("download verification manifest: %w", err)
+	}
+
+	sig, err := fetchRuleAsset(ctx, client, url+verify.ManifestSignatureSuffix, 512)
+	if err != nil {
+		return "", fmt.Errorf("download verification manifest signature: %w", err)
+	}
+	if err := verify.VerifyManifestSignature(raw, sig); err != nil {
+		logger.Error("Verification manifest rejected", "url", url, "error", err)
+		return "", fmt.Errorf("verify manifest signature: %w", err)
+	}
+
+	// A signed manifest of another build must not pass for this one
+	var manifest verify.Manifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return "", fmt.Errorf("parse verification manifest: %w", err)
+	}
+	if manifest.Version != strconv.Itoa(build) {
+		return "", fmt.Errorf("verification manifest is for build %q, not %d", manifest.Version, build)
+	}
+
+	if err := writeFileAtomic(path, raw); err != nil {
+		return "", fmt.Errorf("cache verification manifest: %w", err)
+	}
+	if err := writeFileAtomic(path+verify.ManifestSignatureSuffix, sig); err != nil {
+		return "", fmt.Errorf("cache verification manifest signature: %w", err)
+	}
+
+	logger.Info("Fetched verification manifest", "branch", branch, "build", build, "files", len(manifest.Files))
+	return path, nil
+}
@@ -0,0 +1,185 @@
+package patch
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strings"
+	"time"
+
+	"HyLauncher/internal/progress"
+	"HyLauncher/pkg/hyerrors"
+	"HyLauncher/pkg/logger"
+)
+
+const (
+	// Range of StagePatch covered by butler apply; the rest is left for auth patching
+	applyProgressStart = 0.0
+	applyProgressEnd   = 80.0
+
+	butlerLogTail = 20
+)
+
+// butlerEvent is a single line of `butler --json` output
+// {"type":"progress","progress":0.42,"percentage":42,"eta":12.5,"bps":1048576}
+// {"type":"log","level":"info","message":"..."}
+// {"type":"error","message":"..."}
+type butlerEvent struct {
+	Type       string  `json:"type"`
+	Level      string  `json:"level"`
+	Message    string  `json:"message"`
+	Progress   float64 `json:"progress"` // 0-1
+	Percentage float64 `json:"percentage"`
+	ETA        float64 `json:"eta"`
+	BPS        float64 `json:"bps"`
+}
+
+// butlerOutput keeps what we need from the event stream to explain a failure
+type butlerOutput struct {
+	lastError string
+	logs      []string
+}
+
+func (o *butlerOutput) addLog(line string) {
+	o.logs = append(o.logs, line)
+	if len(o.logs) > butlerLogTail {
+		o.logs = o.logs[len(o.logs)-butlerLogTail:]
+	}
+}
+
+// streamButlerEvents reads butler JSON events until r is closed and forwards
+// progress, ETA and log messages to the reporter
+func streamButlerEvents(r io.Reader, reporter *progress.Reporter) *butlerOutput {
+	out := &butlerOutput{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	current := applyProgressStart
+	message := "Applying game patch..."
+	lastReport := time.Time{}
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var ev butlerEvent
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			// Not every line is guaranteed to be JSON (e.g. panics)
+			logger.Debug("butler", "output", line)
+			out.addLog(line)
+			continue
+		}
+
+		switch ev.Type {
+		case "progress":
+			pct := math.Max(0, math.Min(1, ev.Progress))
+			current = applyProgressStart + pct*(applyProgressEnd-applyProgressStart)
+
+			// Butler emits progress very often, throttle like the downloader does
+			if time.Since(lastReport) < 200*time.Millisecond {
+				continue
+			}
+			lastReport = time.Now()
+
+			speed := ""
+			if ev.BPS > 0 {
+				speed = formatApplySpeed(ev.BPS)
+			}
+			eta := ""
+			if ev.ETA > 0 {
+				eta = (time.Duration(ev.ETA) * time.Second).String()
+			}
+			reporter.ReportWithETA(progress.StagePatch, current, message, speed, eta)
+
+		case "log":
+			logger.Info("butler", "level", ev.Level, "message", ev.Message)
+			out.addLog(ev.Message)
+			if ev.Level == "info" && ev.Message != "" {
+				message = ev.Message
+				reporter.Report(progress.StagePatch, current, message)
+			}
+
+		case "error":
+			logger.Error("butler", "message", ev.Message)
+			out.lastError = ev.Message
+			out.addLog(ev.Message)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		logger.Warn("Failed to read butler output", "error", err)
+	}
+
+	return out
+}
+
+// classifyButlerError maps a failed butler run to a structured launcher error
+func classifyButlerError(runErr error, applyCtx context.Context, out *butlerOutput, stderr string) *hyerrors.Error {
+	detail := out.lastError
+	if detail == "" {
+		detail = strings.TrimSpace(stderr)
+	}
+	if detail == "" && len(out.logs) > 0 {
+		detail = out.logs[len(out.logs)-1]
+	}
+
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+
+	text := strings.ToLower(detail + "\n" + stderr)
+
+	var appErr *hyerrors.Error
+	switch {
+	case errors.Is(applyCtx.Err(), context.DeadlineExceeded):
+		appErr = hyerrors.Update("butler apply timed out after 30 minutes - the patch may be too large or stuck")
+	case errors.Is(applyCtx.Err(), context.Canceled):
+		appErr = hyerrors.Update("patch apply canceled")
+	case containsAny(text, "no space left", "not enough space", "disk full", "there is not enough space"):
+		appErr = hyerrors.FileSystem("not enough disk space to apply game patch")
+	case containsAny(text, "permission denied", "access is denied", "being used by another process", "read-only file system"):
+		appErr = hyerrors.FileSystem("game files are locked or not writable")
+	case containsAny(text, "hash mismatch", "corrupt", "unexpected eof", "invalid magic", "wrong magic", "decompress", "integrity"):
+		appErr = hyerrors.New(hyerrors.CategoryValidation, hyerrors.SeverityError, "patch file is corrupted")
+	default:
+		appErr = hyerrors.Update(fmt.Sprintf("butler apply failed with exit code %d", exitCode))
+	}
+
+	appErr.Cause = runErr
+	return appErr.
+		WithDetails(detail).
+		WithContext("exitCode", exitCode).
+		WithContext("butlerLog", strings.Join(out.logs, "\n"))
+}
+
+func containsAny(s string, needles ...string) bool {
+	for _, n := range needles {
+		if strings.Contains(s, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func formatApplySpeed(bytesPerSec float64) string {
+	const unit = 1024
+	if bytesPerSec < unit {
+		return fmt.Sprintf("%.0f B/s", bytesPerSec)
+	}
+	div, exp := float64(unit), 0
+	for n := bytesPerSec / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB/s", bytesPerSec/div, "KMGTPE"[exp])
+}
@@ -0,0 +1,183 @@
+package patch
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Constant pool tags, JVM spec 4.4
+const (
+	cpUtf8               = 1
+	cpInteger            = 3
+	cpFloat              = 4
+	cpLong               = 5
+	cpDouble             = 6
+	cpClass              = 7
+	cpString             = 8
+	cpFieldref           = 9
+	cpMethodref          = 10
+	cpInterfaceMethodref = 11
+	cpNameAndType        = 12
+	cpMethodHandle       = 15
+	cpMethodType         = 16
+	cpDynamic            = 17
+	cpInvokeDynamic      = 18
+	cpModule             = 19
+	cpPackage            = 20
+)
+
+const classMagic = 0xCAFEBABE
+
+// errBadClass is wrapped by errors about malformed class files, as opposed to
+// errors reading them
+var errBadClass = errors.New("malformed class file")
+
+func badClass(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", errBadClass, fmt.Sprintf(format, args...))
+}
+
+// readClass is io.ReadFull where running out of data means a truncated class
+func readClass(r io.Reader, buf []byte, format string, args ...any) error {
+	if _, err := io.ReadFull(r, buf); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return badClass(format, args...)
+		}
+		return err
+	}
+	return nil
+}
+
+// cpEntrySize returns the size of a constant pool entry after its tag, for
+// every tag but Utf8
+func cpEntrySize(tag byte) (int, error) {
+	switch tag {
+	case cpClass, cpString, cpMethodType, cpModule, cpPackage:
+		return 2, nil
+	case cpMethodHandle:
+		return 3, nil
+	case cpInteger, cpFloat, cpFieldref, cpMethodref, cpInterfaceMethodref,
+		cpNameAndType, cpDynamic, cpInvokeDynamic:
+		return 4, nil
+	case cpLong, cpDouble:
+		return 8, nil
+	}
+	return 0, fmt.Errorf("unknown constant pool tag %d", tag)
+}
+
+// RewriteClassConstants applies rewrite to every CONSTANT_Utf8 entry of a
+// class file, along with the entry's offset, and fixes up their length
+// prefixes. Everything after the constant pool refers to it by index, so it is
+// copied unchanged. Returns the number of entries changed.
+func RewriteClassConstants(data []byte, rewrite func(offset int, value []byte) []byte) ([]byte, int, error) {
+	var out bytes.Buffer
+	out.Grow(len(data))
+	changed, err := rewriteClassStream(bytes.NewReader(data), &out, rewrite)
+	if err != nil {
+		return nil, 0, err
+	}
+	return out.Bytes(), changed, nil
+}
+
+// rewriteClassStream is RewriteClassConstants from r to w, holding one
+// constant at a time in memory. Malformed classes give an errBadClass error.
+func rewriteClassStream(r io.Reader, w io.Writer, rewrite func(offset int, value []byte) []byte) (int, error) {
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+
+	head := make([]byte, 10)
+	if err := readClass(br, head, "not a class file"); err != nil {
+		return 0, err
+	}
+	if binary.BigEndian.Uint32(head) != classMagic {
+		return 0, badClass("not a class file")
+	}
+	bw.Write(head)
+
+	count := int(binary.BigEndian.Uint16(head[8:]))
+	changed := 0
+	pos := 10
+	var entry [9]byte
+	for i := 1; i < count; i++ {
+		if err := readClass(br, entry[:1], "truncated constant pool at entry %d", i); err != nil {
+			return 0, err
+		}
+		tag := entry[0]
+
+		if tag != cpUtf8 {
+			size, err := cpEntrySize(tag)
+			if err != nil {
+				return 0, badClass("entry %d: %v", i, err)
+			}
+			if err := readClass(br, entry[1:1+size], "truncated constant pool at entry %d", i); err != nil {
+				return 0, err
+			}
+			bw.Write(entry[:1+size])
+			pos += 1 + size
+			// 8-byte constants take two slots
+			if tag == cpLong || tag == cpDouble {
+				i++
+			}
+			continue
+		}
+
+		if err := readClass(br, entry[1:3], "truncated constant pool at entry %d", i); err != nil {
+			return 0, err
+		}
+		value := make([]byte, binary.BigEndian.Uint16(entry[1:]))
+		if err := readClass(br, value, "truncated utf8 constant at entry %d", i); err != nil {
+			return 0, err
+		}
+		updated := rewrite(pos, value)
+		pos += 3 + len(value)
+
+		if bytes.Equal(updated, value) {
+			bw.Write(entry[:3])
+			bw.Write(value)
+			continue
+		}
+		if len(updated) > 0xFFFF {
+			return 0, badClass("utf8 constant %d too long after patching", i)
+		}
+
+		changed++
+		bw.WriteByte(cpUtf8)
+		bw.Write(binary.BigEndian.AppendUint16(nil, uint16(len(updated))))
+		bw.Write(updated)
+	}
+
+	if _, err := io.Copy(bw, br); err != nil {
+		return 0, err
+	}
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+// toModifiedUTF8 encodes s the way class files store strings: NUL as two
+// bytes and characters outside the BMP as surrogate pairs
+func toModifiedUTF8(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == 0:
+			out = append(out, 0xC0, 0x80)
+		case r > 0xFFFF:
+			r -= 0x10000
+			out = appendCESU(out, 0xD800+(r>>10))
+			out = appendCESU(out, 0xDC00+(r&0x3FF))
+		default:
+			out = utf8.AppendRune(out, r)
+		}
+	}
+	return out
+}
+
+func appendCESU(out []byte, r rune) []byte {
+	return append(out, 0xE0|byte(r>>12), 0x80|byte(r>>6)&0x3F, 0x80|byte(r)&0x3F)
+}
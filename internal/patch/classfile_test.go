@@ -0,0 +1,127 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// classTail stands in for everything after the constant pool, which must be
+// copied through untouched
+var classTail = []byte{0x00, 0x21, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00}
+
+// buildClass lays out a class file whose constant pool holds the class name,
+// a Long (two slots), url as Utf8 and a String pointing at it
+func buildClass(url string) []byte {
+	be := binary.BigEndian
+	b := be.AppendUint32(nil, classMagic)
+	b = be.AppendUint16(b, 0)
+	b = be.AppendUint16(b, 52)
+	b = be.AppendUint16(b, 7)
+
+	utf := func(s string) {
+		b = append(b, cpUtf8)
+		b = be.AppendUint16(b, uint16(len(s)))
+		b = append(b, s...)
+	}
+	utf("com/example/Main")                        // #1
+	b = append(b, cpClass, 0x00, 0x01)             // #2
+	b = append(b, cpLong, 0, 0, 0, 0, 0, 0, 0, 42) // #3, #4
+	utf(url)                                       // #5
+	b = append(b, cpString, 0x00, 0x05)            // #6
+
+	return append(b, classTail...)
+}
+
+func TestRewriteClassConstants(t *testing.T) {
+	tests := []struct {
+		name        string
+		from, to    string
+		wantChanged int
+	}{
+		{"grows", "hytale.com", "play.example-server.org", 1},
+		{"shrinks", "hytale.com", "a.io", 1},
+		{"no match", "example.net", "a.io", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := buildClass("https://sessions.hytale.com/api")
+
+			var offsets []int
+			out, changed, err := RewriteClassConstants(in, func(offset int, value []byte) []byte {
+				offsets = append(offsets, offset)
+				return bytes.ReplaceAll(value, toModifiedUTF8(tt.from), toModifiedUTF8(tt.to))
+			})
+			if err != nil {
+				t.Fatalf("RewriteClassConstants: %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %d, want %d", changed, tt.wantChanged)
+			}
+
+			// Offsets point at the tag of each Utf8 entry in the input
+			for _, off := range offsets {
+				if in[off] != cpUtf8 {
+					t.Errorf("offset %d is not a Utf8 entry", off)
+				}
+			}
+
+			want := buildClass(strings.ReplaceAll("https://sessions.hytale.com/api", tt.from, tt.to))
+			if !bytes.Equal(out, want) {
+				t.Errorf("rewritten class:\n got %x\nwant %x", out, want)
+			}
+		})
+	}
+}
+
+func TestRewriteClassConstantsRejects(t *testing.T) {
+	valid := buildClass("hytale.com")
+	badTag := bytes.Clone(valid)
+	badTag[10] = 2 // no constant pool tag 2
+
+	tests := []struct {
+		name    string
+		data    []byte
+		rewrite func(int, []byte) []byte
+	}{
+		{"not a class", []byte("PK\x03\x04 not a class"), nil},
+		{"truncated", valid[:30], nil},
+		{"unknown tag", badTag, nil},
+		{"too long", valid, func(_ int, v []byte) []byte {
+			if bytes.Equal(v, []byte("hytale.com")) {
+				return bytes.Repeat([]byte("a"), 0x10000)
+			}
+			return v
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewrite := tt.rewrite
+			if rewrite == nil {
+				rewrite = func(_ int, v []byte) []byte { return v }
+			}
+			if _, _, err := RewriteClassConstants(tt.data, rewrite); !errors.Is(err, errBadClass) {
+				t.Fatalf("got %v, want errBadClass", err)
+			}
+		})
+	}
+}
+
+func TestToModifiedUTF8(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []byte
+	}{
+		{"hytale.com", []byte("hytale.com")},
+		{"a\x00b", []byte{'a', 0xC0, 0x80, 'b'}},
+		{"é", []byte{0xC3, 0xA9}},
+		{"\U0001F600", []byte{0xED, 0xA0, 0xBD, 0xED, 0xB8, 0x80}},
+	}
+	for _, tt := range tests {
+		if got := toModifiedUTF8(tt.in); !bytes.Equal(got, tt.want) {
+			t.Errorf("toModifiedUTF8(%q) = %x, want %x", tt.in, got, tt.want)
+		}
+	}
+}
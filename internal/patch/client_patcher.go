@@ -2,14 +2,22 @@ package patch
 
 import (
 	"archive/zip"
+	"bufio"
 	"bytes"
+	"compress/flate"
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf16"
 
 	"HyLauncher/internal/config"
 	"HyLauncher/internal/env"
@@ -23,17 +31,23 @@ import (
 const (
 	originalDomain  = "hytale.com"
 	minDomainLength = 4
-	maxDomainLength = 15
+	maxDomainLength = 253
 )
 
+// DefaultTargetDomain is the auth domain the launcher points the game at
+const DefaultTargetDomain = "porkln.fun"
+
 var defaultNewDomain = config.GetPatchDomain()
 
 type ClientPatcher struct {
 	targetDomain string
+
+	// DryRun finds and reports replacements without writing any file
+	DryRun bool
 }
 
 func NewClientPatcher(targetDomain string) *ClientPatcher {
-	if len(targetDomain) < minDomainLength || len(targetDomain) > maxDomainLength {
+	if !validDomain(targetDomain) {
 		logger.Warn("Invalid domain, using default",
 			"domain", targetDomain,
 			"min", minDomainLength,
@@ -46,6 +60,24 @@ func NewClientPatcher(targetDomain string) *ClientPatcher {
 	}
 }
 
+// validDomain reports whether domain is a hostname the client can be pointed at
+func validDomain(domain string) bool {
+	if len(domain) < minDomainLength || len(domain) > maxDomainLength {
+		return false
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // StringToLengthPrefixed converts a string to length-prefixed byte format
 // Format: [length byte] [00 00 00 padding] [char1] [00] [char2] [00] ... [lastChar]
 // Note: No null byte after the last character
@@ -102,11 +134,16 @@ func (cp *ClientPatcher) FindAllOccurrences(data, pattern []byte) []int {
 
 // ReplaceBytes replaces all occurrences of oldBytes with newBytes
 func (cp *ClientPatcher) ReplaceBytes(data, oldBytes, newBytes []byte) ([]byte, int) {
+	result, positions := cp.replaceBytes(data, oldBytes, newBytes)
+	return result, len(positions)
+}
+
+func (cp *ClientPatcher) replaceBytes(data, oldBytes, newBytes []byte) ([]byte, []int) {
 	if len(newBytes) > len(oldBytes) {
 		logger.Warn("New pattern longer than old, skipping",
 			"newLen", len(newBytes),
 			"oldLen", len(oldBytes))
-		return data, 0
+		return data, nil
 	}
 
 	result := make([]byte, len(data))
@@ -121,42 +158,49 @@ func (cp *ClientPatcher) ReplaceBytes(data, oldBytes, newBytes []byte) ([]byte,
 		}
 	}
 
-	return result, len(positions)
+	return result, positions
 }
 
 // FindAndReplaceDomainUTF8 replaces domain in UTF-8 format (for Java JARs)
 func (cp *ClientPatcher) FindAndReplaceDomainUTF8(data []byte, oldDomain, newDomain string) ([]byte, int) {
+	result, positions := cp.replaceUTF8(data, oldDomain, newDomain)
+	return result, len(positions)
+}
+
+func (cp *ClientPatcher) replaceUTF8(data []byte, oldDomain, newDomain string) ([]byte, []int) {
 	result := make([]byte, len(data))
 	copy(result, data)
 
 	oldUtf8 := cp.StringToUTF8(oldDomain)
 	newUtf8 := cp.StringToUTF8(newDomain)
 
-	positions := cp.FindAllOccurrences(result, oldUtf8)
-	count := 0
-
-	for _, pos := range positions {
+	var patched []int
+	for _, pos := range cp.FindAllOccurrences(result, oldUtf8) {
 		if pos+len(oldUtf8) <= len(result) && len(newUtf8) <= len(oldUtf8) {
 			copy(result[pos:], newUtf8)
 			for i := len(newUtf8); i < len(oldUtf8); i++ {
 				result[pos+i] = 0x00
 			}
-			count++
-			fmt.Printf("  Patched UTF-8 occurrence at offset 0x%x\n", pos)
+			patched = append(patched, pos)
 		}
 	}
 
-	return result, count
+	return result, patched
 }
 
 // FindAndReplaceDomainSmart handles both null-terminated and length-prefixed UTF-16LE strings
 func (cp *ClientPatcher) FindAndReplaceDomainSmart(data []byte, oldDomain, newDomain string) ([]byte, int) {
+	result, positions := cp.replaceUTF16(data, oldDomain, newDomain)
+	return result, len(positions)
+}
+
+func (cp *ClientPatcher) replaceUTF16(data []byte, oldDomain, newDomain string) ([]byte, []int) {
 	result := make([]byte, len(data))
 	copy(result, data)
 
 	if len(newDomain) > len(oldDomain) {
-		fmt.Printf("  Skipping UTF-16LE patch: new domain too long (%d > %d)\n", len(newDomain), len(oldDomain))
-		return result, 0
+		logger.Debug("Skipping in-place UTF-16LE patch, new string too long", "new", len(newDomain), "old", len(oldDomain))
+		return result, nil
 	}
 
 	oldUtf16NoLast := cp.StringToUTF16LE(oldDomain[:len(oldDomain)-1])
@@ -164,10 +208,8 @@ func (cp *ClientPatcher) FindAndReplaceDomainSmart(data []byte, oldDomain, newDo
 	oldLastCharByte := byte(oldDomain[len(oldDomain)-1])
 	newLastCharByte := byte(newDomain[len(newDomain)-1])
 
-	positions := cp.FindAllOccurrences(result, oldUtf16NoLast)
-	count := 0
-
-	for _, pos := range positions {
+	var patched []int
+	for _, pos := range cp.FindAllOccurrences(result, oldUtf16NoLast) {
 		lastCharPos := pos + len(oldUtf16NoLast)
 		if lastCharPos+1 > len(result) {
 			continue
@@ -188,75 +230,97 @@ func (cp *ClientPatcher) FindAndReplaceDomainSmart(data []byte, oldDomain, newDo
 			}
 		}
 
-		if lastCharPos+1 < len(result) && result[lastCharPos+1] == 0x00 {
-			fmt.Printf("  Patched UTF-16LE occurrence at offset 0x%x\n", pos)
-		} else {
-			fmt.Printf("  Patched length-prefixed occurrence at offset 0x%x\n", pos)
-		}
-		count++
+		patched = append(patched, pos)
 	}
 
-	return result, count
+	return result, patched
 }
 
-// ApplyDomainPatches applies all domain patches to binary data
-func (cp *ClientPatcher) ApplyDomainPatches(data []byte, protocol string) ([]byte, int) {
-	result := make([]byte, len(data))
-	copy(result, data)
-	totalCount := 0
-
-	fmt.Printf("  Replacing %s -> %s\n", originalDomain, cp.targetDomain)
+// ApplyDomainPatches applies the given rules to binary data in order and
+// returns every replacement made. String literals of managed images are
+// rewritten through the metadata, so replacements may be longer than the
+// original. If a byte-level match remains that the replacement doesn't fit,
+// data is returned unchanged.
+func (cp *ClientPatcher) ApplyDomainPatches(data []byte, rules []PatchRule) ([]byte, []PatchChange) {
+	var out bytes.Buffer
+	out.Grow(len(data))
 
-	var count int
-
-	// Patch sentry URL
-	oldSentry := "https://ca900df42fcf57d4dd8401a86ddd7da2@sentry.hytale.com/2"
-	newSentry := fmt.Sprintf("%st@%s/2", protocol, cp.targetDomain)
-	result, count = cp.ReplaceBytes(result, cp.StringToLengthPrefixed(oldSentry), cp.StringToLengthPrefixed(newSentry))
-	if count > 0 {
-		fmt.Printf("  Patched sentry URL: %d occurrences\n", count)
-		totalCount += count
+	changes, err := cp.patchImage(bytes.NewReader(data), int64(len(data)), &out, rules)
+	if err != nil {
+		logger.Error("Patching in memory failed", "error", err)
+		return data, nil
 	}
+	return out.Bytes(), changes
+}
+
+// rewriteUserString applies the rules to a string literal. Length-prefixed
+// rules replace whole literals, UTF-16LE rules replace substrings.
+func (cp *ClientPatcher) rewriteUserString(offset int, str string, rules []PatchRule) (string, []PatchChange) {
+	var changes []PatchChange
+	for _, rule := range rules {
+		replacement := strings.ReplaceAll(rule.Replacement, "{domain}", cp.targetDomain)
+
+		n := 0
+		switch rule.Encoding {
+		case EncodingLengthPrefixed:
+			if str == rule.Pattern {
+				n = 1
+			}
+		case EncodingUTF16LE:
+			n = strings.Count(str, rule.Pattern)
+		}
+		if n == 0 {
+			continue
+		}
 
-	// Patch full URLs with subdomains
-	subdomains := []string{"", "sessions.", "account-data.", "gameservers."}
-	for _, sub := range subdomains {
-		oldUrl := fmt.Sprintf("%s%s%s", protocol, sub, originalDomain)
-		newUrl := fmt.Sprintf("%s%s", protocol, cp.targetDomain)
-		result, count = cp.ReplaceBytes(result, cp.StringToLengthPrefixed(oldUrl), cp.StringToLengthPrefixed(newUrl))
-		if count > 0 {
-			fmt.Printf("  Patched URL %s: %d occurrences\n", oldUrl, count)
-			totalCount += count
+		updated := replacement
+		if rule.Encoding == EncodingUTF16LE {
+			updated = strings.ReplaceAll(str, rule.Pattern, replacement)
 		}
+		changes = append(changes, newChange(rule, "", offset, encodeUTF16(str), encodeUTF16(updated), n))
+		str = updated
 	}
+	return str, changes
+}
 
-	// Patch bare domain
-	result, count = cp.ReplaceBytes(result, cp.StringToLengthPrefixed(originalDomain), cp.StringToLengthPrefixed(cp.targetDomain))
-	if count > 0 {
-		fmt.Printf("  Patched bare domain: %d occurrences\n", count)
-		totalCount += count
+func encodeUTF16(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	out := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		out = binary.LittleEndian.AppendUint16(out, u)
 	}
+	return out
+}
 
-	// Patch UTF-16LE strings
-	result, count = cp.FindAndReplaceDomainSmart(result, originalDomain, cp.targetDomain)
-	if count > 0 {
-		fmt.Printf("  Patched UTF-16LE: %d occurrences\n", count)
-		totalCount += count
+func totalMatches(changes []PatchChange) int {
+	total := 0
+	for _, c := range changes {
+		total += c.Matches
 	}
+	return total
+}
 
-	return result, totalCount
+// PatchClient patches the client binary. In dry-run mode the changes are
+// reported but nothing is written.
+func (cp *ClientPatcher) PatchClient(clientPath string, rules []PatchRule, reporter *progress.Reporter) (*FileReport, error) {
+	return cp.patchClient(clientPath, clientPath, rules, reporter)
 }
 
-// PatchClient patches the client binary
-func (cp *ClientPatcher) PatchClient(clientPath string, reporter *progress.Reporter) error {
-	logger.Info("Patching client", "path", clientPath, "domain", cp.targetDomain)
+// patchClient writes the patched bytes of source to clientPath. When source
+// is the backup the result is written even without matches, so a client
+// patched for another domain goes back to the original strings.
+func (cp *ClientPatcher) patchClient(clientPath, source string, rules []PatchRule, reporter *progress.Reporter) (*FileReport, error) {
+	logger.Info("Patching client", "path", clientPath, "source", source, "domain", cp.targetDomain, "rules", len(rules), "dryRun", cp.DryRun)
+
+	report := &FileReport{Path: clientPath, Target: RuleTargetClient, FromBackup: source != clientPath}
 
 	if !fileutil.FileExists(clientPath) {
-		return fmt.Errorf("client binary not found: %s", clientPath)
+		return report, fmt.Errorf("client binary not found: %s", clientPath)
 	}
 
-	// On macOS, remove code signature before patching
-	if runtime.GOOS == "darwin" {
+	// On macOS, remove code signature before patching. The backup was
+	// unsigned when it was made.
+	if runtime.GOOS == "darwin" && !cp.DryRun && !report.FromBackup {
 		if reporter != nil {
 			reporter.Report(progress.StagePatch, 5, "Removing code signature...")
 		}
@@ -269,38 +333,78 @@ func (cp *ClientPatcher) PatchClient(clientPath string, reporter *progress.Repor
 		reporter.Report(progress.StagePatch, 10, "Reading client binary...")
 	}
 
-	data, err := os.ReadFile(clientPath)
+	src, err := os.Open(source)
+	if err != nil {
+		return report, fmt.Errorf("failed to read client: %w", err)
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to read client: %w", err)
+		return report, fmt.Errorf("failed to read client: %w", err)
+	}
+
+	// The patched binary is streamed to a temp file next to the client, so
+	// memory use does not depend on the binary size
+	tempPath := clientPath + ".tmp"
+	var out io.Writer = io.Discard
+	var tempFile *os.File
+	if !cp.DryRun {
+		tempFile, err = os.OpenFile(tempPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0755)
+		if err != nil {
+			return report, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		defer os.Remove(tempPath)
+		defer tempFile.Close()
+		out = tempFile
 	}
 
 	if reporter != nil {
 		reporter.Report(progress.StagePatch, 30, "Applying patches...")
 	}
 
-	patchedData, count := cp.ApplyDomainPatches(data, "https://")
+	bw := bufio.NewWriterSize(out, streamChunkSize)
+	changes, err := cp.patchImage(src, info.Size(), bw, rules)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to patch client: %w", err)
+	}
+	report.Changes = changes
+	count := totalMatches(changes)
 
-	if count == 0 {
+	if count == 0 && !report.FromBackup {
 		logger.Info("No patches applied", "reason", "already patched or no matches")
-		return nil
+		return report, nil
+	}
+	if cp.DryRun {
+		logger.Info("Dry run, client left unchanged", "occurrences", count)
+		return report, nil
 	}
 
 	if reporter != nil {
 		reporter.Report(progress.StagePatch, 70, "Writing patched binary...")
 	}
 
+	if err := tempFile.Close(); err != nil {
+		return report, fmt.Errorf("failed to write patched client: %w", err)
+	}
+	src.Close()
+
 	// Backup original
 	backupPath := clientPath + ".original"
 	if !fileutil.FileExists(backupPath) {
 		if err := os.Rename(clientPath, backupPath); err != nil {
-			return fmt.Errorf("failed to backup original: %w", err)
+			return report, fmt.Errorf("failed to backup original: %w", err)
 		}
 	}
 
-	// Write patched version
-	if err := os.WriteFile(clientPath, patchedData, 0755); err != nil {
-		return fmt.Errorf("failed to write patched client: %w", err)
+	// Move patched version in place
+	if err := os.Rename(tempPath, clientPath); err != nil {
+		return report, fmt.Errorf("failed to write patched client: %w", err)
 	}
+	report.Written = true
 
 	// On macOS, re-sign with ad-hoc signature after patching
 	if runtime.GOOS == "darwin" {
@@ -319,39 +423,56 @@ func (cp *ClientPatcher) PatchClient(clientPath string, reporter *progress.Repor
 	}
 
 	logger.Info("Client patched successfully", "occurrences", count)
-	return nil
+	return report, nil
+}
+
+// PatchServer patches the server JAR file. In dry-run mode the changes are
+// reported but nothing is written.
+func (cp *ClientPatcher) PatchServer(serverPath string, rules []PatchRule, reporter *progress.Reporter) (*FileReport, error) {
+	return cp.patchServer(serverPath, serverPath, rules, reporter)
 }
 
-// PatchServer patches the server JAR file
-func (cp *ClientPatcher) PatchServer(serverPath string, reporter *progress.Reporter) error {
-	logger.Info("Patching server", "path", serverPath, "domain", cp.targetDomain)
+// patchServer writes the patched entries of source to serverPath, see patchClient
+func (cp *ClientPatcher) patchServer(serverPath, source string, rules []PatchRule, reporter *progress.Reporter) (*FileReport, error) {
+	logger.Info("Patching server", "path", serverPath, "source", source, "domain", cp.targetDomain, "rules", len(rules), "dryRun", cp.DryRun)
+
+	report := &FileReport{Path: serverPath, Target: RuleTargetServer, FromBackup: source != serverPath}
 
 	if !fileutil.FileExists(serverPath) {
-		return fmt.Errorf("server JAR not found: %s", serverPath)
+		return report, fmt.Errorf("server JAR not found: %s", serverPath)
 	}
 
 	if reporter != nil {
 		reporter.Report(progress.StagePatch, 10, "Opening server JAR...")
 	}
 
-	zipReader, err := zip.OpenReader(serverPath)
+	zipReader, err := zip.OpenReader(source)
 	if err != nil {
-		return fmt.Errorf("failed to open JAR: %w", err)
+		return report, fmt.Errorf("failed to open JAR: %w", err)
 	}
 	defer zipReader.Close()
 
 	tempPath := serverPath + ".tmp"
-	tempFile, err := os.Create(tempPath)
-	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
-	}
-	defer tempFile.Close()
+	var tempFile *os.File
+	var zipWriter *zip.Writer
+	if !cp.DryRun {
+		tempFile, err = os.Create(tempPath)
+		if err != nil {
+			return report, fmt.Errorf("failed to create temp file: %w", err)
+		}
+		defer tempFile.Close()
 
-	zipWriter := zip.NewWriter(tempFile)
-	defer zipWriter.Close()
+		zipWriter = zip.NewWriter(tempFile)
+		defer zipWriter.Close()
+	}
 
-	totalCount := 0
-	oldUtf8 := cp.StringToUTF8(originalDomain)
+	// copyEntry keeps the original compressed bytes of unchanged entries
+	copyEntry := func(entry *zip.File) error {
+		if zipWriter == nil {
+			return nil
+		}
+		return zipWriter.Copy(entry)
+	}
 
 	if reporter != nil {
 		reporter.Report(progress.StagePatch, 30, "Patching JAR entries...")
@@ -363,64 +484,75 @@ func (cp *ClientPatcher) PatchServer(serverPath string, reporter *progress.Repor
 			reporter.Report(progress.StagePatch, float64(pct), "Patching JAR entries...")
 		}
 
-		rc, err := entry.Open()
-		if err != nil {
-			return fmt.Errorf("failed to read entry %s: %w", entry.Name, err)
+		if !isPatchableEntry(entry.Name) {
+			if err := copyEntry(entry); err != nil {
+				return report, fmt.Errorf("failed to copy entry %s: %w", entry.Name, err)
+			}
+			continue
 		}
 
-		data, err := io.ReadAll(rc)
-		rc.Close()
-		if err != nil {
-			return fmt.Errorf("failed to read entry data %s: %w", entry.Name, err)
+		// First pass finds the changes and measures the patched entry
+		crc := crc32.NewIEEE()
+		size := &countWriter{}
+		changes, err := cp.patchEntry(entry, io.MultiWriter(crc, size), rules)
+		if errors.Is(err, errBadClass) {
+			logger.Warn("Skipping unreadable class file", "entry", entry.Name, "error", err)
+			changes = nil
+		} else if err != nil {
+			return report, fmt.Errorf("failed to read entry %s: %w", entry.Name, err)
 		}
 
-		shouldPatch := strings.HasSuffix(entry.Name, ".class") ||
-			strings.HasSuffix(entry.Name, ".properties") ||
-			strings.HasSuffix(entry.Name, ".json") ||
-			strings.HasSuffix(entry.Name, ".xml") ||
-			strings.HasSuffix(entry.Name, ".yml")
-
-		if shouldPatch && bytes.Contains(data, oldUtf8) {
-			patchedData, count := cp.FindAndReplaceDomainUTF8(data, originalDomain, cp.targetDomain)
-			if count > 0 {
-				data = patchedData
-				totalCount += count
+		if len(changes) == 0 {
+			if err := copyEntry(entry); err != nil {
+				return report, fmt.Errorf("failed to copy entry %s: %w", entry.Name, err)
 			}
+			continue
 		}
 
-		writer, err := zipWriter.CreateHeader(&entry.FileHeader)
-		if err != nil {
-			return fmt.Errorf("failed to create entry %s: %w", entry.Name, err)
+		report.Changes = append(report.Changes, changes...)
+		if zipWriter == nil {
+			continue
 		}
-		if _, err := writer.Write(data); err != nil {
-			return fmt.Errorf("failed to write entry %s: %w", entry.Name, err)
+		err = writeJarEntry(zipWriter, &entry.FileHeader, crc.Sum32(), size.n, func(w io.Writer) error {
+			_, err := cp.patchEntry(entry, w, rules)
+			return err
+		})
+		if err != nil {
+			return report, fmt.Errorf("failed to write entry %s: %w", entry.Name, err)
 		}
 	}
 
+	totalCount := totalMatches(report.Changes)
+	if cp.DryRun {
+		logger.Info("Dry run, server left unchanged", "occurrences", totalCount)
+		return report, nil
+	}
+
 	if reporter != nil {
 		reporter.Report(progress.StagePatch, 80, "Finalizing patched JAR...")
 	}
 
 	if err := zipWriter.Close(); err != nil {
-		return fmt.Errorf("failed to close ZIP writer: %w", err)
+		return report, fmt.Errorf("failed to close ZIP writer: %w", err)
 	}
 	tempFile.Close()
 	zipReader.Close()
 
-	if totalCount > 0 {
+	if totalCount > 0 || report.FromBackup {
 		backupPath := serverPath + ".original"
 		if !fileutil.FileExists(backupPath) {
 			if err := os.Rename(serverPath, backupPath); err != nil {
 				os.Remove(tempPath)
-				return fmt.Errorf("failed to backup original: %w", err)
+				return report, fmt.Errorf("failed to backup original: %w", err)
 			}
 		} else {
 			os.Remove(serverPath)
 		}
 
 		if err := os.Rename(tempPath, serverPath); err != nil {
-			return fmt.Errorf("failed to replace with patched version: %w", err)
+			return report, fmt.Errorf("failed to replace with patched version: %w", err)
 		}
+		report.Written = true
 	} else {
 		os.Remove(tempPath)
 		logger.Info("No patches applied to server", "reason", "already patched or no matches")
@@ -431,46 +563,324 @@ func (cp *ClientPatcher) PatchServer(serverPath string, reporter *progress.Repor
 	}
 
 	logger.Info("Server patched successfully", "occurrences", totalCount)
-	return nil
+	return report, nil
 }
 
-// EnsureGamePatched ensures both client and server are patched
-func EnsureGamePatched(ctx context.Context, request model.InstanceModel, targetDomain string, reporter *progress.Reporter) error {
-	patcher := NewClientPatcher(targetDomain)
+func isPatchableEntry(name string) bool {
+	return strings.HasSuffix(name, ".class") ||
+		strings.HasSuffix(name, ".properties") ||
+		strings.HasSuffix(name, ".json") ||
+		strings.HasSuffix(name, ".xml") ||
+		strings.HasSuffix(name, ".yml")
+}
 
-	// Patch client
-	clientPath := env.GetGameClientPath(request.Branch, request.BuildVersion)
-	if clientPath != "" {
-		if reporter != nil {
-			reporter.Report(progress.StagePatch, 0, "Patching client binary...")
+// patchEntry streams a JAR entry through the rules into w
+func (cp *ClientPatcher) patchEntry(entry *zip.File, w io.Writer, rules []PatchRule) ([]PatchChange, error) {
+	rc, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return cp.patchJarEntry(entry.Name, rc, w, rules)
+}
+
+// patchJarEntry streams a JAR entry through the UTF-8 rules. Class files are
+// patched through their constant pool, other entries are text.
+func (cp *ClientPatcher) patchJarEntry(name string, r io.Reader, w io.Writer, rules []PatchRule) ([]PatchChange, error) {
+	if !strings.HasSuffix(name, ".class") {
+		var stages []*ruleWriter
+		for _, rule := range rules {
+			if rule.Encoding != EncodingUTF8 {
+				continue
+			}
+			stages = append(stages, &ruleWriter{
+				rule:  rule,
+				entry: name,
+				old:   []byte(rule.Pattern),
+				new:   []byte(strings.ReplaceAll(rule.Replacement, "{domain}", cp.targetDomain)),
+			})
+		}
+		if _, err := io.Copy(chainRules(w, stages), r); err != nil {
+			return nil, err
 		}
+		return closeRules(stages)
+	}
 
-		if err := patcher.PatchClient(clientPath, reporter); err != nil {
-			return fmt.Errorf("failed to patch client: %w", err)
+	var changes []PatchChange
+	_, err := rewriteClassStream(r, w, func(offset int, value []byte) []byte {
+		for _, rule := range rules {
+			if rule.Encoding != EncodingUTF8 {
+				continue
+			}
+			replacement := strings.ReplaceAll(rule.Replacement, "{domain}", cp.targetDomain)
+			pattern := toModifiedUTF8(rule.Pattern)
+
+			if n := bytes.Count(value, pattern); n > 0 {
+				updated := bytes.ReplaceAll(value, pattern, toModifiedUTF8(replacement))
+				changes = append(changes, newChange(rule, name, offset, value, updated, n))
+				value = updated
+			}
 		}
-	} else {
-		logger.Warn("Client binary not found, skipping client patch")
+		return value
+	})
+	if err != nil {
+		return nil, err
 	}
+	return changes, nil
+}
 
-	// Patch server
-	serverPath := env.GetServerPath(request.Branch, request.BuildVersion)
-	if serverPath != "" {
-		if reporter != nil {
-			reporter.Report(progress.StagePatch, 50, "Patching server JAR...")
+// writeJarEntry writes a modified entry with the original header, keeping
+// its compression method and timestamps. Sizes go in the local header so
+// stored entries stay readable by streaming JAR readers. crc and size are of
+// the patched content, which content writes again on every pass: deflated
+// entries take one pass to measure the compressed size and one to write.
+func writeJarEntry(zw *zip.Writer, orig *zip.FileHeader, crc uint32, size int64, content func(io.Writer) error) error {
+	fh := *orig
+	fh.Flags &^= 0x8
+	fh.CRC32 = crc
+	fh.UncompressedSize64 = uint64(size)
+
+	switch fh.Method {
+	case zip.Store:
+		fh.CompressedSize64 = fh.UncompressedSize64
+	case zip.Deflate:
+		compressed := &countWriter{}
+		if err := deflateTo(compressed, content); err != nil {
+			return err
+		}
+		fh.CompressedSize64 = uint64(compressed.n)
+	default:
+		return fmt.Errorf("unsupported compression method %d", fh.Method)
+	}
+
+	w, err := zw.CreateRaw(&fh)
+	if err != nil {
+		return err
+	}
+	if fh.Method == zip.Store {
+		return content(w)
+	}
+	return deflateTo(w, content)
+}
+
+func deflateTo(w io.Writer, content func(io.Writer) error) error {
+	fw, err := flate.NewWriter(w, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	if err := content(fw); err != nil {
+		return err
+	}
+	return fw.Close()
+}
+
+// InstalledBuild returns the build number of an install, 0 when unknown
+func InstalledBuild(request model.InstanceModel) int {
+	if build, err := strconv.Atoi(request.BuildVersion); err == nil {
+		return build
+	}
+
+	data, err := os.ReadFile(filepath.Join(env.GetGameDir(request.Branch, request.BuildVersion), ".version"))
+	if err != nil {
+		return 0
+	}
+	build, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return build
+}
+
+// PatchOptions controls a patch run
+type PatchOptions struct {
+	// DryRun reports what would change without writing anything
+	DryRun bool
+}
+
+// EnsureGamePatched ensures both client and server are patched with the rules
+// for the installed build
+func EnsureGamePatched(ctx context.Context, request model.InstanceModel, targetDomain string, reporter *progress.Reporter) (*PatchReport, error) {
+	return PatchGame(ctx, request, targetDomain, PatchOptions{}, reporter)
+}
+
+// PatchGame patches client and server and saves a report of every change
+// next to the instance logs. Files whose hash, domain and rule version match
+// the install's patch state are left alone.
+func PatchGame(ctx context.Context, request model.InstanceModel, targetDomain string, opts PatchOptions, reporter *progress.Reporter) (*PatchReport, error) {
+	patcher := NewClientPatcher(targetDomain)
+	patcher.DryRun = opts.DryRun
+	ruleSet := LoadPatchRules(ctx)
+
+	report := &PatchReport{
+		Branch:       request.Branch,
+		Version:      request.BuildVersion,
+		Build:        InstalledBuild(request),
+		Domain:       patcher.targetDomain,
+		DryRun:       opts.DryRun,
+		CreatedAt:    time.Now(),
+		RulesVersion: ruleSet.Version,
+		RulesSource:  ruleSet.Source,
+	}
+	for _, rule := range ruleSet.Rules {
+		if !rule.AppliesTo(report.Build) {
+			report.Skipped = append(report.Skipped, rule.ID)
 		}
+	}
+
+	state := LoadPatchState(request)
 
-		if err := patcher.PatchServer(serverPath, reporter); err != nil {
-			return fmt.Errorf("failed to patch server: %w", err)
+	var applied []PatchRule
+	patchErr := func() error {
+		// Patch client
+		clientPath := env.GetGameClientPath(request.Branch, request.BuildVersion)
+		if clientPath != "" {
+			if reporter != nil {
+				reporter.Report(progress.StagePatch, 0, "Patching client binary...")
+			}
+
+			rules := ruleSet.ForBuild(RuleTargetClient, report.Build)
+			applied = append(applied, rules...)
+			file, err := patcher.patchFile(RuleTargetClient, clientPath, rules, ruleSet.Version, state, reporter)
+			report.addFile(file, err)
+			if err != nil {
+				return fmt.Errorf("failed to patch client: %w", err)
+			}
+		} else {
+			logger.Warn("Client binary not found, skipping client patch")
 		}
+
+		// Patch server
+		serverPath := env.GetServerPath(request.Branch, request.BuildVersion)
+		if serverPath != "" {
+			if reporter != nil {
+				reporter.Report(progress.StagePatch, 50, "Patching server JAR...")
+			}
+
+			rules := ruleSet.ForBuild(RuleTargetServer, report.Build)
+			applied = append(applied, rules...)
+			file, err := patcher.patchFile(RuleTargetServer, serverPath, rules, ruleSet.Version, state, reporter)
+			report.addFile(file, err)
+			if err != nil {
+				return fmt.Errorf("failed to patch server: %w", err)
+			}
+		} else {
+			logger.Warn("Server JAR not found, skipping server patch")
+		}
+		return nil
+	}()
+
+	var changes []PatchChange
+	for _, f := range report.Files {
+		changes = append(changes, f.Changes...)
+	}
+	report.Rules = ruleResults(applied, changes)
+
+	if !opts.DryRun {
+		if err := savePatchState(request, state); err != nil {
+			logger.Warn("Failed to save patch state", "error", err)
+		}
+	}
+
+	if path, err := SavePatchReport(report, request.InstanceID); err != nil {
+		logger.Warn("Failed to save patch report", "error", err)
 	} else {
-		logger.Warn("Server JAR not found, skipping server patch")
+		logger.Info("Patch report saved", "path", path)
 	}
 
+	if patchErr != nil {
+		return report, patchErr
+	}
+
+	logger.Info("Patch rules applied",
+		"build", report.Build,
+		"rulesVersion", report.RulesVersion,
+		"source", report.RulesSource,
+		"matched", report.Matched(),
+		"skipped", report.Skipped,
+		"dryRun", report.DryRun)
+
 	if reporter != nil {
 		reporter.Report(progress.StagePatch, 100, "Game patching complete")
 	}
 
-	return nil
+	return report, nil
+}
+
+// patchFile brings one file in line with the patch state. A file still
+// matching its recorded patched hash is skipped, or re-patched from the
+// backup when the domain or rules changed. Any other file is unpatched, and
+// a backup that doesn't match it was left behind by an update.
+func (cp *ClientPatcher) patchFile(target, path string, rules []PatchRule, rulesVersion int, state *PatchState, reporter *progress.Reporter) (*FileReport, error) {
+	report := &FileReport{Path: path, Target: target}
+	backupPath := path + ".original"
+	prev, known := state.Files[target]
+
+	sum, err := hashFile(path)
+	if err != nil {
+		return report, fmt.Errorf("failed to hash %s: %w", target, err)
+	}
+
+	source := path
+	switch {
+	case known && sum == prev.PatchedSHA256 && prev.Domain == cp.targetDomain && prev.RulesVersion == rulesVersion:
+		logger.Info("Already patched, skipping", "target", target, "domain", prev.Domain, "rulesVersion", prev.RulesVersion)
+		report.UpToDate = true
+		return report, nil
+
+	case known && sum == prev.PatchedSHA256 && prev.OriginalSHA256 != prev.PatchedSHA256:
+		backupSum, _ := hashFile(backupPath)
+		if prev.OriginalSHA256 == "" || backupSum != prev.OriginalSHA256 {
+			return report, fmt.Errorf("backup of the original %s is missing or unverified, repair the install to re-patch", target)
+		}
+		logger.Info("Re-patching from backup", "target", target, "domain", cp.targetDomain, "previousDomain", prev.Domain)
+		source = backupPath
+
+	case known && fileutil.FileExists(backupPath):
+		if backupSum, _ := hashFile(backupPath); backupSum != sum && !cp.DryRun {
+			logger.Info("Discarding stale backup, file was replaced by an update", "target", target, "path", backupPath)
+			if err := os.Remove(backupPath); err != nil {
+				return report, fmt.Errorf("failed to remove stale backup: %w", err)
+			}
+		}
+	}
+
+	hadBackup := fileutil.FileExists(backupPath)
+	if target == RuleTargetClient {
+		report, err = cp.patchClient(path, source, rules, reporter)
+	} else {
+		report, err = cp.patchServer(path, source, rules, reporter)
+	}
+	if err != nil || cp.DryRun {
+		return report, err
+	}
+
+	entry := FilePatchState{
+		Domain:       cp.targetDomain,
+		RulesVersion: rulesVersion,
+		PatchedAt:    time.Now(),
+	}
+	if entry.PatchedSHA256, err = hashFile(path); err != nil {
+		return report, fmt.Errorf("failed to hash patched %s: %w", target, err)
+	}
+
+	switch {
+	case !fileutil.FileExists(backupPath):
+		entry.OriginalSHA256 = entry.PatchedSHA256
+	case known || !hadBackup:
+		entry.OriginalSHA256, _ = hashFile(backupPath)
+	default:
+		logger.Warn("Backup predates patch tracking, original left unverified", "target", target, "path", backupPath)
+	}
+	state.Files[target] = entry
+
+	return report, nil
+}
+
+func (r *PatchReport) addFile(file *FileReport, err error) {
+	if file == nil {
+		return
+	}
+	if err != nil {
+		file.Error = err.Error()
+	}
+	r.Files = append(r.Files, *file)
 }
 
 func RestoreOriginalGame(request model.InstanceModel) error {
@@ -506,6 +916,10 @@ func RestoreOriginalGame(request model.InstanceModel) error {
 		}
 	}
 
+	if err := os.Remove(patchStatePath(request)); err != nil && !os.IsNotExist(err) {
+		logger.Warn("Failed to remove patch state", "error", err)
+	}
+
 	if restored == 0 {
 		return fmt.Errorf("no backups found to restore")
 	}
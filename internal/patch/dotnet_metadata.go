@@ -0,0 +1,875 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"unicode/utf16"
+
+	"HyLauncher/pkg/logger"
+)
+
+// Rewriting user strings in a managed PE image.
+//
+// String literals of a .NET assembly live in the #US metadata heap and IL
+// refers to them with ldstr tokens (0x70 << 24 | heap offset). To change a
+// literal's length the heap is rebuilt, every ldstr operand is remapped to the
+// new offsets, and when the heap grew the whole metadata block is moved to the
+// end of the image. The image is read through an io.ReaderAt and the result
+// is described as an imageEdit, so only headers, metadata and one method body
+// at a time are held in memory.
+
+var errNotManaged = errors.New("not a managed PE image")
+
+const (
+	clrDirectoryIndex      = 14
+	securityDirectoryIndex = 4
+	metadataSignature      = 0x424A5342
+	userStringToken        = 0x70
+	maxUserStringHeap      = 0xFFFFFF
+	metadataSectionFlags   = 0x40000040 // initialized data, readable
+	cliFlagILLibrary       = 0x4
+)
+
+type peSection struct {
+	va, vsize      uint32
+	rawPtr, rawLen uint32
+}
+
+type peImage struct {
+	r           io.ReaderAt
+	size        int64
+	hdr         []byte // headers through one spare section header slot
+	coffOff     int
+	optOff      int
+	sectOff     int
+	dirOff      int
+	numDirs     uint32
+	fileAlign   uint32
+	sectAlign   uint32
+	sizeHeaders uint32
+	sections    []peSection
+}
+
+// readAt reads n bytes at off, capped at the end of the image
+func readAt(r io.ReaderAt, size, off int64, n int) ([]byte, error) {
+	n = int(max(0, min(int64(n), size-off)))
+	buf := make([]byte, n)
+	if read, err := r.ReadAt(buf, off); read < n {
+		if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+func parsePE(r io.ReaderAt, size int64) (*peImage, error) {
+	data, err := readAt(r, size, 0, 0x1000)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 0x40 || data[0] != 'M' || data[1] != 'Z' {
+		return nil, errNotManaged
+	}
+
+	peOff := int(binary.LittleEndian.Uint32(data[0x3C:]))
+	if peOff < 0 || peOff+24 > len(data) || string(data[peOff:peOff+4]) != "PE\x00\x00" {
+		return nil, errNotManaged
+	}
+
+	img := &peImage{r: r, size: size, coffOff: peOff + 4, optOff: peOff + 24}
+	numSections := int(binary.LittleEndian.Uint16(data[img.coffOff+2:]))
+	optSize := int(binary.LittleEndian.Uint16(data[img.coffOff+16:]))
+	img.sectOff = img.optOff + optSize
+
+	if need := img.sectOff + (numSections+1)*40; need > len(data) {
+		if data, err = readAt(r, size, 0, need); err != nil {
+			return nil, err
+		}
+	}
+	img.hdr = data
+	if img.sectOff+numSections*40 > len(data) || optSize < 96 {
+		return nil, errNotManaged
+	}
+
+	switch binary.LittleEndian.Uint16(data[img.optOff:]) {
+	case 0x10b:
+		img.numDirs = binary.LittleEndian.Uint32(data[img.optOff+92:])
+		img.dirOff = img.optOff + 96
+	case 0x20b:
+		img.numDirs = binary.LittleEndian.Uint32(data[img.optOff+108:])
+		img.dirOff = img.optOff + 112
+	default:
+		return nil, errNotManaged
+	}
+	if img.numDirs <= clrDirectoryIndex || img.dirOff+int(img.numDirs)*8 > img.sectOff {
+		return nil, errNotManaged
+	}
+
+	img.sectAlign = binary.LittleEndian.Uint32(data[img.optOff+32:])
+	img.fileAlign = binary.LittleEndian.Uint32(data[img.optOff+36:])
+	img.sizeHeaders = binary.LittleEndian.Uint32(data[img.optOff+60:])
+	if img.sectAlign == 0 || img.fileAlign == 0 {
+		return nil, errNotManaged
+	}
+
+	for i := 0; i < numSections; i++ {
+		h := data[img.sectOff+i*40:]
+		img.sections = append(img.sections, peSection{
+			vsize:  binary.LittleEndian.Uint32(h[8:]),
+			va:     binary.LittleEndian.Uint32(h[12:]),
+			rawLen: binary.LittleEndian.Uint32(h[16:]),
+			rawPtr: binary.LittleEndian.Uint32(h[20:]),
+		})
+	}
+
+	return img, nil
+}
+
+func (img *peImage) directory(index int) (uint32, uint32) {
+	off := img.dirOff + index*8
+	return binary.LittleEndian.Uint32(img.hdr[off:]), binary.LittleEndian.Uint32(img.hdr[off+4:])
+}
+
+// offset maps an RVA to a file offset, -1 if it is not backed by file data
+func (img *peImage) offset(rva uint32) int {
+	for _, s := range img.sections {
+		if rva >= s.va && rva-s.va < s.rawLen && rva-s.va < max(s.vsize, s.rawLen) {
+			off := int(s.rawPtr + rva - s.va)
+			if int64(off) < img.size {
+				return off
+			}
+		}
+	}
+	return -1
+}
+
+type metadataStream struct {
+	hdrOff int // offset of the stream header within the metadata block
+	offset uint32
+	size   uint32
+	name   string
+}
+
+type managedImage struct {
+	pe      *peImage
+	cliOff  int    // file offset of the COR20 header
+	cli     []byte // the COR20 header, at most 72 bytes
+	metaOff int    // file offset of the metadata root
+	meta    []byte
+	streams []metadataStream
+}
+
+func parseManaged(r io.ReaderAt, size int64) (*managedImage, error) {
+	pe, err := parsePE(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	cliRVA, cliSize := pe.directory(clrDirectoryIndex)
+	if cliRVA == 0 || cliSize < 16 {
+		return nil, errNotManaged
+	}
+	cliOff := pe.offset(cliRVA)
+	if cliOff < 0 || int64(cliOff)+16 > size {
+		return nil, errNotManaged
+	}
+	cli, err := readAt(r, size, int64(cliOff), int(min(cliSize, 72)))
+	if err != nil {
+		return nil, err
+	}
+
+	metaRVA := binary.LittleEndian.Uint32(cli[8:])
+	metaSize := binary.LittleEndian.Uint32(cli[12:])
+	metaOff := pe.offset(metaRVA)
+	if metaOff < 0 || int64(metaOff)+int64(metaSize) > size {
+		return nil, fmt.Errorf("metadata outside of image")
+	}
+	meta, err := readAt(r, size, int64(metaOff), int(metaSize))
+	if err != nil {
+		return nil, err
+	}
+
+	m := &managedImage{pe: pe, cliOff: cliOff, cli: cli, metaOff: metaOff, meta: meta}
+	if err := m.parseStreams(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *managedImage) parseStreams() error {
+	meta := m.meta
+	if len(meta) < 20 || binary.LittleEndian.Uint32(meta) != metadataSignature {
+		return fmt.Errorf("bad metadata signature")
+	}
+
+	verLen := int(binary.LittleEndian.Uint32(meta[12:]))
+	pos := 16 + verLen
+	if pos+4 > len(meta) {
+		return fmt.Errorf("truncated metadata root")
+	}
+	count := int(binary.LittleEndian.Uint16(meta[pos+2:]))
+	pos += 4
+
+	for i := 0; i < count; i++ {
+		if pos+8 > len(meta) {
+			return fmt.Errorf("truncated stream header")
+		}
+		s := metadataStream{
+			hdrOff: pos,
+			offset: binary.LittleEndian.Uint32(meta[pos:]),
+			size:   binary.LittleEndian.Uint32(meta[pos+4:]),
+		}
+		pos += 8
+
+		end := pos
+		for end < len(meta) && meta[end] != 0 {
+			end++
+		}
+		if end >= len(meta) {
+			return fmt.Errorf("truncated stream name")
+		}
+		s.name = string(meta[pos:end])
+		pos = (end + 4) &^ 3
+
+		if uint64(s.offset)+uint64(s.size) > uint64(len(meta)) {
+			return fmt.Errorf("stream %s outside of metadata", s.name)
+		}
+		m.streams = append(m.streams, s)
+	}
+	return nil
+}
+
+func (m *managedImage) stream(names ...string) *metadataStream {
+	for i := range m.streams {
+		for _, name := range names {
+			if m.streams[i].name == name {
+				return &m.streams[i]
+			}
+		}
+	}
+	return nil
+}
+
+// methodBodies returns the file offsets of all IL method bodies
+func (m *managedImage) methodBodies() ([]int, error) {
+	tables := m.stream("#~", "#-")
+	if tables == nil {
+		return nil, fmt.Errorf("no metadata tables stream")
+	}
+	t := m.meta[tables.offset : tables.offset+tables.size]
+	if len(t) < 24 {
+		return nil, fmt.Errorf("truncated tables stream")
+	}
+
+	heapSizes := t[6]
+	valid := binary.LittleEndian.Uint64(t[8:])
+
+	var rows [64]uint32
+	pos := 24
+	for i := 0; i < 64; i++ {
+		if valid&(1<<i) == 0 {
+			continue
+		}
+		if pos+4 > len(t) {
+			return nil, fmt.Errorf("truncated table row counts")
+		}
+		rows[i] = binary.LittleEndian.Uint32(t[pos:])
+		pos += 4
+	}
+	if heapSizes&0x40 != 0 {
+		pos += 4
+	}
+
+	heap := func(bit byte) int {
+		if heapSizes&bit != 0 {
+			return 4
+		}
+		return 2
+	}
+	index := func(table int) int {
+		if rows[table] >= 1<<16 {
+			return 4
+		}
+		return 2
+	}
+	coded := func(bits uint, tables ...int) int {
+		for _, table := range tables {
+			if rows[table] >= 1<<(16-bits) {
+				return 4
+			}
+		}
+		return 2
+	}
+
+	str, guid, blob := heap(0x01), heap(0x02), heap(0x04)
+	const (
+		tModule, tTypeRef, tTypeDef, tFieldPtr, tField, tMethodPtr, tMethodDef = 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06
+		tParam, tModuleRef, tTypeSpec, tAssemblyRef                            = 0x08, 0x1A, 0x1B, 0x23
+	)
+
+	rowSize := [...]int{
+		tModule:    2 + str + 3*guid,
+		tTypeRef:   coded(2, tModule, tModuleRef, tAssemblyRef, tTypeRef) + 2*str,
+		tTypeDef:   4 + 2*str + coded(2, tTypeDef, tTypeRef, tTypeSpec) + index(tField) + index(tMethodDef),
+		tFieldPtr:  index(tField),
+		tField:     2 + str + blob,
+		tMethodPtr: index(tMethodDef),
+	}
+	for table, size := range rowSize {
+		pos += int(rows[table]) * size
+	}
+
+	methodRow := 8 + str + blob + index(tParam)
+	if pos+int(rows[tMethodDef])*methodRow > len(t) {
+		return nil, fmt.Errorf("truncated MethodDef table")
+	}
+
+	seen := make(map[uint32]bool)
+	var bodies []int
+	for i := 0; i < int(rows[tMethodDef]); i++ {
+		row := t[pos+i*methodRow:]
+		rva := binary.LittleEndian.Uint32(row)
+		implFlags := binary.LittleEndian.Uint16(row[4:])
+		// Only IL bodies, native and runtime-provided methods have no ldstr
+		if rva == 0 || implFlags&0x3 != 0 || seen[rva] {
+			continue
+		}
+		seen[rva] = true
+
+		off := m.pe.offset(rva)
+		if off < 0 {
+			return nil, fmt.Errorf("method body at RVA 0x%x outside of image", rva)
+		}
+		bodies = append(bodies, off)
+	}
+	return bodies, nil
+}
+
+// ilOperandSize returns the operand size of a one-byte opcode (ECMA-335 III),
+// -1 for switch and unused opcodes
+func ilOperandSize(op byte) int {
+	switch {
+	case op >= 0x0E && op <= 0x13, op == 0x1F, op >= 0x2B && op <= 0x37, op == 0xDE:
+		return 1
+	case op == 0x21, op == 0x23:
+		return 8
+	case op == 0x20, op == 0x22, op >= 0x27 && op <= 0x29, op >= 0x38 && op <= 0x44,
+		op >= 0x6F && op <= 0x75, op == 0x79, op >= 0x7B && op <= 0x81, op == 0x8C, op == 0x8D,
+		op == 0x8F, op >= 0xA3 && op <= 0xA5, op == 0xC2, op == 0xC6, op == 0xD0, op == 0xDD:
+		return 4
+	case op <= 0x0D, op >= 0x14 && op <= 0x1E, op == 0x25, op == 0x26, op == 0x2A,
+		op >= 0x46 && op <= 0x6E, op == 0x76, op == 0x7A, op >= 0x82 && op <= 0x8B, op == 0x8E,
+		op >= 0x90 && op <= 0xA2, op >= 0xB3 && op <= 0xBA, op == 0xC3, op >= 0xD1 && op <= 0xDC,
+		op == 0xDF, op == 0xE0:
+		return 0
+	}
+	return -1
+}
+
+// ilOperandSizeFE returns the operand size of a 0xFE-prefixed opcode
+func ilOperandSizeFE(op byte) int {
+	switch op {
+	case 0x06, 0x07, 0x15, 0x16, 0x1C:
+		return 4
+	case 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E:
+		return 2
+	case 0x12, 0x19:
+		return 1
+	case 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x0F, 0x11, 0x13, 0x14, 0x17, 0x18, 0x1A, 0x1D, 0x1E:
+		return 0
+	}
+	return -1
+}
+
+// ldstrOperand is the token of an ldstr instruction and its file offset
+type ldstrOperand struct {
+	off   int
+	token uint32
+}
+
+// ldstrOperands returns the ldstr operands in the method body at off
+func ldstrOperands(r io.ReaderAt, imageSize int64, off int) ([]ldstrOperand, error) {
+	if int64(off) >= imageSize {
+		return nil, fmt.Errorf("method body outside of image")
+	}
+	header, err := readAt(r, imageSize, int64(off), 12)
+	if err != nil {
+		return nil, err
+	}
+
+	var code, end int
+	switch header[0] & 0x3 {
+	case 0x2:
+		code = off + 1
+		end = code + int(header[0]>>2)
+	case 0x3:
+		if len(header) < 12 {
+			return nil, fmt.Errorf("truncated fat method header")
+		}
+		code = off + int(header[1]>>4)*4
+		end = code + int(binary.LittleEndian.Uint32(header[4:]))
+	default:
+		return nil, fmt.Errorf("bad method header 0x%02x", header[0])
+	}
+	if int64(end) > imageSize {
+		return nil, fmt.Errorf("method body past end of image")
+	}
+
+	data, err := readAt(r, imageSize, int64(code), end-code)
+	if err != nil {
+		return nil, err
+	}
+	end -= code
+
+	var operands []ldstrOperand
+	for pc := 0; pc < end; {
+		op := data[pc]
+		pc++
+
+		size := 0
+		switch op {
+		case 0x72: // ldstr
+			if pc+4 > end {
+				return nil, fmt.Errorf("truncated ldstr")
+			}
+			operands = append(operands, ldstrOperand{off: code + pc, token: binary.LittleEndian.Uint32(data[pc:])})
+			size = 4
+		case 0x45: // switch
+			if pc+4 > end {
+				return nil, fmt.Errorf("truncated switch")
+			}
+			size = 4 + 4*int(binary.LittleEndian.Uint32(data[pc:]))
+		case 0xFE:
+			if pc >= end {
+				return nil, fmt.Errorf("truncated opcode")
+			}
+			size = ilOperandSizeFE(data[pc])
+			if size < 0 {
+				return nil, fmt.Errorf("unknown opcode 0xfe%02x", data[pc])
+			}
+			pc++
+		default:
+			size = ilOperandSize(op)
+			if size < 0 {
+				return nil, fmt.Errorf("unknown opcode 0x%02x", op)
+			}
+		}
+		pc += size
+	}
+	return operands, nil
+}
+
+func readCompressedUint(b []byte) (uint32, int, bool) {
+	if len(b) == 0 {
+		return 0, 0, false
+	}
+	switch {
+	case b[0]&0x80 == 0:
+		return uint32(b[0]), 1, true
+	case b[0]&0xC0 == 0x80 && len(b) >= 2:
+		return uint32(b[0]&0x3F)<<8 | uint32(b[1]), 2, true
+	case b[0]&0xE0 == 0xC0 && len(b) >= 4:
+		return uint32(b[0]&0x1F)<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3]), 4, true
+	}
+	return 0, 0, false
+}
+
+func appendCompressedUint(b []byte, v uint32) []byte {
+	switch {
+	case v < 0x80:
+		return append(b, byte(v))
+	case v < 0x4000:
+		return append(b, byte(v>>8)|0x80, byte(v))
+	default:
+		return append(b, byte(v>>24)|0xC0, byte(v>>16), byte(v>>8), byte(v))
+	}
+}
+
+// userStringFlag is the trailing byte of a #US entry, set when the string
+// needs more than ordinary ASCII handling
+func userStringFlag(units []uint16) byte {
+	for _, u := range units {
+		if u > 0xFF || (u >= 0x01 && u <= 0x08) || (u >= 0x0E && u <= 0x1F) || u == 0x27 || u == 0x2D || u == 0x7F {
+			return 1
+		}
+	}
+	return 0
+}
+
+// rebuildUserStrings applies rewrite to every entry of a #US heap, passing
+// base plus the entry offset. It returns the new heap and a map from old to
+// new entry offsets, nil if nothing changed.
+func rebuildUserStrings(heap []byte, base int, rewrite func(int, string) string) ([]byte, map[uint32]uint32, error) {
+	out := []byte{0}
+	offsets := make(map[uint32]uint32)
+	changed := false
+
+	pos := 1
+	for pos < len(heap) {
+		length, n, ok := readCompressedUint(heap[pos:])
+		if !ok || pos+n+int(length) > len(heap) {
+			return nil, nil, fmt.Errorf("corrupt user string at 0x%x", pos)
+		}
+		entry := heap[pos : pos+n+int(length)]
+		offsets[uint32(pos)] = uint32(len(out))
+		entryOff := base + pos
+		pos += len(entry)
+
+		if length < 3 {
+			out = append(out, entry...)
+			continue
+		}
+
+		raw := entry[n : len(entry)-1]
+		units := make([]uint16, len(raw)/2)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(raw[i*2:])
+		}
+
+		old := string(utf16.Decode(units))
+		updated := rewrite(entryOff, old)
+		if updated == old {
+			out = append(out, entry...)
+			continue
+		}
+		changed = true
+
+		units = utf16.Encode([]rune(updated))
+		out = appendCompressedUint(out, uint32(len(units)*2+1))
+		for _, u := range units {
+			out = binary.LittleEndian.AppendUint16(out, u)
+		}
+		out = append(out, userStringFlag(units))
+	}
+
+	if !changed {
+		return nil, nil, nil
+	}
+	if len(out) > maxUserStringHeap {
+		return nil, nil, fmt.Errorf("user string heap too large (%d bytes)", len(out))
+	}
+	for len(out)%4 != 0 {
+		out = append(out, 0)
+	}
+	return out, offsets, nil
+}
+
+// ReadyToRun images built for other OSes than Windows store the machine
+// XORed with an OS value, plain IL images must carry the real one
+var r2rMachineOverrides = []uint16{0x4644, 0xADC4, 0x7B79, 0x1993, 0x1992}
+
+func restoreMachine(coff []byte) {
+	machine := binary.LittleEndian.Uint16(coff)
+	for _, v := range r2rMachineOverrides {
+		switch machine ^ v {
+		case 0x014C, 0x8664, 0x01C4, 0xAA64:
+			binary.LittleEndian.PutUint16(coff, machine^v)
+			return
+		}
+	}
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func alignUp(v, align uint32) uint32 {
+	return (v + align - 1) / align * align
+}
+
+// imageEdit describes a rewritten image as changes to the original file, so
+// it can be streamed instead of copied in memory. The result is the first
+// keep bytes of the original with patches laid over them, zero filled up to
+// size, with tail placed at tailOff.
+type imageEdit struct {
+	patches []filePatch // never overlapping
+	keep    int64
+	tailOff int64
+	tail    []byte
+	size    int64
+}
+
+type filePatch struct {
+	off  int64
+	data []byte
+}
+
+func (e *imageEdit) patch(off int, data []byte) {
+	e.patches = append(e.patches, filePatch{off: int64(off), data: data})
+}
+
+func (e *imageEdit) patchUint32(off int, v uint32) {
+	e.patch(off, binary.LittleEndian.AppendUint32(nil, v))
+}
+
+// apply returns the edited copy of data
+func (e *imageEdit) apply(data []byte) []byte {
+	out := make([]byte, e.size)
+	copy(out, data[:e.keep])
+	for _, p := range e.patches {
+		copy(out[p.off:], p.data)
+	}
+	copy(out[e.tailOff:], e.tail)
+	return out
+}
+
+// reader streams the edited image, reading the original from src
+func (e *imageEdit) reader(src io.ReaderAt) io.Reader {
+	sort.Slice(e.patches, func(i, j int) bool { return e.patches[i].off < e.patches[j].off })
+	return &editReader{edit: e, src: src}
+}
+
+type editReader struct {
+	edit *imageEdit
+	src  io.ReaderAt
+	pos  int64
+	next int // first patch that may still overlap pos
+}
+
+func (er *editReader) Read(p []byte) (int, error) {
+	e := er.edit
+	if er.pos >= e.size {
+		return 0, io.EOF
+	}
+	p = p[:min(int64(len(p)), e.size-er.pos)]
+
+	n := int(max(0, min(int64(len(p)), e.keep-er.pos)))
+	if n > 0 {
+		if read, err := er.src.ReadAt(p[:n], er.pos); read < n {
+			if err == nil || err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return 0, err
+		}
+	}
+	clear(p[n:])
+
+	for er.next < len(e.patches) && e.patches[er.next].off+int64(len(e.patches[er.next].data)) <= er.pos {
+		er.next++
+	}
+	end := er.pos + int64(len(p))
+	for _, patch := range e.patches[er.next:] {
+		if patch.off >= end {
+			break
+		}
+		overlay(p, er.pos, patch.off, patch.data)
+	}
+	overlay(p, er.pos, e.tailOff, e.tail)
+
+	er.pos = end
+	return len(p), nil
+}
+
+// overlay copies the part of data, which belongs at off, that falls into p,
+// which starts at pos
+func overlay(p []byte, pos, off int64, data []byte) {
+	start := max(off, pos)
+	end := min(off+int64(len(data)), pos+int64(len(p)))
+	if start < end {
+		copy(p[start-pos:end-pos], data[start-off:end-off])
+	}
+}
+
+// RewriteUserStrings applies rewrite to every string literal of a managed PE
+// image, along with the file offset of its #US entry. Longer strings are
+// supported, the metadata is moved to a new section when the heap outgrows its
+// original space. Returns errNotManaged for native binaries.
+func RewriteUserStrings(data []byte, rewrite func(offset int, s string) string) ([]byte, error) {
+	edit, err := planUserStrings(bytes.NewReader(data), int64(len(data)), rewrite)
+	if err != nil {
+		return nil, err
+	}
+	if edit == nil {
+		return data, nil
+	}
+	return edit.apply(data), nil
+}
+
+// planUserStrings works out the edit RewriteUserStrings makes, reading the
+// image from r. Returns nil when no string changes.
+func planUserStrings(r io.ReaderAt, size int64, rewrite func(offset int, s string) string) (*imageEdit, error) {
+	m, err := parseManaged(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	us := m.stream("#US")
+	if us == nil {
+		return nil, nil
+	}
+
+	heap, offsets, err := rebuildUserStrings(m.meta[us.offset:us.offset+us.size], m.metaOff+int(us.offset), rewrite)
+	if err != nil {
+		return nil, err
+	}
+	if heap == nil {
+		return nil, nil
+	}
+
+	bodies, err := m.methodBodies()
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect every ldstr before touching anything, a body we cannot decode
+	// would leave tokens pointing into the old heap
+	var operands []ldstrOperand
+	for _, body := range bodies {
+		ops, err := ldstrOperands(r, size, body)
+		if err != nil {
+			return nil, fmt.Errorf("method body at 0x%x: %w", body, err)
+		}
+		operands = append(operands, ops...)
+	}
+
+	edit := &imageEdit{keep: size, size: size}
+
+	// ReadyToRun code has the old string offsets baked into its fixups, drop
+	// it so the runtime compiles the remapped IL instead
+	if len(m.cli) >= 72 && binary.LittleEndian.Uint32(m.cli) >= 72 {
+		if binary.LittleEndian.Uint32(m.cli[64:]) != 0 {
+			logger.Info("Discarding ReadyToRun code of patched image")
+			edit.patch(m.cliOff+64, make([]byte, 8))
+			flags := binary.LittleEndian.Uint32(m.cli[16:])
+			edit.patchUint32(m.cliOff+16, flags&^cliFlagILLibrary)
+			machine := slices.Clone(m.pe.hdr[m.pe.coffOff : m.pe.coffOff+2])
+			restoreMachine(machine)
+			edit.patch(m.pe.coffOff, machine)
+		}
+	}
+
+	for _, op := range operands {
+		if op.token>>24 != userStringToken {
+			continue
+		}
+		newOff, ok := offsets[op.token&0xFFFFFF]
+		if !ok {
+			return nil, fmt.Errorf("ldstr at 0x%x references unknown string 0x%x", op.off, op.token&0xFFFFFF)
+		}
+		edit.patchUint32(op.off, userStringToken<<24|newOff)
+	}
+
+	if len(heap) <= int(us.size) {
+		// Fits in place, the leftover bytes read as empty entries
+		padded := make([]byte, us.size)
+		copy(padded, heap)
+		edit.patch(m.metaOff+int(us.offset), padded)
+		return edit, nil
+	}
+
+	return relocateMetadata(edit, m, us, heap)
+}
+
+// relocateMetadata adds the metadata with the new #US heap to the edit, in a
+// section appended to the image, and points the CLI header at it
+func relocateMetadata(edit *imageEdit, m *managedImage, us *metadataStream, heap []byte) (*imageEdit, error) {
+	pe := m.pe
+	delta := uint32(len(heap)) - us.size
+
+	meta := make([]byte, 0, len(m.meta)+int(delta))
+	meta = append(meta, m.meta[:us.offset]...)
+	meta = append(meta, heap...)
+	meta = append(meta, m.meta[us.offset+us.size:]...)
+
+	for _, s := range m.streams {
+		switch {
+		case s.name == "#US":
+			binary.LittleEndian.PutUint32(meta[s.hdrOff+4:], uint32(len(heap)))
+		case s.offset > us.offset:
+			binary.LittleEndian.PutUint32(meta[s.hdrOff:], s.offset+delta)
+		}
+	}
+
+	var imageEnd, virtualEnd, firstRaw uint32 = 0, 0, ^uint32(0)
+	last := -1
+	for i, s := range pe.sections {
+		imageEnd = max(imageEnd, s.rawPtr+s.rawLen)
+		if end := s.va + max(s.vsize, s.rawLen); end > virtualEnd {
+			virtualEnd = end
+			last = i
+		}
+		if s.rawLen > 0 {
+			firstRaw = min(firstRaw, s.rawPtr)
+		}
+	}
+
+	if pe.size > int64(imageEnd) {
+		// An Authenticode signature is the only trailing data we can drop, the
+		// patch invalidates it anyway. Anything else (e.g. a single-file
+		// bundle) would move.
+		secOff, secSize := pe.directory(securityDirectoryIndex)
+		if secOff < imageEnd || int64(secOff)+int64(secSize) < pe.size {
+			return nil, fmt.Errorf("image has trailing data, cannot grow metadata")
+		}
+		logger.Info("Dropping Authenticode signature from patched client")
+		edit.keep = int64(imageEnd)
+		edit.patch(pe.dirOff+securityDirectoryIndex*8, make([]byte, 8))
+	}
+
+	var va uint32
+
+	hdrOff := pe.sectOff + len(pe.sections)*40
+	if uint32(hdrOff+40) <= min(pe.sizeHeaders, firstRaw) && hdrOff+40 <= len(pe.hdr) && allZero(pe.hdr[hdrOff:hdrOff+40]) {
+		// Room for another section header, put the metadata in its own section
+		rawPtr := alignUp(imageEnd, pe.fileAlign)
+		rawLen := alignUp(uint32(len(meta)), pe.fileAlign)
+		va = alignUp(virtualEnd, pe.sectAlign)
+
+		edit.tailOff = int64(rawPtr)
+		edit.size = int64(rawPtr + rawLen)
+
+		h := make([]byte, 40)
+		copy(h, ".hlmeta\x00")
+		binary.LittleEndian.PutUint32(h[8:], uint32(len(meta)))
+		binary.LittleEndian.PutUint32(h[12:], va)
+		binary.LittleEndian.PutUint32(h[16:], rawLen)
+		binary.LittleEndian.PutUint32(h[20:], rawPtr)
+		binary.LittleEndian.PutUint32(h[36:], metadataSectionFlags)
+		edit.patch(hdrOff, h)
+		edit.patch(pe.coffOff+2, binary.LittleEndian.AppendUint16(nil, uint16(len(pe.sections)+1)))
+	} else {
+		// Otherwise grow the last section, it must also be last in the file
+		s := pe.sections[last]
+		if s.rawPtr+s.rawLen != imageEnd {
+			return nil, fmt.Errorf("no room for another section header")
+		}
+
+		start := alignUp(max(s.vsize, s.rawLen), 8)
+		rawLen := alignUp(start+uint32(len(meta)), pe.fileAlign)
+		va = s.va + start
+
+		edit.tailOff = int64(s.rawPtr + start)
+		edit.size = int64(s.rawPtr + rawLen)
+
+		off := pe.sectOff + last*40
+		h := slices.Clone(pe.hdr[off : off+40])
+		binary.LittleEndian.PutUint32(h[8:], start+uint32(len(meta)))
+		binary.LittleEndian.PutUint32(h[16:], rawLen)
+		binary.LittleEndian.PutUint32(h[36:], binary.LittleEndian.Uint32(h[36:])|metadataSectionFlags)
+		edit.patch(off, h)
+	}
+	edit.tail = meta
+
+	edit.patchUint32(pe.optOff+56, alignUp(va+uint32(len(meta)), pe.sectAlign))
+	edit.patchUint32(pe.optOff+64, 0) // checksum is not enforced for applications
+
+	cli := binary.LittleEndian.AppendUint32(nil, va)
+	edit.patch(m.cliOff+8, binary.LittleEndian.AppendUint32(cli, uint32(len(meta))))
+
+	// The old heap is unreferenced now, clear it so byte patching does not
+	// find stale hostnames there
+	edit.patch(m.metaOff+int(us.offset), make([]byte, us.size))
+
+	return edit, nil
+}
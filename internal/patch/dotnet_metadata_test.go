@@ -0,0 +1,257 @@
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// Layout of the test image: headers, then one section at RVA == file offset
+// 0x200 holding the CLI header, a method body and the metadata
+const (
+	testPEOff      = 0x80
+	testOptOff     = testPEOff + 24
+	testSectOff    = testOptOff + 224
+	testSection    = 0x200
+	testBodyOff    = testSection + 72
+	testMetaOff    = testSection + 0x60
+	testImageSize  = 0x400
+	testStreamsOff = 56 // metadata root and both stream headers
+)
+
+// userStringEntry encodes one #US heap entry
+func userStringEntry(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	b := appendCompressedUint(nil, uint32(len(units)*2+1))
+	for _, u := range units {
+		b = binary.LittleEndian.AppendUint16(b, u)
+	}
+	return append(b, userStringFlag(units))
+}
+
+// buildManagedPE lays out a minimal PE32 assembly with one method that loads
+// each of strs with ldstr
+func buildManagedPE(t *testing.T, strs ...string) []byte {
+	t.Helper()
+	img := make([]byte, testImageSize)
+	le := binary.LittleEndian
+
+	copy(img, "MZ")
+	le.PutUint32(img[0x3C:], testPEOff)
+	copy(img[testPEOff:], "PE\x00\x00")
+	coff := img[testPEOff+4:]
+	le.PutUint16(coff, 0x014C)
+	le.PutUint16(coff[2:], 1)
+	le.PutUint16(coff[16:], 224)
+
+	opt := img[testOptOff:]
+	le.PutUint16(opt, 0x10b)
+	le.PutUint32(opt[32:], 0x200) // section alignment
+	le.PutUint32(opt[36:], 0x200) // file alignment
+	le.PutUint32(opt[56:], testImageSize)
+	le.PutUint32(opt[60:], 0x200) // size of headers
+	le.PutUint32(opt[92:], 16)
+	le.PutUint32(opt[96+clrDirectoryIndex*8:], testSection)
+	le.PutUint32(opt[96+clrDirectoryIndex*8+4:], 72)
+
+	sect := img[testSectOff:]
+	copy(sect, ".text\x00")
+	le.PutUint32(sect[8:], testImageSize-testSection)
+	le.PutUint32(sect[12:], testSection)
+	le.PutUint32(sect[16:], testImageSize-testSection)
+	le.PutUint32(sect[20:], testSection)
+
+	// #US heap, with the offset of every string
+	heap := []byte{0}
+	var offsets []uint32
+	for _, s := range strs {
+		offsets = append(offsets, uint32(len(heap)))
+		heap = append(heap, userStringEntry(s)...)
+	}
+	for len(heap)%4 != 0 {
+		heap = append(heap, 0)
+	}
+
+	// Tiny method body: ldstr; pop for every string, then ret
+	var code []byte
+	for _, off := range offsets {
+		code = append(code, 0x72)
+		code = le.AppendUint32(code, userStringToken<<24|off)
+		code = append(code, 0x26)
+	}
+	code = append(code, 0x2A)
+	if len(code) > 63 || testBodyOff+1+len(code) > testMetaOff {
+		t.Fatal("fixture method body too large")
+	}
+	img[testBodyOff] = byte(len(code))<<2 | 0x2
+	copy(img[testBodyOff+1:], code)
+
+	// #~ with a single MethodDef row pointing at the body
+	tables := make([]byte, 24)
+	tables[4] = 2
+	le.PutUint64(tables[8:], 1<<0x06)
+	tables = le.AppendUint32(tables, 1)
+	row := make([]byte, 14)
+	le.PutUint32(row, testBodyOff)
+	tables = append(tables, row...)
+	for len(tables)%4 != 0 {
+		tables = append(tables, 0)
+	}
+
+	meta := le.AppendUint32(nil, metadataSignature)
+	meta = le.AppendUint16(meta, 1)
+	meta = le.AppendUint16(meta, 1)
+	meta = le.AppendUint32(meta, 0)
+	meta = le.AppendUint32(meta, 12)
+	meta = append(meta, "v4.0.30319\x00\x00"...)
+	meta = le.AppendUint16(meta, 0)
+	meta = le.AppendUint16(meta, 2)
+	meta = le.AppendUint32(meta, testStreamsOff)
+	meta = le.AppendUint32(meta, uint32(len(tables)))
+	meta = append(meta, "#~\x00\x00"...)
+	meta = le.AppendUint32(meta, testStreamsOff+uint32(len(tables)))
+	meta = le.AppendUint32(meta, uint32(len(heap)))
+	meta = append(meta, "#US\x00"...)
+	if len(meta) != testStreamsOff {
+		t.Fatalf("metadata root is %d bytes", len(meta))
+	}
+	meta = append(append(meta, tables...), heap...)
+	if testMetaOff+len(meta) > testImageSize {
+		t.Fatal("fixture metadata too large")
+	}
+	copy(img[testMetaOff:], meta)
+
+	cli := img[testSection:]
+	le.PutUint32(cli, 72)
+	le.PutUint32(cli[8:], testMetaOff)
+	le.PutUint32(cli[12:], uint32(len(meta)))
+	le.PutUint32(cli[16:], 1) // IL only
+
+	return img
+}
+
+// loadedStrings parses an image the way the runtime would and returns the
+// strings its ldstr instructions load
+func loadedStrings(t *testing.T, data []byte) []string {
+	t.Helper()
+	m, err := parseManaged(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("parse rewritten image: %v", err)
+	}
+	us := m.stream("#US")
+	heap := m.meta[us.offset : us.offset+us.size]
+
+	bodies, err := m.methodBodies()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var strs []string
+	for _, body := range bodies {
+		ops, err := ldstrOperands(bytes.NewReader(data), int64(len(data)), body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, op := range ops {
+			off := int(op.token & 0xFFFFFF)
+			length, n, ok := readCompressedUint(heap[off:])
+			if !ok || off+n+int(length) > len(heap) {
+				t.Fatalf("ldstr token 0x%x points outside the heap", op.token)
+			}
+			raw := heap[off+n : off+n+int(length)-1]
+			units := make([]uint16, len(raw)/2)
+			for i := range units {
+				units[i] = binary.LittleEndian.Uint16(raw[i*2:])
+			}
+			strs = append(strs, string(utf16.Decode(units)))
+		}
+	}
+	return strs
+}
+
+func TestRewriteUserStrings(t *testing.T) {
+	original := []string{"https://sessions.hytale.com/api", "ok", "hytale.com"}
+
+	tests := []struct {
+		name      string
+		from, to  string
+		want      []string
+		relocated bool
+	}{
+		{
+			name: "shorter string in place",
+			from: "hytale.com", to: "a.io",
+			want: []string{"https://sessions.a.io/api", "ok", "a.io"},
+		},
+		{
+			name: "longer string relocates the metadata",
+			from: "hytale.com", to: "play.example-server.org",
+			want:      []string{"https://sessions.play.example-server.org/api", "ok", "play.example-server.org"},
+			relocated: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := buildManagedPE(t, original...)
+			if got := loadedStrings(t, img); strings.Join(got, "|") != strings.Join(original, "|") {
+				t.Fatalf("fixture loads %q", got)
+			}
+
+			out, err := RewriteUserStrings(img, func(_ int, s string) string {
+				return strings.ReplaceAll(s, tt.from, tt.to)
+			})
+			if err != nil {
+				t.Fatalf("RewriteUserStrings: %v", err)
+			}
+
+			if got := loadedStrings(t, out); strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("rewritten image loads %q, want %q", got, tt.want)
+			}
+			if relocated := len(out) > len(img); relocated != tt.relocated {
+				t.Errorf("image grew from %d to %d bytes, relocated want %v", len(img), len(out), tt.relocated)
+			}
+			if bytes.Contains(out, userStringEntry(tt.from)[1:]) {
+				t.Errorf("%q is still in the image", tt.from)
+			}
+
+			// Streaming the edit gives the same bytes
+			edit, err := planUserStrings(bytes.NewReader(img), int64(len(img)), func(_ int, s string) string {
+				return strings.ReplaceAll(s, tt.from, tt.to)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var streamed bytes.Buffer
+			if _, err := streamed.ReadFrom(edit.reader(bytes.NewReader(img))); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(streamed.Bytes(), out) {
+				t.Error("streamed edit differs from the in-memory one")
+			}
+		})
+	}
+}
+
+func TestRewriteUserStringsUnchanged(t *testing.T) {
+	img := buildManagedPE(t, "ok")
+	out, err := RewriteUserStrings(img, func(_ int, s string) string { return s })
+	if err != nil || !bytes.Equal(out, img) {
+		t.Fatalf("unchanged image was rewritten: %v", err)
+	}
+}
+
+func TestRewriteUserStringsNotManaged(t *testing.T) {
+	native := buildManagedPE(t, "ok")
+	clear(native[testOptOff+96+clrDirectoryIndex*8:][:8])
+
+	for name, data := range map[string][]byte{
+		"native PE": native,
+		"ELF":       []byte("\x7fELF\x02\x01\x01" + strings.Repeat("\x00", 64)),
+	} {
+		if _, err := RewriteUserStrings(data, func(_ int, s string) string { return s }); !errors.Is(err, errNotManaged) {
+			t.Errorf("%s: got %v, want errNotManaged", name, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"HyLauncher/internal/env"
+	"HyLauncher/pkg/logger"
+)
+
+// ErrManifestUnavailable means no patch source answered and nothing is cached on disk
+var ErrManifestUnavailable = errors.New("patch manifest unavailable")
+
+const (
+	manifestCacheFile      = "manifest.json"
+	manifestCacheStateFile = "state.json"
+)
+
+// manifestDiskState is stored next to the cached manifest and used to revalidate it
+type manifestDiskState struct {
+	PatchesURL   string    `json:"patchesUrl,omitempty"`
+	ManifestURL  string    `json:"manifestUrl,omitempty"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	FetchedAt    time.Time `json:"fetchedAt"`
+}
+
+// ManifestStatus tells the frontend how fresh the version data is
+type ManifestStatus struct {
+	// Stale is set when the manifest came from disk because no source was reachable
+	Stale     bool      `json:"stale"`
+	FetchedAt time.Time `json:"fetchedAt,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+var (
+	manifestDiskMu sync.Mutex
+
+	manifestStatusMu sync.RWMutex
+	manifestStatus   ManifestStatus
+)
+
+// GetManifestStatus returns the freshness of the last manifest lookup
+func GetManifestStatus() ManifestStatus {
+	manifestStatusMu.RLock()
+	defer manifestStatusMu.RUnlock()
+	return manifestStatus
+}
+
+func setManifestStatus(status ManifestStatus) {
+	manifestStatusMu.Lock()
+	manifestStatus = status
+	manifestStatusMu.Unlock()
+}
+
+func loadManifestDiskState() manifestDiskState {
+	manifestDiskMu.Lock()
+	defer manifestDiskMu.Unlock()
+	return readManifestDiskState()
+}
+
+func readManifestDiskState() manifestDiskState {
+	var st manifestDiskState
+	data, err := os.ReadFile(filepath.Join(env.GetManifestCacheDir(), manifestCacheStateFile))
+	if err != nil {
+		return st
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		logger.Warn("Failed to parse manifest cache state", "error", err)
+		return manifestDiskState{}
+	}
+	return st
+}
+
+func writeManifestDiskState(st manifestDiskState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(env.GetManifestCacheDir(), manifestCacheStateFile), data)
+}
+
+// savePatchesURL remembers the last patches URL a config source returned
+func savePatchesURL(url string) {
+	manifestDiskMu.Lock()
+	defer manifestDiskMu.Unlock()
+
+	st := readManifestDiskState()
+	if st.PatchesURL == url {
+		return
+	}
+	st.PatchesURL = url
+	if err := writeManifestDiskState(st); err != nil {
+		logger.Warn("Failed to save patches URL", "error", err)
+	}
+}
+
+// loadCachedManifest returns the last good manifest and its signature
+func loadCachedManifest() (raw []byte, sig []byte, err error) {
+	dir := env.GetManifestCacheDir()
+	raw, err = os.ReadFile(filepath.Join(dir, manifestCacheFile))
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err = os.ReadFile(filepath.Join(dir, ManifestSignatureFileName))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+	return raw, sig, nil
+}
+
+// saveCachedManifest stores a verified manifest with its validators
+func saveCachedManifest(raw, sig []byte, manifestURL, etag, lastModified string) {
+	manifestDiskMu.Lock()
+	defer manifestDiskMu.Unlock()
+
+	dir := env.GetManifestCacheDir()
+	if err := writeFileAtomic(filepath.Join(dir, manifestCacheFile), raw); err != nil {
+		logger.Warn("Failed to cache manifest", "error", err)
+		return
+	}
+
+	sigPath := filepath.Join(dir, ManifestSignatureFileName)
+	if len(sig) > 0 {
+		if err := writeFileAtomic(sigPath, sig); err != nil {
+			logger.Warn("Failed to cache manifest signature", "error", err)
+		}
+	} else {
+		_ = os.Remove(sigPath)
+	}
+
+	st := readManifestDiskState()
+	st.ManifestURL = manifestURL
+	st.ETag = etag
+	st.LastModified = lastModified
+	st.FetchedAt = time.Now()
+	if err := writeManifestDiskState(st); err != nil {
+		logger.Warn("Failed to save manifest cache state", "error", err)
+	}
+}
+
+// touchCachedManifest records a successful 304 revalidation
+func touchCachedManifest() time.Time {
+	manifestDiskMu.Lock()
+	defer manifestDiskMu.Unlock()
+
+	st := readManifestDiskState()
+	st.FetchedAt = time.Now()
+	if err := writeManifestDiskState(st); err != nil {
+		logger.Warn("Failed to save manifest cache state", "error", err)
+	}
+	return st.FetchedAt
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
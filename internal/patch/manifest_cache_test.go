@@ -0,0 +1,152 @@
+package patch
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"HyLauncher/internal/env"
+)
+
+// manifestServer serves a signed manifest.json with an ETag, or 500 while down
+type manifestServer struct {
+	raw, sig    []byte
+	down        atomic.Bool
+	full        atomic.Int32 // 200 responses for manifest.json
+	revalidated atomic.Int32 // 304 responses
+}
+
+func (s *manifestServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.down.Load() {
+		http.Error(w, "down", http.StatusInternalServerError)
+		return
+	}
+	switch r.URL.Path {
+	case "/manifest.json":
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			s.revalidated.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.full.Add(1)
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = w.Write(s.raw)
+	case "/" + ManifestSignatureFileName:
+		_, _ = w.Write(s.sig)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// withPatchesURL points the patch package at url with an empty cache dir
+func withPatchesURL(t *testing.T, url string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	reset := func(url string) {
+		patchesStateMu.Lock()
+		patchesBaseURL, patchesBaseURLSet = url, time.Now()
+		manifestCache, manifestCacheSet = nil, time.Time{}
+		patchesStateMu.Unlock()
+	}
+	reset(url)
+	t.Cleanup(func() { reset("") })
+}
+
+// forgetManifest drops the in-memory manifest so the next fetch goes out again
+func forgetManifest() {
+	patchesStateMu.Lock()
+	manifestCache = nil
+	patchesStateMu.Unlock()
+}
+
+func TestManifestRevalidationAndStaleFallback(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withManifestKey(t, hex.EncodeToString(pub))
+
+	raw := []byte(`{"files":{"linux/amd64/release/0_to_5.pwr":{"size":1}}}`)
+	srv := &manifestServer{raw: raw, sig: ed25519.Sign(priv, raw)}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+	withPatchesURL(t, ts.URL)
+
+	// First fetch downloads and caches the manifest with its ETag
+	if _, err := fetchManifest(); err != nil {
+		t.Fatalf("first fetch: %v", err)
+	}
+	if got := loadManifestDiskState().ETag; got != `"v1"` {
+		t.Fatalf("cached ETag = %q", got)
+	}
+	if status := GetManifestStatus(); status.Stale {
+		t.Errorf("fresh manifest reported stale: %+v", status)
+	}
+
+	// An unchanged manifest is revalidated instead of downloaded again
+	forgetManifest()
+	manifest, err := fetchManifest()
+	if err != nil {
+		t.Fatalf("revalidation: %v", err)
+	}
+	if srv.full.Load() != 1 || srv.revalidated.Load() != 1 {
+		t.Errorf("got %d downloads and %d revalidations, want 1 and 1", srv.full.Load(), srv.revalidated.Load())
+	}
+	if len(manifest.Files) != 1 {
+		t.Errorf("revalidated manifest has %d files", len(manifest.Files))
+	}
+
+	// With every source failing the cached copy is served as stale
+	srv.down.Store(true)
+	forgetManifest()
+	manifest, err = fetchManifest()
+	if err != nil {
+		t.Fatalf("stale fallback: %v", err)
+	}
+	if len(manifest.Files) != 1 {
+		t.Errorf("stale manifest has %d files", len(manifest.Files))
+	}
+	status := GetManifestStatus()
+	if !status.Stale || status.Error == "" || !status.FetchedAt.Equal(loadManifestDiskState().FetchedAt) {
+		t.Errorf("status = %+v, want stale with the cached fetch time", status)
+	}
+
+	// Without a cache there is nothing left to fall back on
+	if err := os.RemoveAll(env.GetManifestCacheDir()); err != nil {
+		t.Fatal(err)
+	}
+	forgetManifest()
+	if _, err := fetchManifest(); !errors.Is(err, ErrManifestUnavailable) {
+		t.Fatalf("got %v, want ErrManifestUnavailable", err)
+	}
+}
+
+func TestCachedManifestMustVerify(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withManifestKey(t, hex.EncodeToString(pub))
+	withPatchesURL(t, "")
+
+	raw := []byte(`{"files":{}}`)
+	saveCachedManifest(raw, ed25519.Sign(priv, raw), "https://example.invalid/manifest.json", `"v1"`, "")
+	if _, err := loadVerifiedCachedManifest(); err != nil {
+		t.Fatalf("signed cache rejected: %v", err)
+	}
+
+	// A cached manifest edited on disk is not trusted
+	saveCachedManifest(append(raw, ' '), ed25519.Sign(priv, raw), "https://example.invalid/manifest.json", `"v1"`, "")
+	if _, err := loadVerifiedCachedManifest(); err == nil {
+		t.Fatal("tampered cache accepted")
+	}
+}
@@ -0,0 +1,79 @@
+package patch
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"HyLauncher/internal/bootstrap"
+	"HyLauncher/internal/config"
+)
+
+// ManifestSignatureFileName is the detached Ed25519 signature of manifest.json
+const ManifestSignatureFileName = "manifest.json.sig"
+
+// manifestPublicKey parses the embedded manifest signing key. Without one
+// only dev builds run, and they accept unsigned manifests (nil key).
+func manifestPublicKey() (ed25519.PublicKey, error) {
+	key, err := bootstrap.ParsePublicKey(config.GetPatchManifestPublicKeyHex())
+	if errors.Is(err, bootstrap.ErrNoPublicKey) && config.DevBuild {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("patch manifest %w", err)
+	}
+	return key, nil
+}
+
+// fetchManifestSignature downloads manifest.json.sig from the patches base URL
+func fetchManifestSignature(client *http.Client, baseURL string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/"+ManifestSignatureFileName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create signature request: %w", err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest signature: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch manifest signature: HTTP %d", resp.StatusCode)
+	}
+
+	// Ed25519 signature is 64 bytes, allow some overhead for encoding
+	sig, err := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if err != nil {
+		return nil, fmt.Errorf("read manifest signature: %w", err)
+	}
+
+	return sig, nil
+}
+
+// verifyPatchFile checks a downloaded .pwr against the SHA-256 pinned in the
+// manifest. Pinning is optional per entry; an unpinned patch is held to the
+// size the manifest lists.
+func verifyPatchFile(pwrPath string, step PatchStep) error {
+	if strings.TrimSpace(step.SHA256) != "" {
+		return bootstrap.VerifyFileSHA256(pwrPath, step.SHA256)
+	}
+
+	st, err := os.Stat(pwrPath)
+	if err != nil {
+		return fmt.Errorf("stat patch: %w", err)
+	}
+	if step.Size > 0 && st.Size() != step.Size {
+		return fmt.Errorf("patch %d→%d size mismatch: expected %d, got %d", step.From, step.To, step.Size, st.Size())
+	}
+	return nil
+}
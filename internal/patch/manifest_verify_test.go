@@ -0,0 +1,124 @@
+package patch
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"HyLauncher/internal/config"
+	"HyLauncher/internal/env"
+)
+
+// withManifestKey embeds keyHex as the manifest key for one test
+func withManifestKey(t *testing.T, keyHex string) {
+	t.Helper()
+	old := config.PatchManifestPublicKeyHex
+	config.PatchManifestPublicKeyHex = keyHex
+	t.Cleanup(func() { config.PatchManifestPublicKeyHex = old })
+}
+
+func TestParseManifest(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := []byte(`{"files":{"windows/amd64/release/0_to_5.pwr":{"size":1}}}`)
+	sig := ed25519.Sign(priv, raw)
+
+	tests := []struct {
+		name    string
+		key     string
+		raw     []byte
+		sig     []byte
+		wantErr bool
+	}{
+		{"signed", hex.EncodeToString(pub), raw, sig, false},
+		{"tampered", hex.EncodeToString(pub), append([]byte(" "), raw...), sig, true},
+		{"missing signature", hex.EncodeToString(pub), raw, nil, true},
+		{"bad key", "zz", raw, sig, true},
+		{"no key", "", raw, nil, !config.DevBuild},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withManifestKey(t, tt.key)
+			_, err := parseManifest(tt.raw, tt.sig)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseManifest error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyPatchFile(t *testing.T) {
+	data := []byte("patch body")
+	path := filepath.Join(t.TempDir(), "0_to_5.pwr")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	good := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name    string
+		sha     string
+		size    int64
+		wantErr bool
+	}{
+		{"pinned", good, int64(len(data)), false},
+		{"pinned, mismatch", hex.EncodeToString(make([]byte, 32)), int64(len(data)), true},
+		{"not pinned", "", int64(len(data)), false},
+		{"not pinned, wrong size", "", 3, true},
+		{"not pinned, no size", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := verifyPatchFile(path, PatchStep{From: 0, To: 5, SHA256: tt.sha, Size: tt.size})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("verifyPatchFile error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignedManifestWithUnpinnedEntry(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withManifestKey(t, hex.EncodeToString(pub))
+
+	dir := t.TempDir()
+	full, update := []byte("full build"), []byte("update")
+	sum := sha256.Sum256(full)
+	prefix := fmt.Sprintf("%s/%s/release/", env.GetOS(), env.GetArchForAPI())
+	raw := []byte(fmt.Sprintf(`{"files":{%q:{"size":%d,"sha256":%q},%q:{"size":%d}}}`,
+		prefix+"0_to_5.pwr", len(full), hex.EncodeToString(sum[:]),
+		prefix+"5_to_6.pwr", len(update)))
+
+	manifest, err := parseManifest(raw, ed25519.Sign(priv, raw))
+	if err != nil {
+		t.Fatalf("parseManifest: %v", err)
+	}
+	patches := getPlatformPatches(manifest, "release")
+	if len(patches) != 2 {
+		t.Fatalf("got %d patches", len(patches))
+	}
+
+	for _, p := range patches {
+		data := full
+		if p.From == 5 {
+			data = update
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%d_to_%d.pwr", p.From, p.To))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := verifyPatchFile(path, PatchStep{From: p.From, To: p.To, Size: p.Size, SHA256: p.SHA256}); err != nil {
+			t.Errorf("%d→%d: %v", p.From, p.To, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package patch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"HyLauncher/internal/config"
+	"HyLauncher/internal/env"
+	"HyLauncher/pkg/logger"
+)
+
+// Unfinished downloads are kept for resume, but not forever
+const stalePartialAge = 14 * 24 * time.Hour
+
+var (
+	patchCacheMu     sync.Mutex
+	patchCachePinned = make(map[string]int)
+)
+
+// patchCachePath returns where a step lives in the content-addressed patch cache.
+// Steps with a pinned hash are stored as <sha256>-<size>.pwr, so identical patches
+// served under different manifest keys share one file.
+func patchCachePath(step PatchStep) string {
+	name := ""
+	if h := strings.ToLower(strings.TrimSpace(step.SHA256)); len(h) == 64 {
+		name = fmt.Sprintf("%s-%d.pwr", h, step.Size)
+	} else {
+		// Unpinned patches are keyed by their manifest path instead
+		key := step.Key
+		if key == "" {
+			key = fmt.Sprintf("%d_to_%d", step.From, step.To)
+		}
+		sum := sha256.Sum256([]byte(key))
+		name = fmt.Sprintf("key-%s-%d.pwr", hex.EncodeToString(sum[:8]), step.Size)
+	}
+	return filepath.Join(env.GetPatchCacheDir(), name)
+}
+
+// lookupCachedPatch returns the cached file for step if it still matches the manifest
+func lookupCachedPatch(step PatchStep) (string, bool) {
+	path := patchCachePath(step)
+
+	st, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+
+	if step.Size > 0 && st.Size() != step.Size {
+		logger.Warn("Cached patch has wrong size, discarding", "file", path, "size", st.Size(), "expected", step.Size)
+		_ = os.Remove(path)
+		return "", false
+	}
+
+	if err := verifyPatchFile(path, step); err != nil {
+		logger.Warn("Cached patch failed verification, discarding", "file", path, "error", err)
+		_ = os.Remove(path)
+		return "", false
+	}
+
+	touchCachedPatch(path)
+	return path, true
+}
+
+// touchCachedPatch marks a patch as recently used for LRU eviction
+func touchCachedPatch(path string) {
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+}
+
+// pinPatch protects a cached patch from eviction while it is being used
+func pinPatch(path string) {
+	patchCacheMu.Lock()
+	patchCachePinned[path]++
+	patchCacheMu.Unlock()
+}
+
+func unpinPatch(path string) {
+	patchCacheMu.Lock()
+	if patchCachePinned[path] <= 1 {
+		delete(patchCachePinned, path)
+	} else {
+		patchCachePinned[path]--
+	}
+	patchCacheMu.Unlock()
+}
+
+func patchCacheBudget() int64 {
+	cfg, err := config.LoadLauncher()
+	if err != nil || cfg.PatchCacheMaxMB < 0 {
+		return int64(config.LauncherDefault().PatchCacheMaxMB) << 20
+	}
+	return int64(cfg.PatchCacheMaxMB) << 20
+}
+
+// TrimPatchCache evicts the least recently used patches until the cache fits
+// the configured budget and drops partial downloads nobody came back for
+func TrimPatchCache() {
+	budget := patchCacheBudget()
+	dir := env.GetPatchCacheDir()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	type cachedFile struct {
+		path    string
+		size    int64
+		modTime time.Time
+	}
+
+	patchCacheMu.Lock()
+	defer patchCacheMu.Unlock()
+
+	var files []cachedFile
+	var total int64
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		name := entry.Name()
+
+		switch {
+		case strings.HasSuffix(name, ".pwr"):
+			total += info.Size()
+			if patchCachePinned[path] == 0 {
+				files = append(files, cachedFile{path: path, size: info.Size(), modTime: info.ModTime()})
+			}
+
+		case strings.HasSuffix(name, ".part"), strings.HasSuffix(name, ".part.segments"):
+			owner := strings.TrimSuffix(strings.TrimSuffix(name, ".segments"), ".part")
+			if patchCachePinned[filepath.Join(dir, owner)] == 0 && time.Since(info.ModTime()) > stalePartialAge {
+				logger.Info("Removing abandoned partial patch", "path", path)
+				_ = os.Remove(path)
+			}
+		}
+	}
+
+	if total <= budget {
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+
+	for _, f := range files {
+		if total <= budget {
+			break
+		}
+		logger.Info("Evicting cached patch", "path", f.path, "size", f.size)
+		if err := os.Remove(f.path); err != nil {
+			logger.Warn("Failed to evict cached patch", "path", f.path, "error", err)
+			continue
+		}
+		total -= f.size
+	}
+}
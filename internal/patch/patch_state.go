@@ -0,0 +1,99 @@
+package patch
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"HyLauncher/internal/env"
+	"HyLauncher/pkg/model"
+)
+
+// patchStateFileName sits in each install slot and records what was patched
+const patchStateFileName = ".patch-state.json"
+
+// FilePatchState ties a patched file to the original it was made from
+type FilePatchState struct {
+	// OriginalSHA256 is the hash of the .original backup, empty when the
+	// backup predates patch tracking and can't be trusted. Equals
+	// PatchedSHA256 when no rule matched and the file was left as is.
+	OriginalSHA256 string    `json:"originalSha256,omitempty"`
+	PatchedSHA256  string    `json:"patchedSha256"`
+	Domain         string    `json:"domain"`
+	RulesVersion   int       `json:"rulesVersion"`
+	PatchedAt      time.Time `json:"patchedAt"`
+}
+
+// PatchState is the patch-state file of an install slot, keyed by rule target
+type PatchState struct {
+	Files map[string]FilePatchState `json:"files"`
+}
+
+func patchStatePath(request model.InstanceModel) string {
+	return filepath.Join(env.GetGameDir(request.Branch, request.BuildVersion), patchStateFileName)
+}
+
+// LoadPatchState reads the patch state of an install. A missing or corrupt
+// file gives an empty state, which makes the next run start over.
+func LoadPatchState(request model.InstanceModel) *PatchState {
+	state := &PatchState{Files: make(map[string]FilePatchState)}
+
+	data, err := os.ReadFile(patchStatePath(request))
+	if err != nil {
+		return state
+	}
+	if err := json.Unmarshal(data, state); err != nil || state.Files == nil {
+		return &PatchState{Files: make(map[string]FilePatchState)}
+	}
+	return state
+}
+
+func savePatchState(request model.InstanceModel, state *PatchState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode patch state: %w", err)
+	}
+	return writeFileAtomic(patchStatePath(request), data)
+}
+
+// PatchedFiles returns the client and server of an install if they are still
+// exactly as the patcher left them. Verification against the unpatched build
+// reports them as modified, repair must leave them alone.
+func PatchedFiles(request model.InstanceModel) []string {
+	state := LoadPatchState(request)
+	paths := map[string]string{
+		RuleTargetClient: env.GetGameClientPath(request.Branch, request.BuildVersion),
+		RuleTargetServer: env.GetServerPath(request.Branch, request.BuildVersion),
+	}
+
+	var patched []string
+	for target, path := range paths {
+		entry, ok := state.Files[target]
+		if !ok || path == "" || entry.PatchedSHA256 == entry.OriginalSHA256 {
+			continue
+		}
+		if hash, err := hashFile(path); err == nil && hash == entry.PatchedSHA256 {
+			patched = append(patched, path)
+		}
+	}
+	return patched
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
@@ -0,0 +1,92 @@
+package patch
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"HyLauncher/internal/env"
+	"HyLauncher/pkg/model"
+)
+
+// nativeClient is a client binary whose only patchable string is UTF-16
+func nativeClient(url string) []byte {
+	return append([]byte("\x7fELF native client "), encodeUTF16(url+"\x00")...)
+}
+
+func TestPatchGameRerun(t *testing.T) {
+	withRulesKey(t, "", nil)
+
+	request := model.InstanceModel{InstanceID: "test", Branch: "release", BuildVersion: "5"}
+	clientDir := filepath.Join(env.GetGameDir(request.Branch, request.BuildVersion), "Client")
+	if err := os.MkdirAll(clientDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeClient := func(t *testing.T, data []byte) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(clientDir, "HytaleClient"), data, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeClient(t, nativeClient("https://hytale.com/api"))
+
+	// The steps run in order against the same install
+	tests := []struct {
+		name           string
+		before         func(t *testing.T)
+		domain         string
+		wantUpToDate   bool
+		wantFromBackup bool
+		wantURL        string
+		wantOriginal   string
+	}{
+		{"first run patches", nil, "a.io", false, false, "https://a.io", "https://hytale.com/api"},
+		{"same domain skips", nil, "a.io", true, false, "https://a.io", "https://hytale.com/api"},
+		{"new domain re-patches from backup", nil, "b.io", false, true, "https://b.io", "https://hytale.com/api"},
+		{"update replaced the client", func(t *testing.T) {
+			writeClient(t, nativeClient("https://hytale.com/v2"))
+		}, "b.io", false, false, "https://b.io", "https://hytale.com/v2"},
+		{"same domain skips after update", nil, "b.io", true, false, "https://b.io", "https://hytale.com/v2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.before != nil {
+				tt.before(t)
+			}
+
+			report, err := PatchGame(context.Background(), request, tt.domain, PatchOptions{}, nil)
+			if err != nil {
+				t.Fatalf("PatchGame: %v", err)
+			}
+			if len(report.Files) != 1 {
+				t.Fatalf("got %d file reports", len(report.Files))
+			}
+			file := report.Files[0]
+			if file.UpToDate != tt.wantUpToDate || file.FromBackup != tt.wantFromBackup {
+				t.Errorf("UpToDate = %v, FromBackup = %v, want %v, %v", file.UpToDate, file.FromBackup, tt.wantUpToDate, tt.wantFromBackup)
+			}
+
+			client, err := os.ReadFile(filepath.Join(clientDir, "HytaleClient"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(client, encodeUTF16(tt.wantURL)) || bytes.Contains(client, encodeUTF16("hytale.com")) {
+				t.Errorf("client = %q, want it patched to %s", client, tt.wantURL)
+			}
+
+			// The backup always holds the unpatched client of the current build
+			backup, err := os.ReadFile(filepath.Join(clientDir, "HytaleClient.original"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Contains(backup, encodeUTF16(tt.wantOriginal)) {
+				t.Errorf("backup = %q, want the client with %s", backup, tt.wantOriginal)
+			}
+			if patched := PatchedFiles(request); len(patched) != 1 {
+				t.Errorf("PatchedFiles = %v, want the client", patched)
+			}
+		})
+	}
+}
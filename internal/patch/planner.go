@@ -0,0 +1,125 @@
+package patch
+
+import (
+	"fmt"
+)
+
+// PatchPlan is an ordered chain of patches leading from one version to another
+type PatchPlan struct {
+	From      int
+	To        int
+	Steps     []PatchInfo
+	TotalSize int64
+	// Full is true when the plan starts from an empty install (0_to_N)
+	Full bool
+}
+
+// planPatchPath computes the cheapest chain of patches (by download size)
+// from currentVer to targetVer and compares it against a full install from 0.
+// A targetVer <= 0 means "latest available".
+func planPatchPath(patches []PatchInfo, currentVer, targetVer int) (*PatchPlan, error) {
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("no patches available")
+	}
+
+	if targetVer <= 0 {
+		for _, p := range patches {
+			if p.To > targetVer {
+				targetVer = p.To
+			}
+		}
+	}
+
+	if currentVer == targetVer {
+		return &PatchPlan{From: currentVer, To: targetVer}, nil
+	}
+
+	var incremental *PatchPlan
+	if currentVer > 0 {
+		incremental = shortestPatchPath(patches, currentVer, targetVer)
+	}
+
+	full := shortestPatchPath(patches, 0, targetVer)
+
+	switch {
+	case incremental == nil && full == nil:
+		return nil, fmt.Errorf("no patch path from %d to %d", currentVer, targetVer)
+	case incremental == nil:
+		return full, nil
+	case full == nil:
+		return incremental, nil
+	case full.TotalSize < incremental.TotalSize:
+		return full, nil
+	default:
+		return incremental, nil
+	}
+}
+
+// shortestPatchPath runs Dijkstra over the patch graph, where every patch is
+// an edge From -> To weighted by its size. Returns nil if target is unreachable.
+func shortestPatchPath(patches []PatchInfo, from, to int) *PatchPlan {
+	edges := make(map[int][]PatchInfo)
+	for _, p := range patches {
+		// Only forward edges; a downgrade patch never helps reach a newer build
+		if p.To <= p.From {
+			continue
+		}
+		edges[p.From] = append(edges[p.From], p)
+	}
+
+	dist := map[int]int64{from: 0}
+	hops := map[int]int{from: 0}
+	prev := make(map[int]PatchInfo)
+	visited := make(map[int]bool)
+
+	for {
+		// Pick the closest unvisited version; the graph is small (tens of builds)
+		current, found := 0, false
+		for v, d := range dist {
+			if visited[v] {
+				continue
+			}
+			if !found || d < dist[current] || (d == dist[current] && hops[v] < hops[current]) {
+				current, found = v, true
+			}
+		}
+
+		if !found {
+			return nil
+		}
+		if current == to {
+			break
+		}
+		visited[current] = true
+
+		for _, p := range edges[current] {
+			if p.To > to || visited[p.To] {
+				continue
+			}
+
+			cost := dist[current] + p.Size
+			known, seen := dist[p.To]
+			// Prefer fewer hops when sizes tie, every apply has a fixed overhead
+			if !seen || cost < known || (cost == known && hops[current]+1 < hops[p.To]) {
+				dist[p.To] = cost
+				hops[p.To] = hops[current] + 1
+				prev[p.To] = p
+			}
+		}
+	}
+
+	var steps []PatchInfo
+	for v := to; v != from; {
+		p := prev[v]
+		steps = append([]PatchInfo{p}, steps...)
+		v = p.From
+	}
+
+	return &PatchPlan{
+		From:      from,
+		To:        to,
+		Steps:     steps,
+		TotalSize: dist[to],
+		Full:      from == 0,
+	}
+}
@@ -0,0 +1,114 @@
+package patch
+
+import (
+	"fmt"
+	"testing"
+)
+
+func edge(from, to int, size int64) PatchInfo {
+	return PatchInfo{From: from, To: to, Key: fmt.Sprintf("%d_to_%d.pwr", from, to), Size: size}
+}
+
+func TestPlanPatchPath(t *testing.T) {
+	tests := []struct {
+		name      string
+		patches   []PatchInfo
+		from, to  int
+		wantSteps []string
+		wantSize  int64
+		wantFull  bool
+		wantErr   bool
+	}{
+		{
+			name:      "direct patch",
+			patches:   []PatchInfo{edge(0, 3, 1000), edge(2, 3, 40)},
+			from:      2,
+			to:        3,
+			wantSteps: []string{"2_to_3.pwr"},
+			wantSize:  40,
+		},
+		{
+			name:      "cheaper multi-hop path",
+			patches:   []PatchInfo{edge(0, 4, 1000), edge(1, 4, 300), edge(1, 2, 50), edge(2, 3, 50), edge(3, 4, 50)},
+			from:      1,
+			to:        4,
+			wantSteps: []string{"1_to_2.pwr", "2_to_3.pwr", "3_to_4.pwr"},
+			wantSize:  150,
+		},
+		{
+			name:      "fewer hops on a tie",
+			patches:   []PatchInfo{edge(1, 2, 50), edge(2, 3, 50), edge(1, 3, 100)},
+			from:      1,
+			to:        3,
+			wantSteps: []string{"1_to_3.pwr"},
+			wantSize:  100,
+		},
+		{
+			name:      "full install cheaper than the chain",
+			patches:   []PatchInfo{edge(0, 3, 100), edge(1, 2, 80), edge(2, 3, 80)},
+			from:      1,
+			to:        3,
+			wantSteps: []string{"0_to_3.pwr"},
+			wantSize:  100,
+			wantFull:  true,
+		},
+		{
+			name:      "latest build when no target",
+			patches:   []PatchInfo{edge(0, 2, 500), edge(2, 5, 60), edge(0, 5, 900)},
+			from:      2,
+			to:        0,
+			wantSteps: []string{"2_to_5.pwr"},
+			wantSize:  60,
+		},
+		{
+			name:      "downgrade patches are ignored",
+			patches:   []PatchInfo{edge(3, 1, 1), edge(1, 3, 70), edge(0, 3, 900)},
+			from:      1,
+			to:        3,
+			wantSteps: []string{"1_to_3.pwr"},
+			wantSize:  70,
+		},
+		{
+			name:    "unreachable",
+			patches: []PatchInfo{edge(1, 2, 10)},
+			from:    2,
+			to:      4,
+			wantErr: true,
+		},
+		{
+			name:    "no patches",
+			from:    1,
+			to:      2,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planPatchPath(tt.patches, tt.from, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			var steps []string
+			for _, s := range plan.Steps {
+				steps = append(steps, s.Key)
+			}
+			if fmt.Sprint(steps) != fmt.Sprint(tt.wantSteps) {
+				t.Errorf("steps = %v, want %v", steps, tt.wantSteps)
+			}
+			if plan.TotalSize != tt.wantSize || plan.Full != tt.wantFull {
+				t.Errorf("size = %d, full = %v, want %d, %v", plan.TotalSize, plan.Full, tt.wantSize, tt.wantFull)
+			}
+		})
+	}
+}
+
+func TestPlanPatchPathUpToDate(t *testing.T) {
+	plan, err := planPatchPath([]PatchInfo{edge(0, 3, 100)}, 3, 3)
+	if err != nil || len(plan.Steps) != 0 {
+		t.Fatalf("plan = %+v, %v", plan, err)
+	}
+}
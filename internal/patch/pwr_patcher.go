@@ -3,6 +3,7 @@ package patch
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -10,6 +11,8 @@ import (
 	"time"
 
 	"HyLauncher/internal/env"
+	"HyLauncher/internal/game"
+	"HyLauncher/internal/patch/wharf"
 	"HyLauncher/internal/platform"
 	"HyLauncher/internal/progress"
 	"HyLauncher/pkg/download"
@@ -29,6 +32,12 @@ type PatchStep struct {
 	PWR     string `json:"pwr"`
 	PWRHead string `json:"pwrHead"`
 	Sig     string `json:"sig"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+	// Key is the manifest path of the patch
+	Key string `json:"key,omitempty"`
+	// Mirrors lists every URL serving this patch, PWR first
+	Mirrors []string `json:"mirrors,omitempty"`
 }
 
 type PatchStepsResponse struct {
@@ -38,30 +47,35 @@ type PatchStepsResponse struct {
 func DownloadAndApplyPWR(ctx context.Context, branch string, currentVer int, targetVer int, versionDir string, reporter *progress.Reporter) error {
 	logger.Info("Starting patch download", "branch", branch, "from", currentVer, "to", targetVer)
 
-	var pwrPath string
-
-	steps, err := fetchPatchSteps(ctx, branch, currentVer)
+	steps, err := fetchPatchSteps(ctx, branch, currentVer, targetVer)
 	if err != nil {
 		logger.Error("Failed to fetch patch steps", "branch", branch, "error", err)
 		return fmt.Errorf("fetch patch steps: %w", err)
 	}
 
 	if len(steps) == 0 {
+		if currentVer == targetVer {
+			logger.Info("Already at target version", "branch", branch, "version", currentVer)
+			return nil
+		}
 		logger.Warn("No patch steps available", "branch", branch, "currentVer", currentVer)
 		return fmt.Errorf("no patch steps available")
 	}
 
+	// A full build only holds the files of the new version, applying it over
+	// an older install would leave the files that were dropped behind
+	if steps[0].From == 0 && currentVer > 0 {
+		if err := clearGameDir(env.GetGameDir(branch, versionDir)); err != nil {
+			return fmt.Errorf("prepare full install: %w", err)
+		}
+	}
+
 	logger.Info("Found patch steps", "count", len(steps), "branch", branch)
 	for i, step := range steps {
-		logger.Info("  Step", "index", i, "from", step.From, "to", step.To)
+		logger.Info("  Step", "index", i, "from", step.From, "to", step.To, "size", step.Size)
 	}
 
 	for i, step := range steps {
-		if targetVer > 0 && step.From >= targetVer {
-			logger.Info("Reached target version, stopping", "target", targetVer, "current", step.From)
-			break
-		}
-
 		logger.Info("Downloading patch", "from", step.From, "to", step.To, "progress", fmt.Sprintf("%d/%d", i+1, len(steps)))
 
 		if reporter != nil {
@@ -75,9 +89,14 @@ func DownloadAndApplyPWR(ctx context.Context, branch string, currentVer int, tar
 		}
 
 		logger.Info("Applying patch", "from", step.From, "to", step.To)
-		if err := applyPWR(ctx, pwrPath, sigPath, branch, versionDir, reporter); err != nil {
-			_ = os.Remove(pwrPath)
-			_ = os.Remove(sigPath)
+		pinPatch(pwrPath)
+		err = applyPWR(ctx, pwrPath, sigPath, branch, versionDir, reporter)
+		unpinPatch(pwrPath)
+		if err != nil {
+			// A file matching its pinned hash is fine, keep it for the retry
+			if step.SHA256 == "" {
+				_ = os.Remove(pwrPath)
+			}
 			logger.Error("Failed to apply patch", "from", step.From, "to", step.To, "error", err)
 			return fmt.Errorf("apply patch %d→%d: %w", step.From, step.To, err)
 		}
@@ -85,12 +104,33 @@ func DownloadAndApplyPWR(ctx context.Context, branch string, currentVer int, tar
 		logger.Info("Patch applied successfully", "from", step.From, "to", step.To)
 	}
 
-	_ = os.RemoveAll(pwrPath)
 	logger.Info("All patches applied", "totalSteps", len(steps))
+	TrimPatchCache()
 
 	return nil
 }
 
+// PrefetchPatches downloads the patch chain from currentVer to targetVer into
+// the patch cache without applying it, so a later DownloadAndApplyPWR only has
+// to apply. Returns the total size of the chain.
+func PrefetchPatches(ctx context.Context, branch string, currentVer int, targetVer int, reporter *progress.Reporter) (int64, error) {
+	steps, err := fetchPatchSteps(ctx, branch, currentVer, targetVer)
+	if err != nil {
+		return 0, fmt.Errorf("fetch patch steps: %w", err)
+	}
+
+	var total int64
+	for _, step := range steps {
+		logger.Info("Prefetching patch", "branch", branch, "from", step.From, "to", step.To, "size", step.Size)
+		if _, _, err := downloadPatchStep(ctx, step, reporter); err != nil {
+			return total, fmt.Errorf("prefetch patch %d→%d: %w", step.From, step.To, err)
+		}
+		total += step.Size
+	}
+
+	return total, nil
+}
+
 func applyPWR(ctx context.Context, pwrFile string, sigFile string, branch string, version string, reporter *progress.Reporter) error {
 	gameDir := env.GetGameDir(branch, version)
 	stagingDir := filepath.Join(env.GetCacheDir(), "staging-temp")
@@ -106,19 +146,102 @@ func applyPWR(ctx context.Context, pwrFile string, sigFile string, branch string
 		logger.Info("  File", "name", entry.Name(), "isDir", entry.IsDir())
 	}
 
+	err := applyPWRNative(ctx, pwrFile, gameDir, stagingDir, reporter)
+	if err == nil {
+		_ = os.RemoveAll(stagingDir)
+		if reporter != nil {
+			reporter.Report(progress.StagePatch, applyProgressEnd, "Game patched!")
+		}
+		return nil
+	}
+
+	// Butler cannot help once files were moved or the user gave up
+	if ctx.Err() != nil || errors.Is(err, wharf.ErrPartiallyApplied) {
+		_ = os.RemoveAll(stagingDir)
+		return fmt.Errorf("native patch apply failed: %w", err)
+	}
+
+	logger.Warn("Native patch apply failed, falling back to butler", "error", err)
+
+	if _, berr := GetButlerExec(); berr != nil {
+		_ = os.RemoveAll(stagingDir)
+		return fmt.Errorf("native patch apply failed: %w (butler unavailable: %v)", err, berr)
+	}
+
+	// Butler patches files in place, don't let it write through hardlinks into another slot
+	if uerr := game.UnlinkSlot(gameDir); uerr != nil {
+		_ = os.RemoveAll(stagingDir)
+		return fmt.Errorf("native patch apply failed: %w (prepare for butler: %v)", err, uerr)
+	}
+
+	return applyPWRButler(ctx, pwrFile, gameDir, stagingDir, reporter)
+}
+
+// applyPWRNative applies the patch with the built-in wharf decoder
+func applyPWRNative(ctx context.Context, pwrFile, gameDir, stagingDir string, reporter *progress.Reporter) error {
+	logger.Info("Applying patch natively", "pwr", pwrFile, "gameDir", gameDir, "stagingDir", stagingDir)
+
+	if reporter != nil {
+		reporter.Report(progress.StagePatch, applyProgressStart, "Applying game patch...")
+	}
+
+	started := time.Now()
+	lastReport := time.Time{}
+
+	err := wharf.Apply(ctx, wharf.Options{
+		PatchPath:  pwrFile,
+		GameDir:    gameDir,
+		StagingDir: stagingDir,
+		Progress: func(done, total int64) {
+			if reporter == nil || total <= 0 || time.Since(lastReport) < 200*time.Millisecond {
+				return
+			}
+			lastReport = time.Now()
+
+			frac := float64(done) / float64(total)
+			if frac > 1 {
+				frac = 1
+			}
+
+			speed, eta := "", ""
+			if elapsed := time.Since(started).Seconds(); elapsed > 0 && done > 0 {
+				bps := float64(done) / elapsed
+				speed = formatApplySpeed(bps)
+				eta = (time.Duration(float64(total-done)/bps) * time.Second).Round(time.Second).String()
+			}
+
+			prog := applyProgressStart + frac*(applyProgressEnd-applyProgressStart)
+			reporter.ReportWithETA(progress.StagePatch, prog, "Applying game patch...", speed, eta)
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Native patch apply completed", "duration", time.Since(started).Round(time.Second))
+	return nil
+}
+
+// applyPWRButler applies the patch by running butler
+func applyPWRButler(ctx context.Context, pwrFile, gameDir, stagingDir string, reporter *progress.Reporter) error {
 	butlerPath, err := GetButlerExec()
 	if err != nil {
 		return fmt.Errorf("cannot get butler: %w", err)
 	}
 
+	_ = os.RemoveAll(stagingDir)
+	_ = os.MkdirAll(stagingDir, 0755)
+
 	logger.Info("Running butler apply", "pwr", pwrFile, "gameDir", gameDir, "stagingDir", stagingDir)
 
 	// Create a timeout context for butler apply (30 minutes max)
 	applyCtx, cancel := context.WithTimeout(ctx, 30*time.Minute)
 	defer cancel()
 
-	// New API doesn't use signatures - butler apply without --signature flag
+	// Integrity is pinned by the manifest hash - butler apply without --signature flag.
+	// --json makes butler emit machine-readable progress/log events on stdout.
 	cmd := exec.CommandContext(applyCtx, butlerPath,
+		"--json",
 		"apply",
 		"--staging-dir", stagingDir,
 		pwrFile,
@@ -127,83 +250,46 @@ func applyPWR(ctx context.Context, pwrFile string, sigFile string, branch string
 
 	platform.HideConsoleWindow(cmd)
 
-	// Capture output for logging
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to attach butler output: %w", err)
+	}
+
+	var stderrBuf bytes.Buffer
 	cmd.Stderr = &stderrBuf
 
+	if reporter != nil {
+		reporter.Report(progress.StagePatch, applyProgressStart, "Applying game patch...")
+	}
+
 	// Start the command
 	if err := cmd.Start(); err != nil {
 		_ = os.RemoveAll(stagingDir)
 		return fmt.Errorf("failed to start butler: %w", err)
 	}
 
-	// Progress updater goroutine - sends periodic updates while butler is running
-	progressDone := make(chan struct{})
-	go func() {
-		defer close(progressDone)
-		ticker := time.NewTicker(2 * time.Second)
-		defer ticker.Stop()
-
-		prog := 60.0
-		direction := 0.5 // Increment to show activity
-
-		for {
-			select {
-			case <-ticker.C:
-				if reporter != nil {
-					// Oscillate progress between 60% and 75% to show activity
-					prog += direction
-					if prog >= 75.0 {
-						prog = 75.0
-						direction = -0.5
-					} else if prog <= 60.0 {
-						prog = 60.0
-						direction = 0.5
-					}
-					reporter.Report(progress.StagePatch, prog, "Applying game patch...")
-				}
-			case <-applyCtx.Done():
-				return
-			}
-		}
-	}()
-
-	// Wait for command to complete
+	// Stream events until butler closes stdout, then wait for the exit status
+	output := streamButlerEvents(stdout, reporter)
 	err = cmd.Wait()
 
-	// Stop progress updater
-	cancel()
-	<-progressDone
-
 	if err != nil {
 		_ = os.RemoveAll(stagingDir)
-		stdoutStr := stdoutBuf.String()
-		stderrStr := stderrBuf.String()
-		logger.Error("Butler apply failed", "error", err, "stdout", stdoutStr, "stderr", stderrStr)
-
-		// Check for timeout
-		if applyCtx.Err() == context.DeadlineExceeded {
-			return fmt.Errorf("butler apply timed out after 30 minutes - the patch may be too large or stuck")
-		}
-
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			return fmt.Errorf("butler apply failed with exit code %d: stderr=%s", exitErr.ExitCode(), stderrStr)
-		}
-		return fmt.Errorf("butler apply failed: %w", err)
+		appErr := classifyButlerError(err, applyCtx, output, stderrBuf.String())
+		logger.Error("Butler apply failed", "error", err, "category", appErr.Category, "details", appErr.Details, "stderr", stderrBuf.String())
+		return appErr
 	}
 
-	logger.Info("Butler apply completed", "stdout", stdoutBuf.String())
+	logger.Info("Butler apply completed")
 
 	_ = os.RemoveAll(stagingDir)
 
 	if reporter != nil {
-		reporter.Report(progress.StagePatch, 80, "Game patched!")
+		reporter.Report(progress.StagePatch, applyProgressEnd, "Game patched!")
 	}
 	return nil
 }
 
-func fetchPatchSteps(ctx context.Context, branch string, currentVer int) ([]PatchStep, error) {
+func fetchPatchSteps(ctx context.Context, branch string, currentVer int, targetVer int) ([]PatchStep, error) {
 	// Use the new manifest-based API from version.go
 	manifest, err := fetchManifest()
 	if err != nil {
@@ -215,84 +301,115 @@ func fetchPatchSteps(ctx context.Context, branch string, currentVer int) ([]Patc
 		return nil, fmt.Errorf("no patches available for platform")
 	}
 
-	baseURL, _ := fetchPatchesConfigWithFallback()
+	plan, err := planPatchPath(patches, currentVer, targetVer)
+	if err != nil {
+		return nil, err
+	}
 
-	// Build patch steps - find patches starting from currentVer
-	var steps []PatchStep
-	for _, p := range patches {
-		if p.From == currentVer {
-			steps = append(steps, PatchStep{
-				From:    p.From,
-				To:      p.To,
-				PWR:     fmt.Sprintf("%s/%s", baseURL, p.Key),
-				PWRHead: "",
-				Sig:     "",
-			})
-		}
+	if plan.Full && currentVer > 0 {
+		logger.Info("Full install is cheaper than incremental update", "from", currentVer, "to", plan.To, "size", plan.TotalSize)
+	} else {
+		logger.Info("Planned patch path", "from", plan.From, "to", plan.To, "hops", len(plan.Steps), "size", plan.TotalSize)
 	}
 
-	// If no incremental patches found, look for full patch (0 -> target)
-	if len(steps) == 0 {
-		for _, p := range patches {
-			if p.From == 0 {
-				steps = append(steps, PatchStep{
-					From:    p.From,
-					To:      p.To,
-					PWR:     fmt.Sprintf("%s/%s", baseURL, p.Key),
-					PWRHead: "",
-					Sig:     "",
-				})
-			}
-		}
+	mirrors := fetchPatchesMirrors()
+	if len(mirrors) == 0 {
+		return nil, fmt.Errorf("no patches mirror configured")
 	}
 
-	// When starting from version 0, only use the patch with highest "to" version
-	// Don't apply multiple full patches - just use the latest one
-	if currentVer == 0 && len(steps) > 1 {
-		// Find the step with highest "To" value
-		var bestStep PatchStep
-		for _, s := range steps {
-			if s.To > bestStep.To {
-				bestStep = s
-			}
+	steps := make([]PatchStep, 0, len(plan.Steps))
+	for _, p := range plan.Steps {
+		urls := make([]string, 0, len(mirrors))
+		for _, m := range mirrors {
+			urls = append(urls, fmt.Sprintf("%s/%s", m, p.Key))
 		}
-		steps = []PatchStep{bestStep}
-		logger.Info("Selected latest full patch", "from", bestStep.From, "to", bestStep.To)
+
+		steps = append(steps, PatchStep{
+			From:    p.From,
+			To:      p.To,
+			PWR:     urls[0],
+			PWRHead: "",
+			Sig:     "",
+			Size:    p.Size,
+			SHA256:  p.SHA256,
+			Key:     p.Key,
+			Mirrors: urls,
+		})
 	}
 
 	return steps, nil
 }
 
-func downloadPatchStep(ctx context.Context, step PatchStep, reporter *progress.Reporter) (pwrPath string, sigPath string, err error) {
-	cacheDir := env.GetCacheDir()
-	_ = os.MkdirAll(cacheDir, 0755)
+// clearGameDir empties dir before a full install. Entries are removed rather
+// than rewritten, so files hardlinked into another slot stay intact there.
+func clearGameDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	logger.Info("Clearing game directory for full install", "dir", dir)
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+func downloadPatchStep(ctx context.Context, step PatchStep, reporter *progress.Reporter) (pwrPath string, sigPath string, err error) {
+	pwrDest := patchCachePath(step)
 	pwrFileName := fmt.Sprintf("%d_to_%d.pwr", step.From, step.To)
-	pwrDest := filepath.Join(cacheDir, pwrFileName)
 
-	// Check if PWR file is already cached
-	if _, err := os.Stat(pwrDest); err == nil {
+	if cached, ok := lookupCachedPatch(step); ok {
 		if reporter != nil {
 			reporter.Report(progress.StagePWR, 100, "Patch file cached")
 		}
-		// Return empty sigPath since new API doesn't use signatures
-		return pwrDest, "", nil
+		// Return empty sigPath, integrity is pinned by the manifest hash
+		return cached, "", nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(pwrDest), 0755); err != nil {
+		return "", "", fmt.Errorf("create patch cache: %w", err)
 	}
 
+	// Make room before downloading, and keep our own .part out of the eviction
+	pinPatch(pwrDest)
+	defer unpinPatch(pwrDest)
+	TrimPatchCache()
+
 	if reporter != nil {
 		reporter.Report(progress.StagePWR, 0, fmt.Sprintf("Downloading patch %d→%d...", step.From, step.To))
 	}
 
 	pwrScaler := progress.NewScaler(reporter, progress.StagePWR, 0, 100)
-	if err := download.DownloadWithReporter(ctx, pwrDest, step.PWR, pwrFileName, reporter, progress.StagePWR, pwrScaler); err != nil {
-		_ = os.Remove(pwrDest + ".tmp")
+	mirrors := step.Mirrors
+	if len(mirrors) == 0 {
+		mirrors = []string{step.PWR}
+	}
+
+	// The .part file is left in place on failure so the next attempt resumes it
+	if err := download.DownloadFromMirrors(ctx, pwrDest, mirrors, pwrFileName, reporter, progress.StagePWR, pwrScaler); err != nil {
 		return "", "", fmt.Errorf("download PWR: %w", err)
 	}
 
+	if reporter != nil {
+		reporter.Report(progress.StagePWR, 100, "Verifying patch file...")
+	}
+
+	if err := verifyPatchFile(pwrDest, step); err != nil {
+		_ = os.Remove(pwrDest)
+		logger.Error("Patch file rejected", "from", step.From, "to", step.To, "error", err)
+		return "", "", fmt.Errorf("verify PWR: %w", err)
+	}
+
 	if reporter != nil {
 		reporter.Report(progress.StagePWR, 100, "Patch file downloaded")
 	}
 
-	// Return empty sigPath since new API doesn't use signatures
+	// Return empty sigPath, integrity is pinned by the manifest hash
 	return pwrDest, "", nil
 }
@@ -0,0 +1,162 @@
+package patch
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"HyLauncher/internal/env"
+	"HyLauncher/internal/patch/wharf"
+	"HyLauncher/internal/progress"
+	"HyLauncher/internal/verify"
+	"HyLauncher/pkg/download"
+	"HyLauncher/pkg/fileutil"
+	"HyLauncher/pkg/logger"
+	"HyLauncher/pkg/model"
+)
+
+// RepairSources returns where single files of an installed build can be
+// fetched from when verification finds them damaged: the per-file content
+// endpoint of the patch mirrors, then the cached full-build patch
+func RepairSources(request model.InstanceModel) []verify.RepairSource {
+	build := InstalledBuild(request)
+	if build == 0 {
+		// Without a build number only backups can help
+		return nil
+	}
+	return []verify.RepairSource{
+		&contentSource{branch: request.Branch, build: build},
+		&cachedPatchSource{branch: request.Branch, build: build},
+	}
+}
+
+// contentMissLimit is how many files in a row the content endpoint may fail
+// to serve before it is given up on, so an offline mirror doesn't stall repair
+const contentMissLimit = 3
+
+// contentSource downloads files one by one from
+// <mirror>/files/<os>/<arch>/<branch>/<build>/<path>
+type contentSource struct {
+	branch string
+	build  int
+}
+
+func (c *contentSource) Name() string {
+	return "content"
+}
+
+func (c *contentSource) Fetch(ctx context.Context, files []verify.RepairFile) error {
+	mirrors := fetchPatchesMirrors()
+	prefix := fmt.Sprintf("files/%s/%s/%s/%d", env.GetOS(), env.GetArchForAPI(), c.branch, c.build)
+
+	misses := 0
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if misses >= contentMissLimit {
+			return fmt.Errorf("content endpoint unavailable")
+		}
+
+		urls := make([]string, 0, len(mirrors))
+		for _, m := range mirrors {
+			urls = append(urls, fmt.Sprintf("%s/%s/%s", m, prefix, escapePath(f.Path)))
+		}
+
+		if err := download.DownloadFromMirrors(ctx, f.Dest, urls, path.Base(f.Path), nil, progress.StageVerify, nil); err != nil {
+			logger.Debug("Content endpoint could not provide file", "file", f.Path, "error", err)
+			removePartial(f.Dest)
+			misses++
+			continue
+		}
+		misses = 0
+	}
+	return nil
+}
+
+// cachedPatchSource takes files out of the full-build patch of the build, if
+// it is still in the patch cache
+type cachedPatchSource struct {
+	branch string
+	build  int
+}
+
+func (c *cachedPatchSource) Name() string {
+	return "cached patch"
+}
+
+func (c *cachedPatchSource) Fetch(ctx context.Context, files []verify.RepairFile) error {
+	manifest, err := fetchManifest()
+	if err != nil {
+		return fmt.Errorf("fetch manifest: %w", err)
+	}
+
+	var step *PatchStep
+	for _, p := range getPlatformPatches(manifest, c.branch) {
+		if p.From == 0 && p.To == c.build {
+			step = &PatchStep{From: p.From, To: p.To, Size: p.Size, SHA256: p.SHA256, Key: p.Key}
+			break
+		}
+	}
+	if step == nil {
+		return fmt.Errorf("no full patch for build %d", c.build)
+	}
+
+	pwrPath, ok := lookupCachedPatch(*step)
+	if !ok {
+		return fmt.Errorf("full patch for build %d is not cached", c.build)
+	}
+	pinPatch(pwrPath)
+	defer unpinPatch(pwrPath)
+
+	extractDir := filepath.Join(env.GetCacheDir(), "repair-extract")
+	_ = os.RemoveAll(extractDir)
+	defer os.RemoveAll(extractDir)
+
+	paths := make([]string, len(files))
+	for i, f := range files {
+		paths[i] = f.Path
+	}
+
+	logger.Info("Extracting files from cached patch", "patch", pwrPath, "files", len(paths))
+	missing, err := wharf.Extract(ctx, pwrPath, extractDir, paths, nil)
+	if err != nil {
+		return fmt.Errorf("extract from %s: %w", filepath.Base(pwrPath), err)
+	}
+	if len(missing) > 0 {
+		logger.Warn("Cached patch lacks files", "files", missing)
+	}
+
+	for _, f := range files {
+		extracted := filepath.Join(extractDir, filepath.FromSlash(f.Path))
+		if !fileutil.FileExists(extracted) {
+			continue
+		}
+		if err := fileutil.MoveFile(extracted, f.Dest); err != nil {
+			logger.Warn("Failed to stage extracted file", "file", f.Path, "error", err)
+		}
+	}
+	return nil
+}
+
+// escapePath escapes each segment of a slash-separated path for a URL
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// removePartial drops what a failed download left next to dest
+func removePartial(dest string) {
+	_ = os.Remove(dest)
+	matches, _ := filepath.Glob(dest + ".part*")
+	for _, m := range matches {
+		_ = os.Remove(m)
+	}
+}
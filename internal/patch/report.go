@@ -0,0 +1,139 @@
+package patch
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"HyLauncher/internal/env"
+)
+
+// PatchChange is a single replacement in a patched file
+type PatchChange struct {
+	Rule     string       `json:"rule"`
+	Encoding RuleEncoding `json:"encoding"`
+	// Entry is the JAR entry the change is in, empty for the client binary
+	Entry string `json:"entry,omitempty"`
+	// Offset in the original file, or in the JAR entry
+	Offset int64 `json:"offset"`
+	// Before and After are hex encoded bytes
+	Before string `json:"before"`
+	After  string `json:"after"`
+	// Matches counts substring replacements inside one string, 1 otherwise
+	Matches int `json:"matches"`
+}
+
+// FileReport lists the changes made (or that would be made) to one file
+type FileReport struct {
+	Path    string        `json:"path"`
+	Target  string        `json:"target"`
+	Written bool          `json:"written"`
+	Changes []PatchChange `json:"changes"`
+	Error   string        `json:"error,omitempty"`
+
+	// UpToDate is set when the patch state showed nothing to do
+	UpToDate bool `json:"upToDate,omitempty"`
+	// FromBackup is set when the file was re-patched from its .original backup
+	FromBackup bool `json:"fromBackup,omitempty"`
+}
+
+// PatchReport is the audit trail of a patch run
+type PatchReport struct {
+	Branch       string       `json:"branch"`
+	Version      string       `json:"version"`
+	Build        int          `json:"build"`
+	Domain       string       `json:"domain"`
+	DryRun       bool         `json:"dryRun"`
+	CreatedAt    time.Time    `json:"createdAt"`
+	RulesVersion int          `json:"rulesVersion"`
+	RulesSource  string       `json:"rulesSource"`
+	Files        []FileReport `json:"files"`
+	Rules        []RuleResult `json:"rules"`
+	Skipped      []string     `json:"skipped,omitempty"`
+}
+
+// Matched returns the ids of the rules that matched at least once
+func (r *PatchReport) Matched() []string {
+	var ids []string
+	for _, rule := range r.Rules {
+		if rule.Matches > 0 {
+			ids = append(ids, rule.ID)
+		}
+	}
+	return ids
+}
+
+func newChange(rule PatchRule, entry string, offset int, before, after []byte, matches int) PatchChange {
+	return PatchChange{
+		Rule:     rule.ID,
+		Encoding: rule.Encoding,
+		Entry:    entry,
+		Offset:   int64(offset),
+		Before:   hex.EncodeToString(before),
+		After:    hex.EncodeToString(after),
+		Matches:  matches,
+	}
+}
+
+// ruleResults sums the changes per rule, keeping the rule order
+func ruleResults(rules []PatchRule, changes []PatchChange) []RuleResult {
+	results := make([]RuleResult, len(rules))
+	index := make(map[string]int, len(rules))
+	for i, rule := range rules {
+		results[i] = RuleResult{ID: rule.ID, Target: rule.Target}
+		index[rule.ID] = i
+	}
+	for _, c := range changes {
+		if i, ok := index[c.Rule]; ok {
+			results[i].Matches += c.Matches
+		}
+	}
+	return results
+}
+
+// PatchReportPath returns where the report of an instance is kept. Installs
+// that are not tied to an instance report to the launcher logs.
+func PatchReportPath(instanceID string, dryRun bool) string {
+	name := "patch-report.json"
+	if dryRun {
+		name = "patch-report-dry-run.json"
+	}
+	if instanceID == "" {
+		return filepath.Join(env.GetDefaultAppDir(), "logs", name)
+	}
+	return filepath.Join(env.GetInstanceLogsDir(instanceID), name)
+}
+
+// SavePatchReport writes the report next to the instance logs
+func SavePatchReport(report *PatchReport, instanceID string) (string, error) {
+	path := PatchReportPath(instanceID, report.DryRun)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("create report dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode report: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", fmt.Errorf("write report: %w", err)
+	}
+	return path, nil
+}
+
+// LoadPatchReport reads the last saved report of an instance
+func LoadPatchReport(instanceID string, dryRun bool) (*PatchReport, error) {
+	data, err := os.ReadFile(PatchReportPath(instanceID, dryRun))
+	if err != nil {
+		return nil, err
+	}
+
+	var report PatchReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parse report: %w", err)
+	}
+	return &report, nil
+}
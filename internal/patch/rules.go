@@ -0,0 +1,342 @@
+package patch
+
+import (
+	"context"
+	"crypto/ed25519"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"HyLauncher/internal/bootstrap"
+	"HyLauncher/internal/config"
+	"HyLauncher/internal/env"
+	"HyLauncher/pkg/logger"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// RuleEncoding names how a string is stored in the patched file
+type RuleEncoding string
+
+const (
+	// EncodingLengthPrefixed is the .NET single-file bundle layout, see StringToLengthPrefixed
+	EncodingLengthPrefixed RuleEncoding = "length-prefixed"
+	// EncodingUTF16LE covers null-terminated and length-prefixed UTF-16LE strings
+	EncodingUTF16LE RuleEncoding = "utf16le"
+	// EncodingUTF8 is plain UTF-8, used for JAR entries
+	EncodingUTF8 RuleEncoding = "utf8"
+)
+
+const (
+	RuleTargetClient = "client"
+	RuleTargetServer = "server"
+)
+
+const (
+	patchRulesFileName = "patch-rules.json"
+	rulesCacheTTL      = 5 * time.Minute
+)
+
+//go:embed default_rules.json
+var defaultRulesJSON []byte
+
+// PatchRule is a single string replacement. Replacement may use {domain}
+// for the patcher's target domain.
+type PatchRule struct {
+	ID          string       `json:"id" toml:"id"`
+	Target      string       `json:"target" toml:"target"`
+	Encoding    RuleEncoding `json:"encoding" toml:"encoding"`
+	Pattern     string       `json:"pattern" toml:"pattern"`
+	Replacement string       `json:"replacement" toml:"replacement"`
+
+	// Builds the rule applies to. Empty Builds and zero bounds mean every build.
+	Builds   []int `json:"builds,omitempty" toml:"builds,omitempty"`
+	MinBuild int   `json:"minBuild,omitempty" toml:"min_build,omitempty"`
+	MaxBuild int   `json:"maxBuild,omitempty" toml:"max_build,omitempty"`
+}
+
+// RuleSet is the content of a rule file
+type RuleSet struct {
+	Version int         `json:"version" toml:"version"`
+	Rules   []PatchRule `json:"rules" toml:"rules"`
+
+	// Source tells where the rules were loaded from (override, remote, cache, builtin)
+	Source string `json:"-" toml:"-"`
+}
+
+// RuleResult records how often a rule matched while patching
+type RuleResult struct {
+	ID      string `json:"id"`
+	Target  string `json:"target"`
+	Matches int    `json:"matches"`
+}
+
+// AppliesTo reports whether the rule is meant for build. Rules restricted to
+// specific builds are skipped when the build is unknown (0).
+func (r PatchRule) AppliesTo(build int) bool {
+	restricted := len(r.Builds) > 0 || r.MinBuild > 0 || r.MaxBuild > 0
+	if !restricted {
+		return true
+	}
+	if build <= 0 {
+		return false
+	}
+	if len(r.Builds) > 0 {
+		return slices.Contains(r.Builds, build)
+	}
+	if r.MinBuild > 0 && build < r.MinBuild {
+		return false
+	}
+	if r.MaxBuild > 0 && build > r.MaxBuild {
+		return false
+	}
+	return true
+}
+
+// ForBuild returns the rules of target that apply to build, in file order
+func (rs *RuleSet) ForBuild(target string, build int) []PatchRule {
+	var out []PatchRule
+	for _, r := range rs.Rules {
+		if r.Target == target && r.AppliesTo(build) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (rs *RuleSet) validate() error {
+	if len(rs.Rules) == 0 {
+		return fmt.Errorf("rule file has no rules")
+	}
+
+	seen := make(map[string]bool, len(rs.Rules))
+	for i, r := range rs.Rules {
+		if r.ID == "" {
+			return fmt.Errorf("rule %d has no id", i)
+		}
+		if seen[r.ID] {
+			return fmt.Errorf("duplicate rule id %q", r.ID)
+		}
+		seen[r.ID] = true
+
+		if r.Target != RuleTargetClient && r.Target != RuleTargetServer {
+			return fmt.Errorf("rule %q: unknown target %q", r.ID, r.Target)
+		}
+		switch r.Encoding {
+		case EncodingLengthPrefixed, EncodingUTF16LE, EncodingUTF8:
+		default:
+			return fmt.Errorf("rule %q: unknown encoding %q", r.ID, r.Encoding)
+		}
+		if r.Pattern == "" || r.Replacement == "" {
+			return fmt.Errorf("rule %q: empty pattern or replacement", r.ID)
+		}
+		if r.MinBuild > 0 && r.MaxBuild > 0 && r.MinBuild > r.MaxBuild {
+			return fmt.Errorf("rule %q: minBuild above maxBuild", r.ID)
+		}
+	}
+	return nil
+}
+
+// parseRuleSet decodes a rule file, TOML when name ends in .toml and JSON otherwise
+func parseRuleSet(raw []byte, name string) (*RuleSet, error) {
+	var rs RuleSet
+	var err error
+	if strings.HasSuffix(strings.ToLower(name), ".toml") {
+		err = toml.Unmarshal(raw, &rs)
+	} else {
+		err = json.Unmarshal(raw, &rs)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", name, err)
+	}
+	if err := rs.validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	return &rs, nil
+}
+
+// rulesPublicKey returns the key rule files are signed with. Without one no
+// rule file is trusted and the builtin rules apply.
+func rulesPublicKey() (ed25519.PublicKey, error) {
+	key, err := bootstrap.ParsePublicKey(config.GetPatchRulesPublicKeyHex())
+	if err != nil {
+		return nil, fmt.Errorf("patch rules %w", err)
+	}
+	return key, nil
+}
+
+// verifyRuleFile checks the detached signature of a rule file
+func verifyRuleFile(raw, sig []byte) error {
+	key, err := rulesPublicKey()
+	if err != nil {
+		return err
+	}
+	if len(sig) == 0 {
+		return fmt.Errorf("rule file is not signed")
+	}
+	return bootstrap.VerifyMetadataSignature(key, raw, sig)
+}
+
+var (
+	rulesMu    sync.Mutex
+	rulesCache *RuleSet
+	rulesSet   time.Time
+)
+
+// LoadPatchRules returns the active rule set. A signed override in the app dir
+// wins, then the rules published next to the patches, then the last good copy
+// on disk, then the rules built into the launcher. Without a signing key only
+// the builtin rules are used.
+func LoadPatchRules(ctx context.Context) *RuleSet {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+
+	if rulesCache != nil && time.Since(rulesSet) < rulesCacheTTL {
+		return rulesCache
+	}
+
+	var rs *RuleSet
+	if _, err := rulesPublicKey(); err != nil {
+		logger.Warn("Using builtin patch rules", "error", err)
+	} else {
+		rs = loadOverrideRules()
+		if rs == nil {
+			rs = fetchRemoteRules(ctx)
+		}
+		if rs == nil {
+			rs = loadCachedRules()
+		}
+	}
+	if rs == nil {
+		rs = builtinRules()
+	}
+
+	logger.Info("Loaded patch rules", "source", rs.Source, "version", rs.Version, "rules", len(rs.Rules))
+	rulesCache = rs
+	rulesSet = time.Now()
+	return rs
+}
+
+func builtinRules() *RuleSet {
+	rs, err := parseRuleSet(defaultRulesJSON, patchRulesFileName)
+	if err != nil {
+		// The embedded file is part of the build, this is a programming error
+		panic(fmt.Errorf("builtin patch rules: %w", err))
+	}
+	rs.Source = "builtin"
+	return rs
+}
+
+func loadOverrideRules() *RuleSet {
+	for _, name := range []string{"patch-rules.toml", patchRulesFileName} {
+		path := filepath.Join(env.GetDefaultAppDir(), name)
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		sig, _ := os.ReadFile(path + ".sig")
+		if err := verifyRuleFile(raw, sig); err != nil {
+			logger.Warn("Ignoring patch rules override", "path", path, "error", err)
+			continue
+		}
+
+		rs, err := parseRuleSet(raw, name)
+		if err != nil {
+			logger.Warn("Ignoring patch rules override", "path", path, "error", err)
+			continue
+		}
+		rs.Source = "override"
+		return rs
+	}
+	return nil
+}
+
+func fetchRemoteRules(ctx context.Context) *RuleSet {
+	baseURL, err := fetchPatchesConfigWithFallback()
+	if err != nil {
+		return nil
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	raw, err := fetchRuleAsset(ctx, client, baseURL+"/"+patchRulesFileName, 1<<20)
+	if err != nil {
+		logger.Debug("Remote patch rules unavailable", "error", err)
+		return nil
+	}
+
+	sig, err := fetchRuleAsset(ctx, client, baseURL+"/"+patchRulesFileName+".sig", 512)
+	if err != nil {
+		logger.Warn("Remote patch rules signature unavailable", "error", err)
+		return nil
+	}
+
+	if err := verifyRuleFile(raw, sig); err != nil {
+		logger.Error("Remote patch rules rejected", "error", err)
+		return nil
+	}
+
+	rs, err := parseRuleSet(raw, patchRulesFileName)
+	if err != nil {
+		logger.Error("Remote patch rules rejected", "error", err)
+		return nil
+	}
+
+	dir := env.GetManifestCacheDir()
+	if err := writeFileAtomic(filepath.Join(dir, patchRulesFileName), raw); err != nil {
+		logger.Warn("Failed to cache patch rules", "error", err)
+	} else {
+		_ = writeFileAtomic(filepath.Join(dir, patchRulesFileName+".sig"), sig)
+	}
+
+	rs.Source = "remote"
+	return rs
+}
+
+func fetchRuleAsset(ctx context.Context, client *http.Client, url string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+func loadCachedRules() *RuleSet {
+	path := filepath.Join(env.GetManifestCacheDir(), patchRulesFileName)
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+
+	sig, _ := os.ReadFile(path + ".sig")
+	if err := verifyRuleFile(raw, sig); err != nil {
+		logger.Warn("Ignoring cached patch rules", "error", err)
+		return nil
+	}
+
+	rs, err := parseRuleSet(raw, patchRulesFileName)
+	if err != nil {
+		logger.Warn("Ignoring cached patch rules", "error", err)
+		return nil
+	}
+	rs.Source = "cache"
+	return rs
+}
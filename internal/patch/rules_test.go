@@ -0,0 +1,88 @@
+package patch
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"HyLauncher/internal/config"
+	"HyLauncher/internal/env"
+)
+
+// withRulesKey embeds keyHex as the rules key and points the app dir at a
+// temp dir holding a cached rule file signed with sign
+func withRulesKey(t *testing.T, keyHex string, sign ed25519.PrivateKey) {
+	t.Helper()
+	oldRules, oldManifest := config.PatchRulesPublicKeyHex, config.PatchManifestPublicKeyHex
+	config.PatchRulesPublicKeyHex, config.PatchManifestPublicKeyHex = keyHex, ""
+	t.Cleanup(func() {
+		config.PatchRulesPublicKeyHex, config.PatchManifestPublicKeyHex = oldRules, oldManifest
+		rulesCache = nil
+	})
+	rulesCache = nil
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	dir := env.GetManifestCacheDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, patchRulesFileName)
+	if err := os.WriteFile(path, defaultRulesJSON, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if sign != nil {
+		if err := os.WriteFile(path+".sig", ed25519.Sign(sign, defaultRulesJSON), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadCachedRules(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, other, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key  string
+		sign ed25519.PrivateKey
+		want bool
+	}{
+		{"signed", hex.EncodeToString(pub), priv, true},
+		{"unsigned", hex.EncodeToString(pub), nil, false},
+		{"signed by another key", hex.EncodeToString(pub), other, false},
+		{"no key", "", priv, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withRulesKey(t, tt.key, tt.sign)
+			rs := loadCachedRules()
+			if (rs != nil) != tt.want {
+				t.Fatalf("loadCachedRules = %v, want loaded %v", rs, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadPatchRulesWithoutKeyUsesBuiltin(t *testing.T) {
+	_, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	withRulesKey(t, "", priv)
+
+	if rs := LoadPatchRules(context.Background()); rs.Source != "builtin" {
+		t.Fatalf("source = %q, want builtin", rs.Source)
+	}
+}
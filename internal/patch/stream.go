@@ -0,0 +1,196 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"HyLauncher/pkg/logger"
+)
+
+// streamChunkSize is how much of a file is read at a time while patching
+const streamChunkSize = 1 << 20
+
+// errReplacementTooLong means a byte-level rule matched but its replacement
+// doesn't fit in place of the pattern, so the file would be half patched
+var errReplacementTooLong = errors.New("replacement longer than pattern")
+
+// ruleWriter replaces every occurrence of old in the bytes written to it and
+// passes the result on to next. Only len(old)-1 bytes are held back between
+// writes, enough to find a match split across two of them, so memory does not
+// grow with the input.
+type ruleWriter struct {
+	next     io.Writer
+	rule     PatchRule
+	entry    string
+	old, new []byte
+
+	// tooLong stages only check that old no longer occurs, new doesn't fit
+	tooLong bool
+
+	pending []byte
+	off     int64 // input offset of pending[0]
+	changes []PatchChange
+}
+
+func (rw *ruleWriter) Write(p []byte) (int, error) {
+	rw.pending = append(rw.pending, p...)
+	if err := rw.drain(len(rw.old) - 1); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close writes out the held back bytes
+func (rw *ruleWriter) Close() error {
+	return rw.drain(0)
+}
+
+func (rw *ruleWriter) drain(keep int) error {
+	buf := rw.pending
+	pos := 0
+	for {
+		i := bytes.Index(buf[pos:], rw.old)
+		if i < 0 {
+			break
+		}
+		at := pos + i
+		if rw.tooLong {
+			return fmt.Errorf("rule %s at offset %d: %w (%d > %d bytes)",
+				rw.rule.ID, rw.off+int64(at), errReplacementTooLong, len(rw.new), len(rw.old))
+		}
+		if _, err := rw.next.Write(buf[pos:at]); err != nil {
+			return err
+		}
+		if _, err := rw.next.Write(rw.new); err != nil {
+			return err
+		}
+		rw.changes = append(rw.changes, newChange(rw.rule, rw.entry, int(rw.off)+at, rw.old, rw.new, 1))
+		pos = at + len(rw.old)
+	}
+
+	// Nothing left in buf matches, a match can only start in the last keep bytes
+	if safe := len(buf) - keep; pos < safe {
+		if _, err := rw.next.Write(buf[pos:safe]); err != nil {
+			return err
+		}
+		pos = safe
+	}
+
+	rw.off += int64(pos)
+	rw.pending = append(rw.pending[:0], buf[pos:]...)
+	return nil
+}
+
+// chainRules links the stages so each writes into the next and the last into
+// w, and returns the writer to feed
+func chainRules(w io.Writer, stages []*ruleWriter) io.Writer {
+	for i := len(stages) - 1; i >= 0; i-- {
+		stages[i].next = w
+		w = stages[i]
+	}
+	return w
+}
+
+// closeRules flushes the stages in order and returns their changes in rule order
+func closeRules(stages []*ruleWriter) ([]PatchChange, error) {
+	var changes []PatchChange
+	for _, s := range stages {
+		if err := s.Close(); err != nil {
+			return nil, err
+		}
+		changes = append(changes, s.changes...)
+	}
+	return changes, nil
+}
+
+// imageRules turns the rules into in-place stages. Replacements are padded
+// with zeros to the pattern length, so offsets are the same in every stage.
+// A replacement longer than its pattern can't be made in place; its stage
+// fails the patch if the pattern is still found, e.g. in a native client
+// whose strings are not rewritten through the metadata.
+func (cp *ClientPatcher) imageRules(rules []PatchRule) []*ruleWriter {
+	var stages []*ruleWriter
+	for _, rule := range rules {
+		replacement := strings.ReplaceAll(rule.Replacement, "{domain}", cp.targetDomain)
+
+		var old, new []byte
+		switch rule.Encoding {
+		case EncodingLengthPrefixed:
+			old = cp.StringToLengthPrefixed(rule.Pattern)
+			new = cp.StringToLengthPrefixed(replacement)
+		case EncodingUTF16LE:
+			// The high byte of the last character is left out, see FindAndReplaceDomainSmart
+			old = encodeUTF16(rule.Pattern)
+			old = old[:len(old)-1]
+			new = encodeUTF16(replacement)
+			new = new[:len(new)-1]
+		case EncodingUTF8:
+			old = []byte(rule.Pattern)
+			new = []byte(replacement)
+		default:
+			logger.Warn("Unknown patch rule encoding, skipping", "rule", rule.ID, "encoding", rule.Encoding)
+			continue
+		}
+
+		if len(new) > len(old) {
+			stages = append(stages, &ruleWriter{rule: rule, old: old, new: new, tooLong: true})
+			continue
+		}
+		padded := make([]byte, len(old))
+		copy(padded, new)
+
+		stages = append(stages, &ruleWriter{rule: rule, old: old, new: padded})
+	}
+	return stages
+}
+
+// patchImage streams a client image from src to w. String literals of managed
+// images are rewritten through the metadata first, then the byte-level rules
+// run over the result. Memory is bounded by the metadata size and the
+// chunk size, not by the image.
+func (cp *ClientPatcher) patchImage(src io.ReaderAt, size int64, w io.Writer, rules []PatchRule) ([]PatchChange, error) {
+	logger.Debug("Applying domain patches", "from", originalDomain, "to", cp.targetDomain, "rules", len(rules))
+
+	var changes []PatchChange
+	var in io.Reader = io.NewSectionReader(src, 0, size)
+
+	edit, err := planUserStrings(src, size, func(offset int, str string) string {
+		updated, c := cp.rewriteUserString(offset, str, rules)
+		changes = append(changes, c...)
+		return updated
+	})
+	switch {
+	case err == nil:
+		if edit != nil {
+			in = edit.reader(src)
+		}
+	case errors.Is(err, errNotManaged):
+		changes = nil
+	default:
+		logger.Warn("Could not rewrite managed string literals, falling back to in-place patching", "error", err)
+		changes = nil
+	}
+
+	stages := cp.imageRules(rules)
+	if _, err := io.CopyBuffer(chainRules(w, stages), in, make([]byte, streamChunkSize)); err != nil {
+		return nil, err
+	}
+	byteChanges, err := closeRules(stages)
+	if err != nil {
+		return nil, err
+	}
+	return append(changes, byteChanges...), nil
+}
+
+// countWriter counts the bytes written to it
+type countWriter struct {
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	cw.n += int64(len(p))
+	return len(p), nil
+}
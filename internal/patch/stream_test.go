@@ -0,0 +1,99 @@
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestRuleWriterSplitWrites(t *testing.T) {
+	in := []byte("head hytale.com mid hytale.com tail")
+	old, new := []byte("hytale.com"), []byte("a.io\x00\x00\x00\x00\x00\x00")
+	want := bytes.ReplaceAll(in, old, new)
+
+	tests := []struct {
+		name   string
+		splits []int // offsets where one Write ends and the next starts
+	}{
+		{"single write", nil},
+		{"split inside first match", []int{9}},
+		{"split right after match start", []int{6}},
+		{"split before last byte of match", []int{14}},
+		{"both matches split", []int{8, 25}},
+		{"byte by byte", func() []int {
+			var s []int
+			for i := 1; i < len(in); i++ {
+				s = append(s, i)
+			}
+			return s
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			rw := &ruleWriter{rule: PatchRule{ID: "domain"}, old: old, new: new}
+			w := chainRules(&out, []*ruleWriter{rw})
+
+			start := 0
+			for _, end := range append(tt.splits, len(in)) {
+				if _, err := w.Write(in[start:end]); err != nil {
+					t.Fatal(err)
+				}
+				start = end
+			}
+			changes, err := closeRules([]*ruleWriter{rw})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output = %q, want %q", out.Bytes(), want)
+			}
+			if len(changes) != 2 || changes[0].Offset != 5 || changes[1].Offset != 20 {
+				t.Errorf("changes = %+v, want offsets 5 and 20", changes)
+			}
+		})
+	}
+}
+
+func TestPatchImageReplacementTooLong(t *testing.T) {
+	rules := []PatchRule{
+		{ID: "url", Encoding: EncodingUTF16LE, Pattern: "https://sessions.hytale.com", Replacement: "https://{domain}"},
+		{ID: "domain", Encoding: EncodingUTF16LE, Pattern: "hytale.com", Replacement: "{domain}"},
+	}
+	native := append(append([]byte("\x7fELF native client "), encodeUTF16("https://sessions.hytale.com/api\x00")...), encodeUTF16("hytale.com\x00")...)
+	managed := buildManagedPE(t, "https://sessions.hytale.com/api", "hytale.com")
+
+	tests := []struct {
+		name    string
+		image   []byte
+		domain  string
+		wantErr bool
+	}{
+		{"native, domain fits", native, "a.io", false},
+		{"native, domain too long", native, "play.example-server.org", true},
+		{"managed, domain too long", managed, "play.example-server.org", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := NewClientPatcher(tt.domain)
+			var out bytes.Buffer
+			changes, err := cp.patchImage(bytes.NewReader(tt.image), int64(len(tt.image)), &out, rules)
+			if tt.wantErr {
+				if !errors.Is(err, errReplacementTooLong) {
+					t.Fatalf("got %v, want errReplacementTooLong", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) == 0 {
+				t.Fatal("no changes")
+			}
+			if bytes.Contains(out.Bytes(), encodeUTF16("hytale.co")) {
+				t.Error("patched image still contains the original domain")
+			}
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"sync"
 	"time"
 
+	"HyLauncher/internal/bootstrap"
 	"HyLauncher/internal/config"
 	"HyLauncher/internal/env"
 	"HyLauncher/pkg/logger"
@@ -33,22 +34,26 @@ type PatchesConfigResponse struct {
 }
 
 // ManifestFile represents a file entry in the manifest
+// SHA256 is optional; when present the downloaded .pwr must match it
 type ManifestFile struct {
-	Size int64 `json:"size"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
 }
 
 // Manifest represents the manifest.json structure
-// { files: { "windows/amd64/release/0_to_11.pwr": { size: 12345 }, ... } }
+// { files: { "windows/amd64/release/0_to_11.pwr": { size: 12345, sha256: "..." }, ... } }
+// It may be accompanied by a detached Ed25519 signature in manifest.json.sig
 type Manifest struct {
 	Files map[string]ManifestFile `json:"files"`
 }
 
 // PatchInfo represents a parsed patch entry
 type PatchInfo struct {
-	From int
-	To   int
-	Key  string
-	Size int64
+	From   int
+	To     int
+	Key    string
+	Size   int64
+	SHA256 string
 }
 
 // patchesState holds the cached patches URL and manifest
@@ -56,9 +61,10 @@ var (
 	patchesStateMu    sync.RWMutex
 	patchesBaseURL    string
 	patchesBaseURLSet time.Time
+	patchesMirrors    []string
+	patchesMirrorsSet time.Time
 	manifestCache     *Manifest
 	manifestCacheSet  time.Time
-	fallbackBuild     = 11 // Fallback latest build if manifest unreachable
 )
 
 type cache struct {
@@ -158,52 +164,112 @@ func fetchPatchesConfigWithFallback() (string, error) {
 
 	for _, source := range sources {
 		logger.Info("Trying patches config source", "url", source)
-		resp, err := client.Get(source)
+		url, err := fetchPatchesURLFromSource(client, source)
 		if err != nil {
 			logger.Warn("Patches config source failed", "url", source, "error", err)
 			continue
 		}
-		defer resp.Body.Close()
 
-		if resp.StatusCode != http.StatusOK {
-			logger.Warn("Patches config returned non-200", "url", source, "status", resp.StatusCode)
-			continue
-		}
+		patchesStateMu.Lock()
+		patchesBaseURL = url
+		patchesBaseURLSet = time.Now()
+		patchesStateMu.Unlock()
+		savePatchesURL(url)
+		logger.Info("Got patches URL from config", "url", url)
+		return url, nil
+	}
+
+	// Prefer the last URL a source gave us over the hardcoded fallback
+	fallback := loadManifestDiskState().PatchesURL
+	if fallback != "" {
+		logger.Warn("All patches config sources failed, using last known patches URL", "url", fallback)
+	} else {
+		fallback = config.GetPatchesFallbackURL()
+		logger.Warn("All patches config sources failed, using fallback", "url", fallback)
+	}
+	patchesStateMu.Lock()
+	patchesBaseURL = fallback
+	patchesBaseURLSet = time.Now()
+	patchesStateMu.Unlock()
+	return fallback, nil
+}
+
+// fetchPatchesURLFromSource asks a single patches-config endpoint for its patches URL
+func fetchPatchesURLFromSource(client *http.Client, source string) (string, error) {
+	resp, err := client.Get(source)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("patches config returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("read patches config: %w", err)
+	}
+
+	var cfg PatchesConfigResponse
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return "", fmt.Errorf("parse patches config: %w", err)
+	}
 
-		body, err := io.ReadAll(resp.Body)
+	if cfg.PatchesURL == "" {
+		return "", fmt.Errorf("patches config has empty patches_url")
+	}
+
+	return strings.TrimRight(cfg.PatchesURL, "/"), nil
+}
+
+// fetchPatchesMirrors returns every known patches base URL, the active one first.
+// All mirrors serve the same files under the same manifest keys.
+func fetchPatchesMirrors() []string {
+	patchesStateMu.RLock()
+	if len(patchesMirrors) > 0 && time.Since(patchesMirrorsSet) < 5*time.Minute {
+		mirrors := patchesMirrors
+		patchesStateMu.RUnlock()
+		return mirrors
+	}
+	patchesStateMu.RUnlock()
+
+	primary, _ := fetchPatchesConfigWithFallback()
+	mirrors := []string{primary}
+
+	client := &http.Client{Timeout: 8 * time.Second}
+	for _, source := range config.GetPatchesConfigSources() {
+		url, err := fetchPatchesURLFromSource(client, source)
 		if err != nil {
-			logger.Warn("Failed to read patches config response", "url", source, "error", err)
+			logger.Debug("Patches mirror source unavailable", "url", source, "error", err)
 			continue
 		}
+		mirrors = append(mirrors, url)
+	}
+	mirrors = append(mirrors, config.GetPatchesFallbackURL())
 
-		var cfg PatchesConfigResponse
-		if err := json.Unmarshal(body, &cfg); err != nil {
-			logger.Warn("Failed to parse patches config", "url", source, "error", err)
+	seen := make(map[string]bool, len(mirrors))
+	unique := mirrors[:0]
+	for _, m := range mirrors {
+		if m == "" || seen[m] {
 			continue
 		}
-
-		if cfg.PatchesURL != "" {
-			url := strings.TrimRight(cfg.PatchesURL, "/")
-			patchesStateMu.Lock()
-			patchesBaseURL = url
-			patchesBaseURLSet = time.Now()
-			patchesStateMu.Unlock()
-			logger.Info("Got patches URL from config", "url", url)
-			return url, nil
-		}
+		seen[m] = true
+		unique = append(unique, m)
 	}
 
-	// Use hardcoded fallback
-	fallback := config.GetPatchesFallbackURL()
-	logger.Warn("All patches config sources failed, using fallback", "url", fallback)
 	patchesStateMu.Lock()
-	patchesBaseURL = fallback
-	patchesBaseURLSet = time.Now()
+	patchesMirrors = unique
+	patchesMirrorsSet = time.Now()
 	patchesStateMu.Unlock()
-	return fallback, nil
+
+	logger.Info("Resolved patches mirrors", "count", len(unique))
+	return unique
 }
 
-// fetchManifest fetches the manifest.json from the patches base URL
+// fetchManifest fetches the manifest.json from the patches base URL.
+// The last good manifest is kept on disk and revalidated with ETag/Last-Modified;
+// when no source is reachable it is returned as stale data.
 func fetchManifest() (*Manifest, error) {
 	// Check memory cache (1 min TTL)
 	patchesStateMu.RLock()
@@ -219,27 +285,144 @@ func fetchManifest() (*Manifest, error) {
 		return nil, err
 	}
 
+	manifest, fetchedAt, err := fetchRemoteManifest(baseURL)
+	stale := false
+	if err != nil {
+		logger.Warn("Failed to fetch manifest, trying cached copy", "error", err)
+
+		cached, cacheErr := loadVerifiedCachedManifest()
+		if cacheErr != nil {
+			setManifestStatus(ManifestStatus{Stale: true, Error: err.Error()})
+			return nil, fmt.Errorf("%w: %v (cache: %v)", ErrManifestUnavailable, err, cacheErr)
+		}
+
+		manifest = cached
+		fetchedAt = loadManifestDiskState().FetchedAt
+		stale = true
+		logger.Warn("Using cached manifest", "fetchedAt", fetchedAt)
+	}
+
+	status := ManifestStatus{Stale: stale, FetchedAt: fetchedAt}
+	if err != nil {
+		status.Error = err.Error()
+	}
+	setManifestStatus(status)
+
+	patchesStateMu.Lock()
+	manifestCache = manifest
+	manifestCacheSet = time.Now()
+	patchesStateMu.Unlock()
+
+	return manifest, nil
+}
+
+// fetchRemoteManifest downloads and verifies manifest.json, sending the cached
+// validators so an unchanged manifest costs a 304
+func fetchRemoteManifest(baseURL string) (*Manifest, time.Time, error) {
 	manifestURL := baseURL + "/manifest.json"
 	logger.Info("Fetching manifest", "url", manifestURL)
 
+	req, err := http.NewRequest(http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("create manifest request: %w", err)
+	}
+
+	disk := loadManifestDiskState()
+	if disk.ManifestURL == manifestURL {
+		if disk.ETag != "" {
+			req.Header.Set("If-None-Match", disk.ETag)
+		}
+		if disk.LastModified != "" {
+			req.Header.Set("If-Modified-Since", disk.LastModified)
+		}
+	}
+
 	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Get(manifestURL)
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("fetch manifest: %w", err)
+		return nil, time.Time{}, fmt.Errorf("fetch manifest: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified {
+		manifest, err := loadVerifiedCachedManifest()
+		if err == nil {
+			logger.Info("Manifest not modified, using cached copy")
+			return manifest, touchCachedManifest(), nil
+		}
+		// Cache went missing under us, ask again without validators
+		logger.Warn("Cached manifest unusable after 304", "error", err)
+		req.Header.Del("If-None-Match")
+		req.Header.Del("If-Modified-Since")
+		resp.Body.Close()
+		if resp, err = client.Do(req); err != nil {
+			return nil, time.Time{}, fmt.Errorf("fetch manifest: %w", err)
+		}
+		defer resp.Body.Close()
+	}
+
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("manifest returned status %d", resp.StatusCode)
+		return nil, time.Time{}, fmt.Errorf("manifest returned status %d", resp.StatusCode)
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read manifest: %w", err)
+		return nil, time.Time{}, fmt.Errorf("read manifest: %w", err)
+	}
+
+	pubKey, err := manifestPublicKey()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var sig []byte
+	if pubKey != nil {
+		sig, err = fetchManifestSignature(client, baseURL)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	manifest, err := parseManifest(body, sig)
+	if err != nil {
+		logger.Error("Rejected manifest", "url", manifestURL, "error", err)
+		return nil, time.Time{}, err
+	}
+
+	saveCachedManifest(body, sig, manifestURL, resp.Header.Get("ETag"), resp.Header.Get("Last-Modified"))
+
+	logger.Info("Manifest fetched successfully", "files", len(manifest.Files))
+	return manifest, time.Now(), nil
+}
+
+// loadVerifiedCachedManifest reads the on-disk manifest and checks it like a fresh one
+func loadVerifiedCachedManifest() (*Manifest, error) {
+	raw, sig, err := loadCachedManifest()
+	if err != nil {
+		return nil, err
+	}
+	return parseManifest(raw, sig)
+}
+
+// parseManifest verifies the signature and decodes the manifest. Only dev
+// builds without a manifest key skip the signature check.
+func parseManifest(raw, sig []byte) (*Manifest, error) {
+	pubKey, err := manifestPublicKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if pubKey != nil {
+		if err := bootstrap.VerifyMetadataSignature(pubKey, raw, sig); err != nil {
+			return nil, fmt.Errorf("patch manifest: %w", err)
+		}
+		logger.Info("Manifest signature verified")
+	} else {
+		logger.Warn("Dev build without manifest public key, skipping signature check")
 	}
 
 	var manifest Manifest
-	if err := json.Unmarshal(body, &manifest); err != nil {
+	if err := json.Unmarshal(raw, &manifest); err != nil {
 		return nil, fmt.Errorf("parse manifest: %w", err)
 	}
 
@@ -247,12 +430,6 @@ func fetchManifest() (*Manifest, error) {
 		return nil, fmt.Errorf("invalid manifest: no files")
 	}
 
-	patchesStateMu.Lock()
-	manifestCache = &manifest
-	manifestCacheSet = time.Now()
-	patchesStateMu.Unlock()
-
-	logger.Info("Manifest fetched successfully", "files", len(manifest.Files))
 	return &manifest, nil
 }
 
@@ -282,10 +459,11 @@ func getPlatformPatches(manifest *Manifest, branch string) []PatchInfo {
 		}
 
 		patches = append(patches, PatchInfo{
-			From: from,
-			To:   to,
-			Key:  key,
-			Size: info.Size,
+			From:   from,
+			To:     to,
+			Key:    key,
+			Size:   info.Size,
+			SHA256: info.SHA256,
 		})
 	}
 
@@ -302,7 +480,10 @@ func FindLatestVersion(branch string) (int, error) {
 	// Use coalescer to prevent duplicate in-flight requests
 	result, err := versionCoalescer.Do("latest:"+key, func() (interface{}, error) {
 		r := findLatestVersion(branch)
-		versionCache.setLatest(key, &r)
+		// Don't pin errors or offline data, retry on the next call
+		if r.Error == nil && !GetManifestStatus().Stale {
+			versionCache.setLatest(key, &r)
+		}
 		return r.LatestVersion, r.Error
 	})
 
@@ -322,7 +503,9 @@ func ListAllVersions(branch string) ([]int, error) {
 	// Use coalescer to prevent duplicate in-flight requests
 	result, err := versionCoalescer.Do("all:"+key, func() (interface{}, error) {
 		r := listAllVersions(branch)
-		versionCache.setAllVersions(key, &r)
+		if r.Error == nil && !GetManifestStatus().Stale {
+			versionCache.setAllVersions(key, &r)
+		}
 		return r.Versions, r.Error
 	})
 
@@ -349,14 +532,14 @@ func VerifyVersionExists(branch string, version int) error {
 func findLatestVersion(branch string) VersionCheckResult {
 	manifest, err := fetchManifest()
 	if err != nil {
-		logger.Warn("Failed to fetch manifest, using fallback build", "error", err, "fallback", fallbackBuild)
-		return VersionCheckResult{LatestVersion: fallbackBuild}
+		logger.Warn("Failed to fetch manifest", "error", err)
+		return VersionCheckResult{Error: err}
 	}
 
 	patches := getPlatformPatches(manifest, branch)
 	if len(patches) == 0 {
-		logger.Warn("No patches found for platform, using fallback build", "os", runtime.GOOS, "arch", runtime.GOARCH, "fallback", fallbackBuild)
-		return VersionCheckResult{LatestVersion: fallbackBuild}
+		logger.Warn("No patches found for platform", "os", runtime.GOOS, "arch", runtime.GOARCH, "branch", branch)
+		return VersionCheckResult{Error: fmt.Errorf("no patches available for %s/%s/%s", runtime.GOOS, runtime.GOARCH, branch)}
 	}
 
 	// Find the highest "to" version
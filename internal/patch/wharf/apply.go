@@ -0,0 +1,683 @@
+package wharf
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// ErrPartiallyApplied means the failure happened while moving staged files into
+// the game directory, so the install no longer matches the old build
+var ErrPartiallyApplied = errors.New("wharf: patch partially applied")
+
+// ProgressFunc receives the number of bytes of the new build produced so far
+type ProgressFunc func(done, total int64)
+
+// Options configures a patch application
+type Options struct {
+	PatchPath  string
+	GameDir    string
+	StagingDir string
+	Progress   ProgressFunc
+}
+
+// Apply patches GameDir from the old build to the new one described by PatchPath.
+// New and changed files are built in StagingDir first, the game directory is
+// only touched once the whole patch has been decoded successfully.
+func Apply(ctx context.Context, opts Options) error {
+	p, err := openPatch(opts.PatchPath)
+	if err != nil {
+		return err
+	}
+	defer p.close()
+
+	if err := os.RemoveAll(opts.StagingDir); err != nil {
+		return fmt.Errorf("clear staging dir: %w", err)
+	}
+	if err := os.MkdirAll(opts.StagingDir, 0755); err != nil {
+		return fmt.Errorf("create staging dir: %w", err)
+	}
+	defer os.RemoveAll(opts.StagingDir)
+
+	a := newApplier(ctx, opts, p)
+	defer a.pool.close()
+
+	if err := a.applyAll(); err != nil {
+		return err
+	}
+
+	a.pool.close()
+
+	if err := a.commit(); err != nil {
+		return fmt.Errorf("%w: %v", ErrPartiallyApplied, err)
+	}
+
+	return nil
+}
+
+// patchFile is an opened patch positioned at its first sync header
+type patchFile struct {
+	f           *os.File
+	closeStream func()
+	wire        *wireReader
+	target      Container
+	source      Container
+}
+
+func openPatch(path string) (*patchFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open patch: %w", err)
+	}
+
+	raw := newWireReader(f)
+	if err := raw.expectMagic(PatchMagic); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	var header PatchHeader
+	if err := raw.decode(&header); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("read patch header: %w", err)
+	}
+
+	stream, closeStream, err := decompressor(raw.r, header.Algorithm)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	p := &patchFile{f: f, closeStream: closeStream, wire: newWireReader(stream)}
+	if err := p.wire.decode(&p.target); err != nil {
+		p.close()
+		return nil, fmt.Errorf("read target container: %w", err)
+	}
+	if err := p.wire.decode(&p.source); err != nil {
+		p.close()
+		return nil, fmt.Errorf("read source container: %w", err)
+	}
+	return p, nil
+}
+
+func (p *patchFile) close() {
+	p.closeStream()
+	p.f.Close()
+}
+
+func newApplier(ctx context.Context, opts Options, p *patchFile) *applier {
+	a := &applier{
+		ctx:       ctx,
+		opts:      opts,
+		wire:      p.wire,
+		target:    &p.target,
+		source:    &p.source,
+		pool:      &targetPool{dir: opts.GameDir, container: &p.target},
+		unchanged: make([]bool, len(p.source.Files)),
+	}
+
+	a.total = p.source.Size
+	if a.total <= 0 {
+		for _, sf := range p.source.Files {
+			a.total += sf.Size
+		}
+	}
+	return a
+}
+
+// applyAll decodes every file of the patch into the staging dir
+func (a *applier) applyAll() error {
+	for i := range a.source.Files {
+		if err := a.ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.applyFile(i); err != nil {
+			return fmt.Errorf("patch %s: %w", a.source.Files[i].Path, err)
+		}
+	}
+	return nil
+}
+
+type applier struct {
+	ctx       context.Context
+	opts      Options
+	wire      *wireReader
+	target    *Container
+	source    *Container
+	pool      *targetPool
+	unchanged []bool
+	done      int64
+	total     int64
+
+	// only limits the staged files to these paths, the rest is decoded and dropped
+	only map[string]bool
+}
+
+func (a *applier) report(n int64) {
+	a.done += n
+	if a.opts.Progress != nil {
+		a.opts.Progress(a.done, a.total)
+	}
+}
+
+func (a *applier) applyFile(index int) error {
+	var header SyncHeader
+	if err := a.wire.decode(&header); err != nil {
+		return fmt.Errorf("read sync header: %w", err)
+	}
+	if header.FileIndex != int64(index) {
+		return fmt.Errorf("%w: expected file %d, got %d", ErrCorrupt, index, header.FileIndex)
+	}
+
+	switch header.Type {
+	case syncTypeRsync:
+		return a.applyRsync(index)
+	case syncTypeBsdiff:
+		return a.applyBsdiff(index)
+	default:
+		return fmt.Errorf("%w: unknown sync type %d", ErrCorrupt, header.Type)
+	}
+}
+
+func (a *applier) applyRsync(index int) error {
+	sf := a.source.Files[index]
+
+	var op SyncOp
+	if err := a.wire.decode(&op); err != nil {
+		return err
+	}
+
+	// A single block range copying the whole same-path file means "unchanged"
+	if a.isIdentity(index, &op) {
+		first := op
+		if err := a.wire.decode(&op); err != nil {
+			return err
+		}
+		if op.Type == opHeyYouDidIt {
+			a.unchanged[index] = true
+			a.report(sf.Size)
+			return nil
+		}
+
+		return a.writeStaged(sf, func(w io.Writer) error {
+			if err := a.rsyncOp(w, &first); err != nil {
+				return err
+			}
+			return a.rsyncLoop(w, &op)
+		})
+	}
+
+	return a.writeStaged(sf, func(w io.Writer) error {
+		return a.rsyncLoop(w, &op)
+	})
+}
+
+// rsyncLoop applies op and every following op until HEY_YOU_DID_IT
+func (a *applier) rsyncLoop(w io.Writer, op *SyncOp) error {
+	for op.Type != opHeyYouDidIt {
+		if err := a.ctx.Err(); err != nil {
+			return err
+		}
+		if err := a.rsyncOp(w, op); err != nil {
+			return err
+		}
+		if err := a.wire.decode(op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *applier) rsyncOp(w io.Writer, op *SyncOp) error {
+	switch op.Type {
+	case opBlockRange:
+		if op.FileIndex < 0 || op.FileIndex >= int64(len(a.target.Files)) || op.BlockSpan <= 0 {
+			return fmt.Errorf("%w: bad block range", ErrCorrupt)
+		}
+		tf := a.target.Files[op.FileIndex]
+		r, err := a.pool.open(int(op.FileIndex))
+		if err != nil {
+			return err
+		}
+
+		start := op.BlockIndex * BlockSize
+		lastBlock := op.BlockIndex + op.BlockSpan - 1
+		length := (op.BlockSpan-1)*BlockSize + blockSize(tf.Size, lastBlock)
+
+		n, err := io.Copy(w, io.NewSectionReader(r, start, length))
+		if err != nil {
+			return fmt.Errorf("copy from %s: %w", tf.Path, err)
+		}
+		if n != length {
+			return fmt.Errorf("%w: short block range from %s", ErrCorrupt, tf.Path)
+		}
+		a.report(n)
+	case opData:
+		if _, err := w.Write(op.Data); err != nil {
+			return err
+		}
+		a.report(int64(len(op.Data)))
+	default:
+		return fmt.Errorf("%w: unknown sync op %d", ErrCorrupt, op.Type)
+	}
+	return nil
+}
+
+func (a *applier) applyBsdiff(index int) error {
+	sf := a.source.Files[index]
+
+	var header BsdiffHeader
+	if err := a.wire.decode(&header); err != nil {
+		return fmt.Errorf("read bsdiff header: %w", err)
+	}
+	if header.TargetIndex < 0 || header.TargetIndex >= int64(len(a.target.Files)) {
+		return fmt.Errorf("%w: bad bsdiff target %d", ErrCorrupt, header.TargetIndex)
+	}
+
+	old, err := a.pool.open(int(header.TargetIndex))
+	if err != nil {
+		return err
+	}
+
+	err = a.writeStaged(sf, func(w io.Writer) error {
+		var ctrl Control
+		var oldBuf []byte
+		var oldPos int64
+
+		for {
+			if err := a.ctx.Err(); err != nil {
+				return err
+			}
+			if err := a.wire.decode(&ctrl); err != nil {
+				return err
+			}
+			if ctrl.EOF {
+				return nil
+			}
+
+			if len(ctrl.Add) > 0 {
+				if cap(oldBuf) < len(ctrl.Add) {
+					oldBuf = make([]byte, len(ctrl.Add))
+				}
+				oldBuf = oldBuf[:len(ctrl.Add)]
+				if _, err := old.ReadAt(oldBuf, oldPos); err != nil {
+					return fmt.Errorf("%w: bsdiff read past old file: %v", ErrCorrupt, err)
+				}
+				for i := range ctrl.Add {
+					ctrl.Add[i] += oldBuf[i]
+				}
+				if _, err := w.Write(ctrl.Add); err != nil {
+					return err
+				}
+				oldPos += int64(len(ctrl.Add))
+				a.report(int64(len(ctrl.Add)))
+			}
+
+			if len(ctrl.Copy) > 0 {
+				if _, err := w.Write(ctrl.Copy); err != nil {
+					return err
+				}
+				a.report(int64(len(ctrl.Copy)))
+			}
+
+			oldPos += ctrl.Seek
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	var op SyncOp
+	if err := a.wire.decode(&op); err != nil {
+		return err
+	}
+	if op.Type != opHeyYouDidIt {
+		return fmt.Errorf("%w: bsdiff series not terminated", ErrCorrupt)
+	}
+	return nil
+}
+
+func (a *applier) isIdentity(index int, op *SyncOp) bool {
+	if op.Type != opBlockRange || op.BlockIndex != 0 {
+		return false
+	}
+	if op.FileIndex < 0 || op.FileIndex >= int64(len(a.target.Files)) {
+		return false
+	}
+
+	sf := a.source.Files[index]
+	tf := a.target.Files[op.FileIndex]
+	if tf.Path != sf.Path || tf.Size != sf.Size {
+		return false
+	}
+
+	blocks := (tf.Size + BlockSize - 1) / BlockSize
+	return op.BlockSpan == blocks
+}
+
+// writeStaged creates the staged copy of a source file and checks its final size
+func (a *applier) writeStaged(sf File, fill func(w io.Writer) error) error {
+	if a.only != nil && !a.only[sf.Path] {
+		counter := &countingWriter{w: io.Discard}
+		if err := fill(counter); err != nil {
+			return err
+		}
+		if counter.n != sf.Size {
+			return fmt.Errorf("%w: produced %d bytes, expected %d", ErrCorrupt, counter.n, sf.Size)
+		}
+		return nil
+	}
+
+	path, err := safeJoin(a.opts.StagingDir, sf.Path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fileMode(sf.Mode))
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	counter := &countingWriter{w: out}
+	bw := bufio.NewWriterSize(counter, 256*1024)
+	if err := fill(bw); err != nil {
+		return err
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+
+	if counter.n != sf.Size {
+		return fmt.Errorf("%w: produced %d bytes, expected %d", ErrCorrupt, counter.n, sf.Size)
+	}
+
+	return out.Close()
+}
+
+// commit moves staged files into place and removes what the new build dropped
+func (a *applier) commit() error {
+	gameDir := a.opts.GameDir
+	keep := a.sourcePaths()
+
+	// Drop old files first, a new directory may take the place of one
+	if err := a.removeStaleFiles(keep); err != nil {
+		return err
+	}
+
+	for _, d := range a.source.Dirs {
+		path, err := safeJoin(gameDir, d.Path)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(path, 0755); err != nil {
+			return err
+		}
+	}
+
+	for i, sf := range a.source.Files {
+		dest, err := safeJoin(gameDir, sf.Path)
+		if err != nil {
+			return err
+		}
+
+		if a.unchanged[i] {
+			if runtime.GOOS != "windows" {
+				if err := setMode(dest, fileMode(sf.Mode)); err != nil {
+					return fmt.Errorf("set mode of %s: %w", sf.Path, err)
+				}
+			}
+			continue
+		}
+
+		staged, _ := safeJoin(a.opts.StagingDir, sf.Path)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		if err := moveFile(staged, dest); err != nil {
+			return fmt.Errorf("install %s: %w", sf.Path, err)
+		}
+	}
+
+	if runtime.GOOS != "windows" {
+		for _, sl := range a.source.Symlinks {
+			path, err := safeJoin(gameDir, sl.Path)
+			if err != nil {
+				return err
+			}
+			_ = os.Remove(path)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := os.Symlink(sl.Dest, path); err != nil {
+				return fmt.Errorf("symlink %s: %w", sl.Path, err)
+			}
+		}
+	}
+
+	a.removeStaleDirs(keep)
+	return nil
+}
+
+func (a *applier) sourcePaths() map[string]bool {
+	keep := make(map[string]bool)
+	for _, sf := range a.source.Files {
+		keep[sf.Path] = true
+	}
+	for _, sl := range a.source.Symlinks {
+		keep[sl.Path] = true
+	}
+	for _, d := range a.source.Dirs {
+		keep[d.Path] = true
+	}
+	return keep
+}
+
+// removeStaleFiles deletes files and symlinks that only exist in the old build
+func (a *applier) removeStaleFiles(keep map[string]bool) error {
+	var stale []string
+	for _, tf := range a.target.Files {
+		if !keep[tf.Path] {
+			stale = append(stale, tf.Path)
+		}
+	}
+	for _, sl := range a.target.Symlinks {
+		if !keep[sl.Path] {
+			stale = append(stale, sl.Path)
+		}
+	}
+
+	for _, rel := range stale {
+		path, err := safeJoin(a.opts.GameDir, rel)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove %s: %w", rel, err)
+		}
+	}
+	return nil
+}
+
+// removeStaleDirs deletes directories that only exist in the old build
+func (a *applier) removeStaleDirs(keep map[string]bool) {
+	var dirs []string
+	for _, d := range a.target.Dirs {
+		if !keep[d.Path] {
+			dirs = append(dirs, d.Path)
+		}
+	}
+	// Deepest first so parents are empty when we reach them
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], "/") > strings.Count(dirs[j], "/")
+	})
+	for _, rel := range dirs {
+		path, err := safeJoin(a.opts.GameDir, rel)
+		if err != nil {
+			continue
+		}
+		// Non-empty dirs hold user files (logs, mods), leave them alone
+		_ = os.Remove(path)
+	}
+}
+
+// targetPool keeps the most recently used old-build file open
+type targetPool struct {
+	dir       string
+	container *Container
+	index     int
+	file      *os.File
+}
+
+func (p *targetPool) open(index int) (*os.File, error) {
+	if p.file != nil && p.index == index {
+		return p.file, nil
+	}
+	p.close()
+
+	tf := p.container.Files[index]
+	path, err := safeJoin(p.dir, tf.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open old file %s: %w", tf.Path, err)
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if st.Size() != tf.Size {
+		f.Close()
+		return nil, fmt.Errorf("old file %s has size %d, patch expects %d", tf.Path, st.Size(), tf.Size)
+	}
+
+	p.file = f
+	p.index = index
+	return f, nil
+}
+
+func (p *targetPool) close() {
+	if p.file != nil {
+		_ = p.file.Close()
+		p.file = nil
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// blockSize returns the size of block blockIndex in a file of fileSize bytes
+func blockSize(fileSize int64, blockIndex int64) int64 {
+	if BlockSize*(blockIndex+1) > fileSize {
+		return fileSize % BlockSize
+	}
+	return BlockSize
+}
+
+// safeJoin joins a container path onto base, rejecting paths that escape it
+func safeJoin(base, rel string) (string, error) {
+	path := filepath.Join(base, filepath.FromSlash(rel))
+	cleanBase := filepath.Clean(base)
+	if path != cleanBase && !strings.HasPrefix(path, cleanBase+string(os.PathSeparator)) {
+		return "", fmt.Errorf("%w: illegal path %q", ErrCorrupt, rel)
+	}
+	return path, nil
+}
+
+func fileMode(mode os.FileMode) os.FileMode {
+	perm := mode.Perm()
+	if perm == 0 {
+		return 0644
+	}
+	return perm | 0600
+}
+
+// The game dir may be a slot whose files are still hardlinked to the
+// previous slot, so files in it are only ever replaced, never written or
+// chmodded in place.
+
+// moveFile puts src at dest without touching the file dest pointed to
+func moveFile(src, dest string) error {
+	if runtime.GOOS == "windows" {
+		// Rename does not replace existing files on Windows
+		_ = os.Remove(dest)
+	}
+
+	if err := os.Rename(src, dest); err == nil {
+		return nil
+	}
+
+	// Staging may sit on another volume, fall back to copying
+	st, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	return replaceWithCopy(src, dest, st.Mode().Perm())
+}
+
+// setMode gives dest mode, through a fresh copy so a hardlinked file keeps
+// its mode in the other slot
+func setMode(dest string, mode os.FileMode) error {
+	st, err := os.Lstat(dest)
+	if err != nil || !st.Mode().IsRegular() || st.Mode().Perm() == mode {
+		return nil
+	}
+	return replaceWithCopy(dest, dest, mode)
+}
+
+// replaceWithCopy copies src to a temp file next to dest and renames it over
+// dest, so dest's old inode is left as it was
+func replaceWithCopy(src, dest string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), "."+filepath.Base(dest)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, in); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	in.Close()
+
+	if err := os.Remove(dest); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
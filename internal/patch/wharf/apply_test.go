@@ -0,0 +1,367 @@
+package wharf
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// pb encodes protobuf messages the way butler's generated code does: fields
+// in number order, proto3 zero values left out
+type pb []byte
+
+func (b pb) varint(field int, v uint64) pb {
+	if v == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func (b pb) bytes(field int, data []byte) pb {
+	if len(data) == 0 {
+		return b
+	}
+	b = binary.AppendUvarint(b, uint64(field)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// fixtureFile is a tlc.File: path = 1, mode = 2, size = 3, offset = 4
+type fixtureFile struct {
+	path string
+	mode uint32
+	size int
+}
+
+// container encodes a tlc.Container: files = 1, dirs = 2, symlinks = 3,
+// size = 16
+func container(files []fixtureFile, dirs []string) []byte {
+	var b pb
+	var offset, total int
+	for _, f := range files {
+		file := pb(nil).bytes(1, []byte(f.path)).varint(2, uint64(f.mode)).varint(3, uint64(f.size)).varint(4, uint64(offset))
+		b = b.bytes(1, file)
+		offset += f.size
+		total += f.size
+	}
+	for _, d := range dirs {
+		b = b.bytes(2, pb(nil).bytes(1, []byte(d)).varint(2, uint64(os.ModeDir|0755)))
+	}
+	return b.varint(16, uint64(total))
+}
+
+func syncHeader(typ, fileIndex int) []byte {
+	return pb(nil).varint(1, uint64(typ)).varint(16, uint64(fileIndex))
+}
+
+func blockRange(fileIndex, blockIndex, span int) []byte {
+	return pb(nil).varint(1, opBlockRange).varint(2, uint64(fileIndex)).varint(3, uint64(blockIndex)).varint(4, uint64(span))
+}
+
+func dataOp(data []byte) []byte {
+	return pb(nil).varint(1, opData).bytes(5, data)
+}
+
+func heyYouDidIt() []byte {
+	return pb(nil).varint(1, opHeyYouDidIt)
+}
+
+// writePatch lays out a .pwr: magic, header, then the compressed messages
+func writePatch(t *testing.T, algorithm CompressionAlgorithm, messages ...[]byte) string {
+	t.Helper()
+
+	var body bytes.Buffer
+	for _, m := range messages {
+		body.Write(binary.AppendUvarint(nil, uint64(len(m))))
+		body.Write(m)
+	}
+
+	var out bytes.Buffer
+	_ = binary.Write(&out, binary.LittleEndian, PatchMagic)
+	header := pb(nil).bytes(1, pb(nil).varint(1, uint64(algorithm)).varint(2, 1))
+	out.Write(binary.AppendUvarint(nil, uint64(len(header))))
+	out.Write(header)
+
+	switch algorithm {
+	case CompressionNone:
+		out.Write(body.Bytes())
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		zw.Write(body.Bytes())
+		zw.Close()
+	default:
+		t.Fatalf("fixture can't write %s", algorithm)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.pwr")
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeTree(t *testing.T, dir string, files map[string][]byte) {
+	t.Helper()
+	for rel, data := range files {
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func pattern(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(i * 7)
+	}
+	return b
+}
+
+func TestApplyRsyncAndBsdiff(t *testing.T) {
+	oldGame := pattern(int(BlockSize) + 4464)
+	oldConfig := []byte("volume=3\nname=hytale\n")
+	keep := []byte("unchanged\n")
+
+	newGame := append(append(append([]byte{}, oldGame[:BlockSize]...), "PATCHED"...), oldGame[BlockSize:]...)
+	newConfig := []byte("volume=9\nname=hytale\nfullscreen=1\n")
+	newAsset := []byte("brand new asset")
+
+	// bsdiff adds the delta to the old bytes, then copies new data in
+	add := make([]byte, len(oldConfig))
+	for i := range add {
+		add[i] = newConfig[i] - oldConfig[i]
+	}
+	extra := newConfig[len(oldConfig):]
+
+	target := container([]fixtureFile{
+		{"bin/game", 0755, len(oldGame)},
+		{"data/config.txt", 0644, len(oldConfig)},
+		{"data/keep.txt", 0644, len(keep)},
+		{"data/old.txt", 0644, 3},
+	}, []string{"bin", "data", "data/stale"})
+	source := container([]fixtureFile{
+		{"assets/new.bin", 0644, len(newAsset)},
+		{"bin/game", 0755, len(newGame)},
+		{"data/config.txt", 0644, len(newConfig)},
+		{"data/keep.txt", 0644, len(keep)},
+	}, []string{"assets", "bin", "data"})
+
+	patchPath := writePatch(t, CompressionZstd,
+		target, source,
+		// assets/new.bin: rsync, data only
+		syncHeader(syncTypeRsync, 0), dataOp(newAsset), heyYouDidIt(),
+		// bin/game: rsync, both blocks of the old file around new data
+		syncHeader(syncTypeRsync, 1), blockRange(0, 0, 1), dataOp([]byte("PATCHED")), blockRange(0, 1, 1), heyYouDidIt(),
+		// data/config.txt: bsdiff against the old config
+		syncHeader(syncTypeBsdiff, 2), pb(nil).varint(1, 1),
+		pb(nil).bytes(1, add).bytes(2, extra), pb(nil).varint(4, 1), heyYouDidIt(),
+		// data/keep.txt: unchanged
+		syncHeader(syncTypeRsync, 3), blockRange(2, 0, 1), heyYouDidIt(),
+	)
+
+	gameDir := t.TempDir()
+	writeTree(t, gameDir, map[string][]byte{
+		"bin/game":        oldGame,
+		"data/config.txt": oldConfig,
+		"data/keep.txt":   keep,
+		"data/old.txt":    []byte("old"),
+	})
+	if err := os.MkdirAll(filepath.Join(gameDir, "data", "stale"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	var done, total int64
+	err := Apply(context.Background(), Options{
+		PatchPath:  patchPath,
+		GameDir:    gameDir,
+		StagingDir: filepath.Join(t.TempDir(), "staging"),
+		Progress:   func(d, tot int64) { done, total = d, tot },
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	want := map[string][]byte{
+		"assets/new.bin":  newAsset,
+		"bin/game":        newGame,
+		"data/config.txt": newConfig,
+		"data/keep.txt":   keep,
+	}
+	for rel, data := range want {
+		got, err := os.ReadFile(filepath.Join(gameDir, filepath.FromSlash(rel)))
+		if err != nil {
+			t.Errorf("%s: %v", rel, err)
+			continue
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: got %d bytes, want %d", rel, len(got), len(data))
+		}
+	}
+
+	for _, rel := range []string{"data/old.txt", "data/stale"} {
+		if _, err := os.Stat(filepath.Join(gameDir, filepath.FromSlash(rel))); !os.IsNotExist(err) {
+			t.Errorf("%s should be removed, stat: %v", rel, err)
+		}
+	}
+	for _, rel := range []string{"assets", "bin", "data"} {
+		if st, err := os.Stat(filepath.Join(gameDir, rel)); err != nil || !st.IsDir() {
+			t.Errorf("%s should be a directory", rel)
+		}
+	}
+	if st, err := os.Stat(filepath.Join(gameDir, "bin", "game")); err == nil && st.Mode().Perm()&0100 == 0 {
+		t.Errorf("bin/game lost its executable bit: %v", st.Mode())
+	}
+
+	if total != int64(len(newAsset)+len(newGame)+len(newConfig)+len(keep)) || done != total {
+		t.Errorf("progress = %d/%d", done, total)
+	}
+}
+
+func TestApplyRejectsWrongMagic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.pwr")
+	if err := os.WriteFile(path, []byte("not a patch"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := Apply(context.Background(), Options{PatchPath: path, GameDir: t.TempDir(), StagingDir: filepath.Join(t.TempDir(), "s")})
+	if err != ErrBadMagic {
+		t.Fatalf("got %v, want ErrBadMagic", err)
+	}
+}
+
+func TestExtractFullBuild(t *testing.T) {
+	readme := []byte("read me")
+	client := pattern(1000)
+
+	patchPath := writePatch(t, CompressionNone,
+		container(nil, nil),
+		container([]fixtureFile{
+			{"Client/HytaleClient", 0755, len(client)},
+			{"README.txt", 0644, len(readme)},
+		}, []string{"Client"}),
+		syncHeader(syncTypeRsync, 0), dataOp(client[:600]), dataOp(client[600:]), heyYouDidIt(),
+		syncHeader(syncTypeRsync, 1), dataOp(readme), heyYouDidIt(),
+	)
+
+	dest := t.TempDir()
+	missing, err := Extract(context.Background(), patchPath, dest, []string{"Client/HytaleClient", "Server/missing.jar"}, nil)
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if len(missing) != 1 || missing[0] != "Server/missing.jar" {
+		t.Errorf("missing = %v", missing)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dest, "Client", "HytaleClient"))
+	if err != nil || !bytes.Equal(got, client) {
+		t.Errorf("Client/HytaleClient not extracted correctly: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "README.txt")); !os.IsNotExist(err) {
+		t.Errorf("README.txt was not requested but extracted")
+	}
+}
+
+func TestExtractRefusesDiffPatch(t *testing.T) {
+	patchPath := writePatch(t, CompressionNone,
+		container([]fixtureFile{{"a", 0644, 1}}, nil),
+		container([]fixtureFile{{"a", 0644, 1}}, nil),
+	)
+	if _, err := Extract(context.Background(), patchPath, t.TempDir(), []string{"a"}, nil); err != ErrNotFullBuild {
+		t.Fatalf("got %v, want ErrNotFullBuild", err)
+	}
+}
+
+func TestApplyLeavesHardlinkedSlotAlone(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("modes and hardlinks")
+	}
+
+	oldGame := []byte("old game binary")
+	keep := []byte("unchanged\n")
+	newGame := []byte("new game binary!")
+
+	target := container([]fixtureFile{
+		{"bin/game", 0755, len(oldGame)},
+		{"data/keep.txt", 0644, len(keep)},
+	}, []string{"bin", "data"})
+	source := container([]fixtureFile{
+		{"bin/game", 0755, len(newGame)},
+		// Same content, now executable
+		{"data/keep.txt", 0755, len(keep)},
+	}, []string{"bin", "data"})
+	patchPath := writePatch(t, CompressionNone,
+		target, source,
+		syncHeader(syncTypeRsync, 0), dataOp(newGame), heyYouDidIt(),
+		syncHeader(syncTypeRsync, 1), blockRange(1, 0, 1), heyYouDidIt(),
+	)
+
+	// The previous slot, and the new one seeded from it with hardlinks
+	prevDir, gameDir := t.TempDir(), t.TempDir()
+	writeTree(t, prevDir, map[string][]byte{"bin/game": oldGame, "data/keep.txt": keep})
+	for _, rel := range []string{"bin/game", "data/keep.txt"} {
+		dest := filepath.Join(gameDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Link(filepath.Join(prevDir, filepath.FromSlash(rel)), dest); err != nil {
+			t.Skipf("hardlinks unsupported: %v", err)
+		}
+	}
+	if err := os.Chmod(filepath.Join(prevDir, "bin", "game"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	err := Apply(context.Background(), Options{
+		PatchPath:  patchPath,
+		GameDir:    gameDir,
+		StagingDir: filepath.Join(t.TempDir(), "staging"),
+	})
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if st, err := os.Stat(filepath.Join(gameDir, "data", "keep.txt")); err != nil || st.Mode().Perm() != 0755 {
+		t.Errorf("new slot keep.txt mode = %v, %v", st.Mode(), err)
+	}
+	if st, err := os.Stat(filepath.Join(prevDir, "data", "keep.txt")); err != nil || st.Mode().Perm() != 0644 {
+		t.Errorf("previous slot keep.txt mode changed to %v, %v", st.Mode(), err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(prevDir, "bin", "game")); !bytes.Equal(got, oldGame) {
+		t.Errorf("previous slot bin/game = %q", got)
+	}
+	if got, _ := os.ReadFile(filepath.Join(gameDir, "bin", "game")); !bytes.Equal(got, newGame) {
+		t.Errorf("new slot bin/game = %q", got)
+	}
+}
+
+func TestReplaceWithCopyKeepsHardlink(t *testing.T) {
+	dir := t.TempDir()
+	src, dest, other := filepath.Join(dir, "staged"), filepath.Join(dir, "dest"), filepath.Join(dir, "other-slot")
+	writeTree(t, dir, map[string][]byte{"staged": []byte("new"), "other-slot": []byte("old")})
+	if err := os.Link(other, dest); err != nil {
+		t.Skipf("hardlinks unsupported: %v", err)
+	}
+
+	if err := replaceWithCopy(src, dest, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(dest); string(got) != "new" {
+		t.Errorf("dest = %q", got)
+	}
+	if got, _ := os.ReadFile(other); string(got) != "old" {
+		t.Errorf("hardlinked file = %q, was written through", got)
+	}
+}
@@ -0,0 +1,34 @@
+package wharf
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// decompressor wraps the compressed part of a patch
+func decompressor(r io.Reader, algorithm CompressionAlgorithm) (io.Reader, func(), error) {
+	switch algorithm {
+	case CompressionNone:
+		return r, func() {}, nil
+	case CompressionBrotli:
+		return brotli.NewReader(r), func() {}, nil
+	case CompressionGzip:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open gzip stream: %w", err)
+		}
+		return gz, func() { _ = gz.Close() }, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, nil, fmt.Errorf("open zstd stream: %w", err)
+		}
+		return zr, zr.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported compression %s", algorithm)
+	}
+}
@@ -0,0 +1,73 @@
+package wharf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrNotFullBuild means the patch builds on an older build, so single files
+// can't be taken out of it on their own
+var ErrNotFullBuild = errors.New("wharf: patch is not a full build")
+
+// Extract decodes a full-build patch and writes only the listed files, by
+// their slash-separated path in the build, into destDir. Returns the paths
+// the patch does not contain.
+func Extract(ctx context.Context, patchPath, destDir string, paths []string, progress ProgressFunc) ([]string, error) {
+	p, err := openPatch(patchPath)
+	if err != nil {
+		return nil, err
+	}
+	defer p.close()
+
+	if len(p.target.Files) > 0 {
+		return nil, ErrNotFullBuild
+	}
+
+	only := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		only[path] = true
+	}
+
+	var missing []string
+	found := make(map[string]bool, len(paths))
+	for _, sf := range p.source.Files {
+		if only[sf.Path] {
+			found[sf.Path] = true
+		}
+	}
+	for _, path := range paths {
+		if !found[path] {
+			missing = append(missing, path)
+		}
+	}
+	if len(found) == 0 {
+		return missing, nil
+	}
+
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return nil, fmt.Errorf("create extract dir: %w", err)
+	}
+
+	a := newApplier(ctx, Options{PatchPath: patchPath, StagingDir: destDir, Progress: progress}, p)
+	a.only = only
+	defer a.pool.close()
+
+	// Stop decoding once the last wanted file is out
+	remaining := len(found)
+	for i, sf := range p.source.Files {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := a.applyFile(i); err != nil {
+			return nil, fmt.Errorf("extract %s: %w", sf.Path, err)
+		}
+		if only[sf.Path] {
+			if remaining--; remaining == 0 {
+				break
+			}
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,390 @@
+package wharf
+
+import (
+	"fmt"
+	"os"
+)
+
+// CompressionAlgorithm matches pwr.CompressionAlgorithm
+type CompressionAlgorithm int
+
+const (
+	CompressionNone   CompressionAlgorithm = 0
+	CompressionBrotli CompressionAlgorithm = 1
+	CompressionGzip   CompressionAlgorithm = 2
+	CompressionZstd   CompressionAlgorithm = 3
+)
+
+func (c CompressionAlgorithm) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionBrotli:
+		return "brotli"
+	case CompressionGzip:
+		return "gzip"
+	case CompressionZstd:
+		return "zstd"
+	default:
+		return fmt.Sprintf("unknown(%d)", int(c))
+	}
+}
+
+// SyncHeader types
+const (
+	syncTypeRsync  = 0
+	syncTypeBsdiff = 1
+)
+
+// SyncOp types
+const (
+	opBlockRange  = 0
+	opData        = 1
+	opHeyYouDidIt = 2049
+)
+
+// PatchHeader precedes the compressed part of a patch
+type PatchHeader struct {
+	Algorithm CompressionAlgorithm
+	Quality   int32
+}
+
+func (m *PatchHeader) unmarshal(data []byte) error {
+	p := &protoReader{data: data}
+	for !p.done() {
+		field, wt, err := p.tag()
+		if err != nil {
+			return err
+		}
+		if field == 1 && wt == wireBytes {
+			b, err := p.bytes()
+			if err != nil {
+				return err
+			}
+			if err := m.unmarshalCompression(b); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.skip(wt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *PatchHeader) unmarshalCompression(data []byte) error {
+	p := &protoReader{data: data}
+	for !p.done() {
+		field, wt, err := p.tag()
+		if err != nil {
+			return err
+		}
+		switch {
+		case field == 1 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return err
+			}
+			m.Algorithm = CompressionAlgorithm(v)
+		case field == 2 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return err
+			}
+			m.Quality = int32(v)
+		default:
+			if err := p.skip(wt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Container describes a whole build: directories, files and symlinks
+type Container struct {
+	Dirs     []Dir
+	Files    []File
+	Symlinks []Symlink
+	Size     int64
+}
+
+type Dir struct {
+	Path string
+	Mode os.FileMode
+}
+
+type File struct {
+	Path   string
+	Mode   os.FileMode
+	Size   int64
+	Offset int64
+}
+
+type Symlink struct {
+	Path string
+	Mode os.FileMode
+	Dest string
+}
+
+func (m *Container) unmarshal(data []byte) error {
+	*m = Container{}
+	p := &protoReader{data: data}
+	for !p.done() {
+		field, wt, err := p.tag()
+		if err != nil {
+			return err
+		}
+		switch {
+		case field >= 1 && field <= 3 && wt == wireBytes:
+			b, err := p.bytes()
+			if err != nil {
+				return err
+			}
+			entry, err := unmarshalEntry(b)
+			if err != nil {
+				return err
+			}
+			// tlc.proto: files = 1, dirs = 2, symlinks = 3
+			switch field {
+			case 1:
+				m.Files = append(m.Files, File{Path: entry.path, Mode: entry.mode, Size: entry.size, Offset: entry.offset})
+			case 2:
+				m.Dirs = append(m.Dirs, Dir{Path: entry.path, Mode: entry.mode})
+			case 3:
+				m.Symlinks = append(m.Symlinks, Symlink{Path: entry.path, Mode: entry.mode, Dest: entry.dest})
+			}
+		case field == 16 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return err
+			}
+			m.Size = int64(v)
+		default:
+			if err := p.skip(wt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// containerEntry holds the union of Dir/File/Symlink fields, they share numbering
+type containerEntry struct {
+	path   string
+	mode   os.FileMode
+	size   int64
+	offset int64
+	dest   string
+}
+
+func unmarshalEntry(data []byte) (containerEntry, error) {
+	var e containerEntry
+	p := &protoReader{data: data}
+	for !p.done() {
+		field, wt, err := p.tag()
+		if err != nil {
+			return e, err
+		}
+		switch {
+		case field == 1 && wt == wireBytes:
+			b, err := p.bytes()
+			if err != nil {
+				return e, err
+			}
+			e.path = string(b)
+		case field == 2 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return e, err
+			}
+			e.mode = os.FileMode(uint32(v))
+		case field == 3 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return e, err
+			}
+			e.size = int64(v)
+		case field == 3 && wt == wireBytes:
+			b, err := p.bytes()
+			if err != nil {
+				return e, err
+			}
+			e.dest = string(b)
+		case field == 4 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return e, err
+			}
+			e.offset = int64(v)
+		default:
+			if err := p.skip(wt); err != nil {
+				return e, err
+			}
+		}
+	}
+	return e, nil
+}
+
+// SyncHeader starts the ops for one source file
+type SyncHeader struct {
+	Type      int
+	FileIndex int64
+}
+
+func (m *SyncHeader) unmarshal(data []byte) error {
+	*m = SyncHeader{}
+	p := &protoReader{data: data}
+	for !p.done() {
+		field, wt, err := p.tag()
+		if err != nil {
+			return err
+		}
+		switch {
+		case field == 1 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return err
+			}
+			m.Type = int(v)
+		case field == 16 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return err
+			}
+			m.FileIndex = int64(v)
+		default:
+			if err := p.skip(wt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// BsdiffHeader names the target file a bsdiff series patches against
+type BsdiffHeader struct {
+	TargetIndex int64
+}
+
+func (m *BsdiffHeader) unmarshal(data []byte) error {
+	*m = BsdiffHeader{}
+	p := &protoReader{data: data}
+	for !p.done() {
+		field, wt, err := p.tag()
+		if err != nil {
+			return err
+		}
+		if field == 1 && wt == wireVarint {
+			v, err := p.varint()
+			if err != nil {
+				return err
+			}
+			m.TargetIndex = int64(v)
+			continue
+		}
+		if err := p.skip(wt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncOp is a single rsync operation
+type SyncOp struct {
+	Type       int
+	FileIndex  int64
+	BlockIndex int64
+	BlockSpan  int64
+	Data       []byte
+}
+
+func (m *SyncOp) unmarshal(data []byte) error {
+	*m = SyncOp{}
+	p := &protoReader{data: data}
+	for !p.done() {
+		field, wt, err := p.tag()
+		if err != nil {
+			return err
+		}
+		switch {
+		case field >= 1 && field <= 4 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return err
+			}
+			switch field {
+			case 1:
+				m.Type = int(v)
+			case 2:
+				m.FileIndex = int64(v)
+			case 3:
+				m.BlockIndex = int64(v)
+			case 4:
+				m.BlockSpan = int64(v)
+			}
+		case field == 5 && wt == wireBytes:
+			b, err := p.bytes()
+			if err != nil {
+				return err
+			}
+			m.Data = b
+		default:
+			if err := p.skip(wt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Control is a single bsdiff control instruction
+type Control struct {
+	Add  []byte
+	Copy []byte
+	Seek int64
+	EOF  bool
+}
+
+func (m *Control) unmarshal(data []byte) error {
+	*m = Control{}
+	p := &protoReader{data: data}
+	for !p.done() {
+		field, wt, err := p.tag()
+		if err != nil {
+			return err
+		}
+		switch {
+		case field == 1 && wt == wireBytes:
+			b, err := p.bytes()
+			if err != nil {
+				return err
+			}
+			m.Add = b
+		case field == 2 && wt == wireBytes:
+			b, err := p.bytes()
+			if err != nil {
+				return err
+			}
+			m.Copy = b
+		case field == 3 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return err
+			}
+			m.Seek = int64(v)
+		case field == 4 && wt == wireVarint:
+			v, err := p.varint()
+			if err != nil {
+				return err
+			}
+			m.EOF = v != 0
+		default:
+			if err := p.skip(wt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+// Package wharf reads and applies itch.io wharf patches (.pwr) natively,
+// without shelling out to butler.
+//
+// A .pwr file is laid out as:
+//
+//	int32 magic (little endian)
+//	PatchHeader                  (uncompressed)
+//	--- compressed stream ---
+//	Container target             (the old build, what is on disk)
+//	Container source             (the new build)
+//	for every source file:
+//	  SyncHeader
+//	  RSYNC:  SyncOp... until HEY_YOU_DID_IT
+//	  BSDIFF: BsdiffHeader, Control... until eof, then HEY_YOU_DID_IT
+//
+// Every message is a protobuf prefixed with its uvarint length.
+package wharf
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// PatchMagic identifies a .pwr patch file
+	PatchMagic = int32(0xFEF5000)
+
+	// BlockSize is the rsync block size used by wharf diffs
+	BlockSize = int64(64 * 1024)
+
+	// maxMessageSize guards against corrupt length prefixes
+	maxMessageSize = 256 * 1024 * 1024
+)
+
+var (
+	ErrBadMagic = errors.New("wharf: not a patch file (bad magic)")
+	ErrCorrupt  = errors.New("wharf: corrupt patch")
+)
+
+// wireReader reads length-prefixed protobuf messages
+type wireReader struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+func newWireReader(r io.Reader) *wireReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReaderSize(r, 256*1024)
+	}
+	return &wireReader{r: br}
+}
+
+func (w *wireReader) expectMagic(magic int32) error {
+	var got int32
+	if err := binary.Read(w.r, binary.LittleEndian, &got); err != nil {
+		return fmt.Errorf("read magic: %w", err)
+	}
+	if got != magic {
+		return ErrBadMagic
+	}
+	return nil
+}
+
+// readMessage reads the next message body. The returned slice is only valid
+// until the next call.
+func (w *wireReader) readMessage() ([]byte, error) {
+	length, err := binary.ReadUvarint(w.r)
+	if err != nil {
+		if err == io.EOF {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+
+	if length > maxMessageSize {
+		return nil, fmt.Errorf("%w: message of %d bytes", ErrCorrupt, length)
+	}
+
+	if uint64(cap(w.buf)) < length {
+		w.buf = make([]byte, length)
+	}
+	w.buf = w.buf[:length]
+
+	if _, err := io.ReadFull(w.r, w.buf); err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+	return w.buf, nil
+}
+
+func (w *wireReader) decode(msg interface{ unmarshal([]byte) error }) error {
+	data, err := w.readMessage()
+	if err != nil {
+		return err
+	}
+	if err := msg.unmarshal(data); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorrupt, err)
+	}
+	return nil
+}
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protoReader is a minimal protobuf decoder for the handful of messages wharf uses
+type protoReader struct {
+	data []byte
+	pos  int
+}
+
+func (p *protoReader) done() bool {
+	return p.pos >= len(p.data)
+}
+
+func (p *protoReader) tag() (field int, wireType int, err error) {
+	v, err := p.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(v >> 3), int(v & 7), nil
+}
+
+func (p *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(p.data[p.pos:])
+	if n <= 0 {
+		return 0, fmt.Errorf("bad varint at %d", p.pos)
+	}
+	p.pos += n
+	return v, nil
+}
+
+func (p *protoReader) bytes() ([]byte, error) {
+	length, err := p.varint()
+	if err != nil {
+		return nil, err
+	}
+	end := p.pos + int(length)
+	if length > uint64(len(p.data)) || end > len(p.data) {
+		return nil, fmt.Errorf("length %d overflows message", length)
+	}
+	b := p.data[p.pos:end]
+	p.pos = end
+	return b, nil
+}
+
+func (p *protoReader) skip(wireType int) error {
+	switch wireType {
+	case wireVarint:
+		_, err := p.varint()
+		return err
+	case wireFixed64:
+		p.pos += 8
+	case wireBytes:
+		_, err := p.bytes()
+		return err
+	case wireFixed32:
+		p.pos += 4
+	default:
+		return fmt.Errorf("unsupported wire type %d", wireType)
+	}
+	if p.pos > len(p.data) {
+		return fmt.Errorf("field overflows message")
+	}
+	return nil
+}
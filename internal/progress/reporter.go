@@ -30,6 +30,7 @@ type Data struct {
 	Speed       string  `json:"speed"`
 	Downloaded  int64   `json:"downloaded"`
 	Total       int64   `json:"total"`
+	ETA         string  `json:"eta,omitempty"`
 }
 
 type Reporter struct {
@@ -96,6 +97,21 @@ func (p *Reporter) ReportDownload(stage Stage, progress float64, message string,
 	})
 }
 
+// ReportWithETA sends a progress update with throughput and remaining time
+func (p *Reporter) ReportWithETA(stage Stage, progress float64, message string, speed string, eta string) {
+	if p == nil || p.ctx == nil {
+		return
+	}
+
+	runtime.EventsEmit(p.ctx, "progress-update", Data{
+		Stage:    stage,
+		Progress: progress,
+		Message:  message,
+		Speed:    speed,
+		ETA:      eta,
+	})
+}
+
 // Scaler wraps a Reporter to scale progress within a range
 type Scaler struct {
 	reporter *Reporter
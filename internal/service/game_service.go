@@ -5,13 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 
 	"HyLauncher/internal/env"
@@ -22,16 +21,28 @@ import (
 	"HyLauncher/internal/progress"
 	"HyLauncher/internal/verify"
 	"HyLauncher/pkg/fileutil"
+	"HyLauncher/pkg/hyerrors"
 	"HyLauncher/pkg/logger"
 	"HyLauncher/pkg/model"
 )
 
+// firstLaunchGrace is how long a freshly updated build has to survive before
+// a crash is no longer blamed on the update
+const firstLaunchGrace = 60 * time.Second
+
 type GameService struct {
 	ctx        context.Context
 	reporter   *progress.Reporter
 	authSvc    *AuthService
 	authDomain string
 	installMu  sync.Mutex
+	running    atomic.Bool
+
+	// java installs and tracks the JREs the game runs on
+	java *java.Manager
+
+	// updater downloads updates in the background, paused while installing
+	updater *UpdateService
 }
 
 func NewGameService(ctx context.Context, reporter *progress.Reporter, svc *AuthService) *GameService {
@@ -39,19 +50,76 @@ func NewGameService(ctx context.Context, reporter *progress.Reporter, svc *AuthS
 		ctx:        ctx,
 		reporter:   reporter,
 		authSvc:    svc,
-		authDomain: "porkln.fun",
+		authDomain: patch.DefaultTargetDomain,
+		java:       java.NewManager(),
+	}
+}
+
+// javaSelection returns which Java an instance runs on
+func javaSelection(request model.InstanceModel) java.Selection {
+	return java.Selection{
+		Branch:  request.Branch,
+		Runtime: request.JavaRuntime,
+		Path:    request.JavaPath,
+	}
+}
+
+// JavaRuntimes lists the JREs the launcher installed
+func (s *GameService) JavaRuntimes() []java.Runtime {
+	return s.java.Runtimes()
+}
+
+// ProbeJava checks that a user-supplied Java can run the branch
+func (s *GameService) ProbeJava(branch, path string) (*java.JavaInfo, error) {
+	return s.java.ProbeCustom(branch, path)
+}
+
+// VerifyJavaRuntime re-hashes an installed JRE against the index
+func (s *GameService) VerifyJavaRuntime(version string) error {
+	return s.java.Verify(version)
+}
+
+// collectJavaRuntimes removes the JREs neither a branch nor an instance's pin
+// uses any more
+func (s *GameService) collectJavaRuntimes() []string {
+	instances, err := NewInstanceService().ListInstances()
+	if err != nil {
+		// Without the pins nothing can be removed safely
+		logger.Warn("Skipping JRE cleanup, instances unreadable", "error", err)
+		return nil
 	}
+
+	var keep []string
+	for _, inst := range instances {
+		if inst.JavaRuntime != "" {
+			keep = append(keep, inst.JavaRuntime)
+		}
+	}
+
+	removed, err := s.java.GC(keep)
+	if err != nil {
+		logger.Warn("JRE cleanup failed", "error", err)
+	}
+	return removed
+}
+
+// CleanJavaRuntimes removes unused JREs and returns their versions
+func (s *GameService) CleanJavaRuntimes() []string {
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+	return s.collectJavaRuntimes()
 }
 
 func (s *GameService) EnsureGame(request model.InstanceModel) error {
 	s.reporter.Report(progress.StageVerify, 0, "Verifying installation...")
 
-	if err := java.EnsureJRE(s.ctx, request.Branch, s.reporter); err != nil {
+	if err := s.java.Ensure(s.ctx, javaSelection(request), s.reporter); err != nil {
 		return fmt.Errorf("jre: %w", err)
 	}
 
+	// Butler is only a fallback for the built-in patcher, don't block on it
 	if err := patch.EnsureButler(s.ctx, s.reporter); err != nil {
-		return fmt.Errorf("butler: %w", err)
+		logger.Warn("Butler unavailable, relying on native patcher", "error", err)
 	}
 
 	if err := game.CheckInstalled(s.ctx, request.Branch, request.BuildVersion); err != nil {
@@ -68,18 +136,11 @@ func (s *GameService) EnsureGame(request model.InstanceModel) error {
 	return nil
 }
 
-// verifyGameFiles performs integrity verification on game files
-func (s *GameService) verifyGameFiles(request model.InstanceModel) error {
-	gameDir := env.GetGameDir(request.Branch, request.BuildVersion)
-
-	// Check if verification should be skipped
-	if os.Getenv("HYTALE_SKIP_VERIFY") == "1" {
-		logger.Info("Skipping game file verification (HYTALE_SKIP_VERIFY=1)")
-		return nil
-	}
-
+// verifyOptions returns the verification options for an install, checked
+// against the official manifest of its build
+func (s *GameService) verifyOptions(request model.InstanceModel) verify.Options {
 	options := verify.Options{
-		GameDir:       gameDir,
+		GameDir:       env.GetGameDir(request.Branch, request.BuildVersion),
 		Version:       request.BuildVersion,
 		CreateBackups: true,
 		ProgressCallback: func(current, total int64, fileName string) {
@@ -91,6 +152,35 @@ func (s *GameService) verifyGameFiles(request model.InstanceModel) error {
 		},
 	}
 
+	// The patched client and server never match the official manifest
+	for _, path := range patch.PatchedFiles(request) {
+		if rel, err := filepath.Rel(options.GameDir, path); err == nil {
+			options.NoBackup = append(options.NoBackup, filepath.ToSlash(rel))
+		}
+	}
+
+	if build := patch.InstalledBuild(request); build > 0 {
+		options.Version = strconv.Itoa(build)
+		path, err := patch.FetchBuildManifest(s.ctx, request.Branch, build)
+		if err != nil {
+			logger.Warn("Official verification manifest unavailable", "branch", request.Branch, "build", build, "error", err)
+		} else {
+			options.ManifestPath = path
+		}
+	}
+	return options
+}
+
+// verifyGameFiles performs integrity verification on game files and repairs
+// the damaged ones
+func (s *GameService) verifyGameFiles(request model.InstanceModel) error {
+	// Check if verification should be skipped
+	if os.Getenv("HYTALE_SKIP_VERIFY") == "1" {
+		logger.Info("Skipping game file verification (HYTALE_SKIP_VERIFY=1)")
+		return nil
+	}
+
+	options := s.verifyOptions(request)
 	report, err := verify.VerifyWithOptions(options)
 	if err != nil {
 		return fmt.Errorf("verification error: %w", err)
@@ -105,32 +195,209 @@ func (s *GameService) verifyGameFiles(request model.InstanceModel) error {
 		"warnings", report.Summary.Warnings,
 		"status", report.OverallStatus)
 
-	// Report any issues
-	if report.OverallStatus == verify.StatusFailed {
-		for _, file := range report.Files {
-			if file.Status == verify.StatusFailed {
-				logger.Error("File verification failed",
-					"file", file.Path,
-					"message", file.Message)
-			}
+	for _, file := range report.Files {
+		switch file.Status {
+		case verify.StatusFailed:
+			logger.Error("File verification failed",
+				"file", file.Path,
+				"message", file.Message)
+		case verify.StatusWarning:
+			logger.Warn("File verification warning",
+				"file", file.Path,
+				"message", file.Message)
 		}
-		return fmt.Errorf("file verification failed: %d files failed", report.Summary.Failed)
 	}
 
-	if report.OverallStatus == verify.StatusWarning {
-		for _, file := range report.Files {
-			if file.Status == verify.StatusWarning {
-				logger.Warn("File verification warning",
-					"file", file.Path,
-					"message", file.Message)
-			}
-		}
+	// A corrupted file that kept its size is only a warning, so the decision
+	// to repair comes from the files themselves rather than the overall status
+	damaged := damagedFiles(request, options, report)
+	if len(damaged) == 0 {
+		return nil
 	}
 
+	repair, err := s.repairGameFiles(request, options, report)
+	if err != nil {
+		return fmt.Errorf("file verification failed: %d files damaged, repair: %w", len(damaged), err)
+	}
+	if repair.Failed > 0 {
+		return fmt.Errorf("file verification failed: %d files could not be repaired", repair.Failed)
+	}
 	return nil
 }
 
+// damagedFiles returns the files in report that need attention and can be
+// restored, leaving out the ones the auth patch modifies on purpose
+func damagedFiles(request model.InstanceModel, options verify.Options, report *verify.Report) []verify.FileStatus {
+	patched := make(map[string]bool)
+	for _, path := range patch.PatchedFiles(request) {
+		patched[path] = true
+	}
+
+	var damaged []verify.FileStatus
+	for _, f := range report.Files {
+		if !f.NeedsAttention() || f.Extra || f.ExpectedHash == "" {
+			continue
+		}
+		if !patched[filepath.Join(options.GameDir, filepath.FromSlash(f.Path))] {
+			damaged = append(damaged, f)
+		}
+	}
+	return damaged
+}
+
+// RepairGame verifies an install and restores every damaged file
+func (s *GameService) RepairGame(request model.InstanceModel) (*verify.RepairReport, error) {
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+
+	s.reporter.Report(progress.StageVerify, 0, "Verifying installation...")
+
+	options := s.verifyOptions(request)
+	options.DeepVerify = true
+	report, err := verify.VerifyWithOptions(options)
+	if err != nil {
+		return nil, fmt.Errorf("verification error: %w", err)
+	}
+
+	repair, err := s.repairGameFiles(request, options, report)
+	if err != nil {
+		return nil, err
+	}
+
+	s.reporter.Report(progress.StageVerify, 100, "Repair complete")
+	return repair, nil
+}
+
+// CreateRestorePoint backs up the whole install so it can be put back later
+func (s *GameService) CreateRestorePoint(request model.InstanceModel, name string) (*verify.RestorePoint, error) {
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+
+	store := verify.OpenBackupStore("")
+	point, err := store.CreateRestorePoint(env.GetGameDir(request.Branch, request.BuildVersion), verify.RestorePointOptions{
+		Name:    name,
+		Version: request.BuildVersion,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create restore point: %w", err)
+	}
+	logger.Info("Created restore point", "id", point.ID, "name", name, "files", len(point.Files))
+
+	if _, err := store.Prune(verify.DefaultRetention); err != nil {
+		logger.Warn("Failed to prune backups", "error", err)
+	}
+	return point, nil
+}
+
+// RestorePoints lists the restore points of an install, newest first
+func (s *GameService) RestorePoints(request model.InstanceModel) ([]*verify.RestorePoint, error) {
+	points, err := verify.OpenBackupStore("").ListRestorePoints()
+	if err != nil {
+		return nil, err
+	}
+
+	gameDir := filepath.Clean(env.GetGameDir(request.Branch, request.BuildVersion))
+	if abs, err := filepath.Abs(gameDir); err == nil {
+		gameDir = abs
+	}
+	var own []*verify.RestorePoint
+	for _, p := range points {
+		if p.GameDir == gameDir {
+			own = append(own, p)
+		}
+	}
+	return own, nil
+}
+
+// RestoreToPoint puts an install back exactly as it was at a restore point
+func (s *GameService) RestoreToPoint(request model.InstanceModel, id string) (*verify.RestoreResult, error) {
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+
+	store := verify.OpenBackupStore("")
+	point, err := store.FindRestorePoint(id)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := store.Restore(point, verify.RestoreOptions{
+		GameDir:     env.GetGameDir(request.Branch, request.BuildVersion),
+		RemoveExtra: !point.Partial,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("restore %s: %w", point.ID, err)
+	}
+	logger.Info("Restored install", "point", point.ID, "restored", len(result.Restored), "removed", len(result.Removed), "failed", len(result.Failed))
+	if len(result.Failed) > 0 {
+		return result, fmt.Errorf("%d files could not be restored", len(result.Failed))
+	}
+	return result, nil
+}
+
+// VerifyHistory lists the stored verification reports of an install, newest first
+func (s *GameService) VerifyHistory(request model.InstanceModel) ([]verify.HistoryEntry, error) {
+	return verify.ListHistory(verify.GetDefaultHistoryDir(env.GetGameDir(request.Branch, request.BuildVersion)))
+}
+
+// VerifyChanges compares the latest verification report of an install with
+// the last one that passed
+func (s *GameService) VerifyChanges(request model.InstanceModel) (*verify.ReportDiff, error) {
+	dir := verify.GetDefaultHistoryDir(env.GetGameDir(request.Branch, request.BuildVersion))
+	entries, err := verify.ListHistory(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no verification reports")
+	}
+
+	latest, err := verify.LoadReport(entries[0].Path)
+	if err != nil {
+		return nil, err
+	}
+	good, err := verify.LastGoodReport(dir, latest.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+	return verify.DiffReports(good, latest), nil
+}
+
+// repairGameFiles restores the damaged files of a verification report from a
+// backup, the content endpoint or the cached full patch. The patched client
+// and server are not damaged, and are patched again if they were replaced.
+func (s *GameService) repairGameFiles(request model.InstanceModel, options verify.Options, report *verify.Report) (*verify.RepairReport, error) {
+	damaged := *report
+	damaged.Files = damagedFiles(request, options, report)
+
+	s.reporter.Report(progress.StageVerify, 0, "Repairing game files...")
+	repair, err := verify.Repair(s.ctx, options, &damaged, patch.RepairSources(request))
+	if err != nil {
+		return nil, fmt.Errorf("repair: %w", err)
+	}
+
+	for _, f := range repair.Files {
+		if f.Repaired {
+			logger.Info("Repaired game file", "file", f.Path, "source", f.Source)
+		} else {
+			logger.Error("Could not repair game file", "file", f.Path, "error", f.Error)
+		}
+	}
+	logger.Info("Game file repair complete", "repaired", repair.Repaired, "failed", repair.Failed)
+
+	if repair.Repaired > 0 {
+		if err := s.applyAuthPatch(request.Branch, request.BuildVersion, s.reporter); err != nil {
+			logger.Warn("Auth patch after repair failed", "error", err)
+		}
+	}
+	return repair, nil
+}
+
 func (s *GameService) EnsureInstalled(ctx context.Context, request model.InstanceModel, reporter *progress.Reporter) (string, error) {
+	if s.updater != nil {
+		s.updater.pause()
+		defer s.updater.resume()
+	}
+
 	s.installMu.Lock()
 	defer s.installMu.Unlock()
 
@@ -139,8 +406,11 @@ func (s *GameService) EnsureInstalled(ctx context.Context, request model.Instanc
 	}
 
 	latest, err := patch.FindLatestVersion(request.Branch)
-	if err != nil {
-		return "", fmt.Errorf("fetch latest: %w", err)
+	if err != nil || patch.GetManifestStatus().Stale {
+		if err == nil {
+			err = fmt.Errorf("%w: using cached manifest", patch.ErrManifestUnavailable)
+		}
+		return s.handleOffline(ctx, request, err, reporter)
 	}
 
 	switch request.BuildVersion {
@@ -156,17 +426,78 @@ func (s *GameService) EnsureInstalled(ctx context.Context, request model.Instanc
 	}
 }
 
+// handleOffline launches whatever is installed when no patch source is reachable
+func (s *GameService) handleOffline(ctx context.Context, request model.InstanceModel, cause error, reporter *progress.Reporter) (string, error) {
+	logger.Warn("Patch sources unreachable, entering offline mode", "branch", request.Branch, "version", request.BuildVersion, "error", cause)
+
+	version := request.BuildVersion
+	switch version {
+	case "auto":
+	case "latest":
+		version = s.latestInstalledVersion(ctx, request.Branch)
+	default:
+		if err := s.EnsureGame(request); err != nil {
+			version = ""
+		}
+	}
+
+	if version == "" || game.CheckInstalled(ctx, request.Branch, version) != nil {
+		return "", fmt.Errorf("offline and no installed build to launch: %w", cause)
+	}
+
+	if reporter != nil {
+		reporter.Report(progress.StageVerify, 100, "Offline mode: launching installed build")
+	}
+	logger.Info("Offline mode, using installed build", "branch", request.Branch, "version", version)
+	return version, nil
+}
+
+// latestInstalledVersion returns the highest numbered build installed for a branch
+func (s *GameService) latestInstalledVersion(ctx context.Context, branch string) string {
+	entries, err := os.ReadDir(filepath.Join(env.GetSharedGamesDir(), branch))
+	if err != nil {
+		return ""
+	}
+
+	best := 0
+	for _, entry := range entries {
+		ver, err := strconv.Atoi(entry.Name())
+		if err != nil || !entry.IsDir() || ver <= best {
+			continue
+		}
+		if game.CheckInstalled(ctx, branch, entry.Name()) == nil {
+			best = ver
+		}
+	}
+
+	if best == 0 {
+		return ""
+	}
+	return strconv.Itoa(best)
+}
+
 func (s *GameService) handleAutoVersion(ctx context.Context, branch string, latest int, reporter *progress.Reporter) (string, error) {
 	autoDir := env.GetGameDir(branch, "auto")
 	versionFile := filepath.Join(autoDir, ".version")
 
 	currentVer := s.readVersionFile(versionFile)
 
-	if currentVer == latest && game.CheckInstalled(ctx, branch, "auto") == nil {
-		if reporter != nil {
-			reporter.Report(progress.StageVerify, 100, "Up to date")
+	if game.CheckInstalled(ctx, branch, "auto") == nil {
+		if currentVer == latest {
+			if reporter != nil {
+				reporter.Report(progress.StageVerify, 100, "Up to date")
+			}
+			return "auto", nil
+		}
+
+		// The latest build was rolled back, stay on the current one until a newer build ships
+		if ptr, _ := env.LoadSlotPointer(branch); ptr != nil && ptr.RejectedBuild == latest {
+			logger.Info("Skipping rolled back build", "branch", branch, "build", latest, "current", currentVer)
+			if reporter != nil {
+				reporter.Report(progress.StageVerify, 100, "Up to date")
+			}
+			return "auto", nil
 		}
-		return "auto", nil
 	}
 
 	if reporter != nil {
@@ -177,7 +508,6 @@ func (s *GameService) handleAutoVersion(ctx context.Context, branch string, late
 		return "", err
 	}
 
-	_ = os.WriteFile(versionFile, []byte(strconv.Itoa(latest)), 0644)
 	return "auto", nil
 }
 
@@ -219,73 +549,161 @@ func (s *GameService) readVersionFile(path string) int {
 }
 
 func (s *GameService) install(ctx context.Context, branch, version string, targetVer int, reporter *progress.Reporter) error {
-	if err := java.EnsureJRE(ctx, branch, reporter); err != nil {
+	if err := s.java.Ensure(ctx, java.Selection{Branch: branch}, reporter); err != nil {
 		return fmt.Errorf("jre: %w", err)
 	}
+	s.collectJavaRuntimes()
 
+	// Butler is only a fallback for the built-in patcher, don't block on it
 	if err := patch.EnsureButler(ctx, reporter); err != nil {
-		return fmt.Errorf("butler: %w", err)
+		logger.Warn("Butler unavailable, relying on native patcher", "error", err)
 	}
 
-	currentVer := 0
 	if version == "auto" {
-		versionFile := filepath.Join(env.GetGameDir(branch, "auto"), ".version")
-		currentVer = s.readVersionFile(versionFile)
-		logger.Info("Read version file for install", "file", versionFile, "currentVer", currentVer, "targetVer", targetVer)
+		return s.installSlot(ctx, branch, targetVer, reporter)
 	}
 
-	logger.Info("Starting patch download", "branch", branch, "currentVer", currentVer, "targetVer", targetVer, "versionDir", version)
-	if err := patch.DownloadAndApplyPWR(ctx, branch, currentVer, targetVer, version, reporter); err != nil {
-		logger.Error("Patch failed, attempting full reinstall", "error", err, "branch", branch, "version", version)
+	if err := s.applyUpdate(ctx, branch, version, 0, targetVer, reporter); err != nil {
+		return err
+	}
 
-		if reporter != nil {
-			reporter.Report(progress.StagePatch, 0, "Patch failed, cleaning for reinstall...")
-		}
+	if err := s.fixPermissions(branch, version); err != nil {
+		return err
+	}
 
-		// Clean the game directory for fresh install
-		if cleanErr := s.cleanGameDirectory(branch, version); cleanErr != nil {
-			logger.Error("Failed to clean game directory", "error", cleanErr)
-			return fmt.Errorf("patch failed and cleanup failed: %v (original error: %w)", cleanErr, err)
-		}
+	if err := s.applyAuthPatch(branch, version, reporter); err != nil {
+		logger.Warn("Auth patch failed", "error", err)
+	}
 
-		// Retry with fresh install (currentVer = 0 forces full download)
-		logger.Info("Retrying with fresh install", "branch", branch, "targetVer", targetVer, "versionDir", version)
-		if reporter != nil {
-			reporter.Report(progress.StagePatch, 0, "Downloading full game...")
-		}
+	if reporter != nil {
+		reporter.Report(progress.StageComplete, 100, "Done")
+	}
+	return nil
+}
 
-		if retryErr := patch.DownloadAndApplyPWR(ctx, branch, 0, targetVer, version, reporter); retryErr != nil {
-			logger.Error("Full reinstall also failed", "error", retryErr)
-			return fmt.Errorf("patch failed and full reinstall failed: %w (original error: %v)", retryErr, err)
-		}
+// installSlot stages the update in a new install slot next to the active one
+// and only switches "auto" over once the new build passes the install check.
+// The active install stays playable until then and is kept for rollback.
+func (s *GameService) installSlot(ctx context.Context, branch string, targetVer int, reporter *progress.Reporter) error {
+	currentVer := 0
+	if game.CheckInstalled(ctx, branch, "auto") == nil {
+		versionFile := filepath.Join(env.GetGameDir(branch, "auto"), ".version")
+		currentVer = s.readVersionFile(versionFile)
+		logger.Info("Read version file for install", "file", versionFile, "currentVer", currentVer, "targetVer", targetVer)
+	}
 
-		logger.Info("Full reinstall successful")
+	slot, err := game.PrepareSlot(branch, targetVer, currentVer > 0)
+	if err != nil {
+		return fmt.Errorf("prepare install slot: %w", err)
 	}
 
-	// Save version file for auto version after successful patching
-	if version == "auto" {
-		versionFile := filepath.Join(env.GetGameDir(branch, "auto"), ".version")
-		if err := os.WriteFile(versionFile, []byte(strconv.Itoa(targetVer)), 0644); err != nil {
-			logger.Warn("Failed to save version file", "path", versionFile, "error", err)
-		} else {
-			logger.Info("Saved version file", "path", versionFile, "version", targetVer)
-		}
+	if err := s.applyUpdate(ctx, branch, slot, currentVer, targetVer, reporter); err != nil {
+		game.DiscardSlot(branch, slot)
+		return err
 	}
 
-	if err := s.fixPermissions(branch, version); err != nil {
+	slotDir := env.GetGameDir(branch, slot)
+	if err := game.WriteSlotBuild(slotDir, targetVer); err != nil {
+		game.DiscardSlot(branch, slot)
+		return fmt.Errorf("save version file: %w", err)
+	}
+	logger.Info("Saved version file", "dir", slotDir, "version", targetVer)
+
+	if err := s.fixPermissions(branch, slot); err != nil {
+		game.DiscardSlot(branch, slot)
 		return err
 	}
 
-	if err := s.applyAuthPatch(branch, version, reporter); err != nil {
+	if err := s.applyAuthPatch(branch, slot, reporter); err != nil {
 		logger.Warn("Auth patch failed", "error", err)
 	}
 
+	if err := game.CheckInstalled(ctx, branch, slot); err != nil {
+		game.DiscardSlot(branch, slot)
+		return fmt.Errorf("updated build %d failed install check, keeping current install: %w", targetVer, err)
+	}
+
+	if err := game.ActivateSlot(branch, slot); err != nil {
+		game.DiscardSlot(branch, slot)
+		return err
+	}
+
 	if reporter != nil {
 		reporter.Report(progress.StageComplete, 100, "Done")
 	}
 	return nil
 }
 
+// applyUpdate patches dir from currentVer to targetVer, falling back to a full
+// download into a clean directory if the incremental patch fails
+func (s *GameService) applyUpdate(ctx context.Context, branch, version string, currentVer, targetVer int, reporter *progress.Reporter) error {
+	logger.Info("Starting patch download", "branch", branch, "currentVer", currentVer, "targetVer", targetVer, "versionDir", version)
+	err := patch.DownloadAndApplyPWR(ctx, branch, currentVer, targetVer, version, reporter)
+	if err == nil {
+		return nil
+	}
+
+	logger.Error("Patch failed, attempting full reinstall", "error", err, "branch", branch, "version", version)
+
+	if reporter != nil {
+		reporter.Report(progress.StagePatch, 0, "Patch failed, cleaning for reinstall...")
+	}
+
+	// Clean the game directory for fresh install
+	if cleanErr := s.cleanGameDirectory(branch, version); cleanErr != nil {
+		logger.Error("Failed to clean game directory", "error", cleanErr)
+		return fmt.Errorf("patch failed and cleanup failed: %v (original error: %w)", cleanErr, err)
+	}
+
+	// Retry with fresh install (currentVer = 0 forces full download)
+	logger.Info("Retrying with fresh install", "branch", branch, "targetVer", targetVer, "versionDir", version)
+	if reporter != nil {
+		reporter.Report(progress.StagePatch, 0, "Downloading full game...")
+	}
+
+	if retryErr := patch.DownloadAndApplyPWR(ctx, branch, 0, targetVer, version, reporter); retryErr != nil {
+		logger.Error("Full reinstall also failed", "error", retryErr)
+		return fmt.Errorf("patch failed and full reinstall failed: %w (original error: %v)", retryErr, err)
+	}
+
+	logger.Info("Full reinstall successful")
+	return nil
+}
+
+// RollbackUpdate switches the "auto" install of a branch back to the build
+// that was active before the last update
+func (s *GameService) RollbackUpdate(branch string) error {
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+
+	slot, err := game.RollbackSlot(s.ctx, branch)
+	if err != nil {
+		return fmt.Errorf("rollback: %w", err)
+	}
+
+	logger.Info("Game update rolled back", "branch", branch, "slot", slot)
+	return nil
+}
+
+// checkFirstLaunch confirms a freshly activated slot, or rolls it back when
+// the new build crashed right away on its first launch
+func (s *GameService) checkFirstLaunch(branch string, runErr error, uptime time.Duration) {
+	ptr, err := env.LoadSlotPointer(branch)
+	if err != nil || ptr == nil || ptr.Verified {
+		return
+	}
+
+	if runErr == nil || uptime >= firstLaunchGrace {
+		game.MarkSlotVerified(branch)
+		return
+	}
+
+	logger.Error("Updated game crashed on first launch, rolling back", "branch", branch, "slot", ptr.Current, "uptime", uptime, "error", runErr)
+	if err := s.RollbackUpdate(branch); err != nil {
+		logger.Error("Rollback after crash failed", "branch", branch, "error", err)
+	}
+}
+
 // cleanGameDirectory removes all files in the game directory for fresh install
 func (s *GameService) cleanGameDirectory(branch, version string) error {
 	gameDir := env.GetGameDir(branch, version)
@@ -312,12 +730,6 @@ func (s *GameService) cleanGameDirectory(branch, version string) error {
 		}
 	}
 
-	// Also clean the version file for auto version
-	if version == "auto" {
-		versionFile := filepath.Join(gameDir, ".version")
-		_ = os.Remove(versionFile)
-	}
-
 	logger.Info("Game directory cleaned successfully")
 	return nil
 }
@@ -348,7 +760,7 @@ func (s *GameService) applyAuthPatch(branch, version string, reporter *progress.
 	}
 
 	req := model.InstanceModel{BuildVersion: version, Branch: branch}
-	if err := patch.EnsureGamePatched(s.ctx, req, s.authDomain, reporter); err != nil {
+	if _, err := patch.EnsureGamePatched(s.ctx, req, s.authDomain, reporter); err != nil {
 		return err
 	}
 
@@ -358,6 +770,20 @@ func (s *GameService) applyAuthPatch(branch, version string, reporter *progress.
 	return nil
 }
 
+// PatchGame patches an instance's build and returns the report, dryRun only
+// reports what would change
+func (s *GameService) PatchGame(request model.InstanceModel, dryRun bool) (*patch.PatchReport, error) {
+	s.installMu.Lock()
+	defer s.installMu.Unlock()
+
+	return patch.PatchGame(s.ctx, request, s.authDomain, patch.PatchOptions{DryRun: dryRun}, nil)
+}
+
+// IsRunning reports whether a game process started by the launcher is alive
+func (s *GameService) IsRunning() bool {
+	return s.running.Load()
+}
+
 // GameExitedCallback is called when the game process exits
 type GameExitedCallback func()
 
@@ -382,14 +808,14 @@ func (s *GameService) Launch(playerName string, request model.InstanceModel, onG
 		fmt.Printf("Failed to create ServerList.json: %v\n", err)
 	}
 
-	_ = patch.EnsureGamePatched(s.ctx, request, s.authDomain, nil)
+	_, _ = patch.EnsureGamePatched(s.ctx, request, s.authDomain, nil)
 
 	clientPath := env.GetGameClientPath(request.Branch, request.BuildVersion)
 	if clientPath == "" {
 		return fmt.Errorf("client not found")
 	}
 
-	javaBin, err := java.GetJavaExec(request.Branch)
+	javaBin, err := s.java.JavaExec(javaSelection(request))
 	if err != nil {
 		return fmt.Errorf("java: %w", err)
 	}
@@ -414,26 +840,43 @@ func (s *GameService) Launch(playerName string, request model.InstanceModel, onG
 		args = append(args, "--server", serverIP[0])
 	}
 
-	cmd := exec.Command(clientPath, args...)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	game.SetSDLVideoDriver(cmd)
+	cmd, err := game.NewLaunchCommand(clientPath, args, request.Launch)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Launching game", "instance", request.InstanceID,
+		"wrapper", request.Launch.Wrapper, "jvm", game.JVMOptions(request.Launch),
+		"gameArgs", request.Launch.GameArgs)
 
-	if err := cmd.Start(); err != nil {
+	sup, err := game.Supervise(cmd, game.SessionInfo{
+		InstanceID: request.InstanceID,
+		Branch:     request.Branch,
+		Version:    request.BuildVersion,
+		Dir:        env.GetInstanceSessionsDir(request.InstanceID),
+	})
+	if err != nil {
 		return fmt.Errorf("start: %w", err)
 	}
+	s.running.Store(true)
 
 	if runtime.GOOS == "darwin" {
 		_ = platform.RemoveQuarantine(clientPath)
 	}
 
-	time.Sleep(500 * time.Millisecond)
-
-	if cmd.Process != nil && runtime.GOOS != "windows" {
-		if err := cmd.Process.Signal(syscall.Signal(0)); err != nil {
-			_ = cmd.Wait()
-			return fmt.Errorf("process exited")
+	select {
+	case <-sup.Done():
+		sess, waitErr := sup.Wait()
+		if sess.Abnormal {
+			// The game died before it got going, the caller reports it
+			s.running.Store(false)
+			if request.BuildVersion == "auto" {
+				s.checkFirstLaunch(request.Branch, waitErr, sess.Uptime())
+			}
+			return gameCrashError(request, sess)
 		}
+		// A clean exit this early is handled like any other below
+	case <-time.After(500 * time.Millisecond):
 	}
 
 	s.reporter.Report(progress.StageLaunch, 100, "Launched!")
@@ -441,18 +884,58 @@ func (s *GameService) Launch(playerName string, request model.InstanceModel, onG
 
 	// Start a goroutine to wait for the game to exit
 	go func() {
-		if cmd.Process != nil {
-			_ = cmd.Wait()
-			logger.Info("Game process exited")
-			if onGameExit != nil {
-				onGameExit()
-			}
+		sess, waitErr := sup.Wait()
+		s.running.Store(false)
+		if sess.Abnormal {
+			hyerrors.Report(gameCrashError(request, sess))
+		}
+		if request.BuildVersion == "auto" {
+			s.checkFirstLaunch(request.Branch, waitErr, sess.Uptime())
+		}
+		if onGameExit != nil {
+			onGameExit()
 		}
 	}()
 
 	return nil
 }
 
+// gameCrashError builds the crash report of an abnormal game exit, with the
+// end of the game's output attached
+func gameCrashError(request model.InstanceModel, sess *game.Session) *hyerrors.Error {
+	appErr := hyerrors.GameCritical("game crashed").
+		WithDetails(sess.Summary()).
+		WithContext("instance", request.InstanceID).
+		WithContext("branch", request.Branch).
+		WithContext("version", request.BuildVersion).
+		WithContext("session", sess.ID).
+		WithContext("exitCode", sess.ExitCode).
+		WithContext("uptime", sess.Uptime().Round(time.Second).String()).
+		WithContext("logFile", sess.LogFile).
+		WithContext("logTail", sess.Tail)
+	if len(sess.Signatures) > 0 {
+		ids := make([]string, 0, len(sess.Signatures))
+		for _, sig := range sess.Signatures {
+			ids = append(ids, sig.ID)
+		}
+		appErr.WithContext("signatures", ids)
+		if hint := sess.Signatures[0].Hint; hint != "" {
+			appErr.WithContext("hint", hint)
+		}
+	}
+	return appErr
+}
+
+// GameSessions lists the recorded game sessions of an instance, newest first
+func (s *GameService) GameSessions(instanceID string) ([]*game.Session, error) {
+	return game.ListSessions(env.GetInstanceSessionsDir(instanceID))
+}
+
+// GameSessionLog returns the captured output of a game session
+func (s *GameService) GameSessionLog(instanceID, sessionID string) (string, error) {
+	return game.ReadSessionLog(env.GetInstanceSessionsDir(instanceID), sessionID)
+}
+
 func mustAbs(path string) string {
 	abs, _ := filepath.Abs(path)
 	return abs
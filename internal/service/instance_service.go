@@ -77,6 +77,9 @@ func (s *InstanceService) ListInstances() ([]model.InstanceModel, error) {
 			InstanceName: cfg.Name,
 			Branch:       cfg.Branch,
 			BuildVersion: cfg.Build,
+			JavaRuntime:  cfg.JavaRuntime,
+			JavaPath:     cfg.JavaPath,
+			Launch:       cfg.Launch,
 		})
 	}
 
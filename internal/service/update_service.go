@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"HyLauncher/internal/env"
+	"HyLauncher/internal/game"
+	"HyLauncher/internal/patch"
+	"HyLauncher/pkg/logger"
+	"HyLauncher/pkg/model"
+)
+
+const (
+	updateFirstPoll    = 2 * time.Minute
+	updatePollInterval = 30 * time.Minute
+)
+
+// UpdateStatus describes a game update downloaded ahead of time
+type UpdateStatus struct {
+	Branch      string `json:"branch"`
+	Current     int    `json:"current"`
+	Latest      int    `json:"latest"`
+	Ready       bool   `json:"ready"`
+	Downloading bool   `json:"downloading"`
+	Size        int64  `json:"size"`
+	Error       string `json:"error,omitempty"`
+}
+
+// UpdateService polls for new game builds while the launcher is idle and
+// downloads the patch chain into the cache, leaving only the apply step for
+// when the user presses play
+type UpdateService struct {
+	ctx      context.Context
+	game     *GameService
+	instance func() model.InstanceModel
+	onReady  func(UpdateStatus)
+
+	mu     sync.Mutex
+	status UpdateStatus
+	paused int
+	cancel context.CancelFunc
+	done   chan struct{}
+	wake   chan struct{}
+}
+
+func NewUpdateService(ctx context.Context, gameSvc *GameService, instance func() model.InstanceModel, onReady func(UpdateStatus)) *UpdateService {
+	u := &UpdateService{
+		ctx:      ctx,
+		game:     gameSvc,
+		instance: instance,
+		onReady:  onReady,
+		wake:     make(chan struct{}, 1),
+	}
+	gameSvc.updater = u
+	return u
+}
+
+// Start runs the poll loop until the service context is done
+func (u *UpdateService) Start() {
+	go u.loop()
+}
+
+func (u *UpdateService) Status() UpdateStatus {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.status
+}
+
+// CheckNow triggers a poll without waiting for the next interval
+func (u *UpdateService) CheckNow() {
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (u *UpdateService) loop() {
+	timer := time.NewTimer(updateFirstPoll)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-u.ctx.Done():
+			return
+		case <-timer.C:
+		case <-u.wake:
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+		}
+
+		u.poll()
+		timer.Reset(updatePollInterval)
+	}
+}
+
+// pause stops a running prefetch so a foreground install owns the downloads
+func (u *UpdateService) pause() {
+	u.mu.Lock()
+	u.paused++
+	cancel, done := u.cancel, u.done
+	u.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+func (u *UpdateService) resume() {
+	u.mu.Lock()
+	u.paused--
+	u.mu.Unlock()
+
+	// Refresh the status now that the install may have consumed the update
+	u.CheckNow()
+}
+
+func (u *UpdateService) poll() {
+	u.mu.Lock()
+	if u.paused > 0 || u.game.IsRunning() {
+		u.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(u.ctx)
+	done := make(chan struct{})
+	u.cancel, u.done = cancel, done
+	u.mu.Unlock()
+
+	defer func() {
+		cancel()
+		u.mu.Lock()
+		u.cancel, u.done = nil, nil
+		u.mu.Unlock()
+		close(done)
+	}()
+
+	u.prefetch(ctx, u.instance())
+}
+
+func (u *UpdateService) prefetch(ctx context.Context, req model.InstanceModel) {
+	current := 0
+	switch req.BuildVersion {
+	case "auto":
+		if game.CheckInstalled(ctx, req.Branch, "auto") == nil {
+			current = u.game.readVersionFile(filepath.Join(env.GetGameDir(req.Branch, "auto"), ".version"))
+		}
+	case "latest":
+	default:
+		// Pinned build, nothing to update
+		return
+	}
+
+	latest, err := patch.FindLatestVersion(req.Branch)
+	if err != nil || patch.GetManifestStatus().Stale {
+		logger.Debug("Skipping background update check, patch sources unreachable", "error", err)
+		return
+	}
+
+	status := UpdateStatus{Branch: req.Branch, Current: current, Latest: latest}
+
+	upToDate := current == latest
+	if req.BuildVersion == "latest" {
+		upToDate = game.CheckInstalled(ctx, req.Branch, strconv.Itoa(latest)) == nil
+	} else if ptr, _ := env.LoadSlotPointer(req.Branch); ptr != nil && ptr.RejectedBuild == latest {
+		upToDate = true
+	}
+	if upToDate {
+		u.setStatus(status)
+		return
+	}
+
+	prev := u.Status()
+	if prev.Ready && prev.Branch == status.Branch && prev.Current == current && prev.Latest == latest {
+		return
+	}
+
+	logger.Info("Downloading game update in background", "branch", req.Branch, "from", current, "to", latest)
+	status.Downloading = true
+	u.setStatus(status)
+
+	size, err := patch.PrefetchPatches(ctx, req.Branch, current, latest, nil)
+	status.Downloading = false
+	if err != nil {
+		if !errors.Is(ctx.Err(), context.Canceled) {
+			logger.Warn("Background update download failed", "branch", req.Branch, "error", err)
+			status.Error = err.Error()
+		}
+		u.setStatus(status)
+		return
+	}
+
+	status.Ready = true
+	status.Size = size
+	u.setStatus(status)
+
+	logger.Info("Game update ready", "branch", req.Branch, "from", current, "to", latest, "size", size)
+	if u.onReady != nil {
+		u.onReady(status)
+	}
+}
+
+func (u *UpdateService) setStatus(status UpdateStatus) {
+	u.mu.Lock()
+	u.status = status
+	u.mu.Unlock()
+}
@@ -4,87 +4,145 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
-
-	"HyLauncher/pkg/fileutil"
 )
 
-// BackupManager handles file backup and restoration
+// BackupManager backs up the files of one game directory into the backup
+// store. Backups go into the single partial auto restore point of the
+// install, so repeated verification runs don't pile up points.
 type BackupManager struct {
-	backupDir string
-	enabled   bool
+	store   *BackupStore
+	gameDir string
+	enabled bool
+
+	mu    sync.Mutex
+	point *RestorePoint
 }
 
-// NewBackupManager creates a new backup manager
-func NewBackupManager(backupDir string, enabled bool) *BackupManager {
+// NewBackupManager creates a backup manager for gameDir on the store at
+// backupDir (default: launcher_dir/backups). Without a gameDir, files are
+// recorded relative to their own directory.
+func NewBackupManager(backupDir string, gameDir string, enabled bool) *BackupManager {
 	return &BackupManager{
-		backupDir: backupDir,
-		enabled:   enabled,
+		store:   OpenBackupStore(backupDir),
+		gameDir: gameDir,
+		enabled: enabled,
 	}
 }
 
-// EnsureBackupDir creates the backup directory if it doesn't exist
-func (bm *BackupManager) EnsureBackupDir() error {
-	if !bm.enabled {
-		return nil
-	}
-	return os.MkdirAll(bm.backupDir, 0755)
+// Store returns the backup store the manager writes to
+func (bm *BackupManager) Store() *BackupStore {
+	return bm.store
 }
 
-// CreateBackup creates a backup of the specified file
+// CreateBackup creates a backup of the specified file and returns the path of
+// the stored copy, or "" if the file does not exist
 func (bm *BackupManager) CreateBackup(filePath string) (string, error) {
+	return bm.BackupFile(filePath, "")
+}
+
+// BackupFile is CreateBackup for a file whose hash is already known, so
+// content that is stored already is not read again
+func (bm *BackupManager) BackupFile(filePath string, hash string) (string, error) {
 	if !bm.enabled {
 		return "", nil
 	}
 
-	if err := bm.EnsureBackupDir(); err != nil {
+	// Check if file exists
+	stat, err := os.Stat(filePath)
+	if os.IsNotExist(err) {
+		return "", nil // Nothing to backup
+	}
+	if err != nil {
 		return "", &VerificationError{
-			Op:      "create backup directory",
-			Path:    bm.backupDir,
+			Op:      "backup file",
+			Path:    filePath,
 			Err:     err,
-			Message: fmt.Sprintf("failed to create backup directory: %v", err),
+			Message: fmt.Sprintf("failed to backup file %s: %v", filePath, err),
 		}
 	}
 
-	// Check if file exists
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return "", nil // Nothing to backup
-	}
-
-	// Generate backup filename with timestamp
-	fileName := filepath.Base(filePath)
-	timestamp := time.Now().Format("20060102_150405")
-	backupName := fmt.Sprintf("%s.%s.bak", fileName, timestamp)
-	backupPath := filepath.Join(bm.backupDir, backupName)
-
-	// Handle subdirectories in backup
-	subDir := filepath.Dir(filePath)
-	if subDir != "." && subDir != "/" {
-		backupPath = filepath.Join(bm.backupDir, subDir, backupName)
-		if err := os.MkdirAll(filepath.Dir(backupPath), 0755); err != nil {
-			return "", &VerificationError{
-				Op:      "create backup subdirectory",
-				Path:    filepath.Dir(backupPath),
-				Err:     err,
-				Message: fmt.Sprintf("failed to create backup subdirectory: %v", err),
-			}
+	hash, size, err := bm.store.PutFile(filePath, hash)
+	if err != nil {
+		return "", &VerificationError{
+			Op:      "backup file",
+			Path:    filePath,
+			Err:     err,
+			Message: fmt.Sprintf("failed to backup file %s: %v", filePath, err),
 		}
 	}
 
-	// Copy file to backup location
-	if err := fileutil.CopyFile(filePath, backupPath); err != nil {
+	gameDir, relPath := bm.split(filePath)
+
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	if bm.point == nil || bm.point.GameDir != gameDir {
+		bm.point = bm.autoPoint(gameDir)
+	}
+	// An auto point is as recent as its last backup
+	bm.point.CreatedAt = time.Now()
+	bm.point.Files[relPath] = BackupEntry{Size: size, SHA256: hash, Mode: uint32(stat.Mode().Perm())}
+	if err := bm.store.SaveRestorePoint(bm.point); err != nil {
 		return "", &VerificationError{
 			Op:      "backup file",
 			Path:    filePath,
 			Err:     err,
-			Message: fmt.Sprintf("failed to backup file %s: %v", filePath, err),
+			Message: fmt.Sprintf("failed to record backup of %s: %v", filePath, err),
+		}
+	}
+
+	blob, _ := bm.store.BlobPath(hash)
+	return blob, nil
+}
+
+// autoPoint returns the auto restore point of an install, starting one if
+// there is none yet
+func (bm *BackupManager) autoPoint(gameDir string) *RestorePoint {
+	points, _ := bm.store.ListRestorePoints()
+	for _, p := range points {
+		if p.Kind == RestorePointAuto && p.GameDir == gameDir {
+			if p.Files == nil {
+				p.Files = make(map[string]BackupEntry)
+			}
+			return p
 		}
 	}
 
-	return backupPath, nil
+	point := newRestorePoint(RestorePointAuto, gameDir)
+	point.Partial = true
+	return point
+}
+
+// BackedUp reports whether the manager stored any file
+func (bm *BackupManager) BackedUp() bool {
+	bm.mu.Lock()
+	defer bm.mu.Unlock()
+	return bm.point != nil
+}
+
+// split returns the install directory a file is recorded under and its
+// slash-separated path in it
+func (bm *BackupManager) split(filePath string) (string, string) {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		filePath = abs
+	}
+	if bm.gameDir != "" {
+		gameDir := bm.gameDir
+		if abs, err := filepath.Abs(gameDir); err == nil {
+			gameDir = abs
+		}
+		gameDir = filepath.Clean(gameDir)
+		if rel, err := filepath.Rel(gameDir, filePath); err == nil && !strings.HasPrefix(rel, "..") {
+			return gameDir, filepath.ToSlash(rel)
+		}
+	}
+	return filepath.Dir(filePath), filepath.Base(filePath)
 }
 
-// RestoreFile restores a file from its most recent backup
+// RestoreFile restores a file from its most recent backup in any restore
+// point and returns the path of the stored copy
 func (bm *BackupManager) RestoreFile(originalPath string) (string, error) {
 	if !bm.enabled {
 		return "", &VerificationError{
@@ -93,27 +151,16 @@ func (bm *BackupManager) RestoreFile(originalPath string) (string, error) {
 		}
 	}
 
-	fileName := filepath.Base(originalPath)
-	subDir := filepath.Dir(originalPath)
-
-	// Determine backup search directory
-	searchDir := bm.backupDir
-	if subDir != "." && subDir != "/" {
-		searchDir = filepath.Join(bm.backupDir, subDir)
-	}
-
-	// Find the most recent backup
-	backups, err := bm.listBackups(searchDir, fileName)
+	entry, found, err := bm.latestEntry(originalPath)
 	if err != nil {
 		return "", &VerificationError{
 			Op:      "list backups",
-			Path:    searchDir,
+			Path:    bm.store.Dir(),
 			Err:     err,
 			Message: fmt.Sprintf("failed to list backups: %v", err),
 		}
 	}
-
-	if len(backups) == 0 {
+	if !found {
 		return "", &VerificationError{
 			Op:      "restore",
 			Path:    originalPath,
@@ -121,119 +168,65 @@ func (bm *BackupManager) RestoreFile(originalPath string) (string, error) {
 		}
 	}
 
-	// Sort by modification time (newest first) and pick the most recent
-	mostRecent := backups[0]
-	for _, backup := range backups {
-		if backup.ModTime().After(mostRecent.ModTime()) {
-			mostRecent = backup
-		}
-	}
-
-	backupPath := filepath.Join(searchDir, mostRecent.Name())
-
-	// Ensure target directory exists
-	targetDir := filepath.Dir(originalPath)
-	if err := os.MkdirAll(targetDir, 0755); err != nil {
-		return "", &VerificationError{
-			Op:      "create target directory",
-			Path:    targetDir,
-			Err:     err,
-			Message: fmt.Sprintf("failed to create target directory: %v", err),
-		}
-	}
-
-	// Restore the file
-	if err := fileutil.CopyFile(backupPath, originalPath); err != nil {
+	if err := bm.store.restoreBlob(entry, originalPath); err != nil {
 		return "", &VerificationError{
 			Op:      "restore file",
 			Path:    originalPath,
 			Err:     err,
-			Message: fmt.Sprintf("failed to restore file from %s: %v", backupPath, err),
+			Message: fmt.Sprintf("failed to restore file %s: %v", originalPath, err),
 		}
 	}
 
-	return backupPath, nil
+	blob, _ := bm.store.BlobPath(entry.SHA256)
+	return blob, nil
 }
 
-// listBackups returns all backup files for a given original filename
-func (bm *BackupManager) listBackups(searchDir string, originalName string) ([]os.FileInfo, error) {
-	entries, err := os.ReadDir(searchDir)
+// latestEntry finds the newest restore point entry of a file
+func (bm *BackupManager) latestEntry(filePath string) (BackupEntry, bool, error) {
+	if abs, err := filepath.Abs(filePath); err == nil {
+		filePath = abs
+	}
+	points, err := bm.store.ListRestorePoints()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return []os.FileInfo{}, nil
-		}
-		return nil, err
+		return BackupEntry{}, false, err
 	}
-
-	var backups []os.FileInfo
-	for _, entry := range entries {
-		if entry.IsDir() {
+	for _, p := range points {
+		rel, err := filepath.Rel(p.GameDir, filePath)
+		if err != nil || strings.HasPrefix(rel, "..") {
 			continue
 		}
-		// Check if this is a backup of the original file
-		name := entry.Name()
-		if len(name) > len(originalName)+4 &&
-			name[:len(originalName)] == originalName &&
-			name[len(name)-4:] == ".bak" {
-			info, err := entry.Info()
-			if err == nil {
-				backups = append(backups, info)
-			}
+		if entry, ok := p.Files[filepath.ToSlash(rel)]; ok {
+			return entry, true, nil
 		}
 	}
-
-	return backups, nil
+	return BackupEntry{}, false, nil
 }
 
-// ListAllBackups returns all backup files in the backup directory
-func (bm *BackupManager) ListAllBackups() ([]string, error) {
-	if !bm.enabled {
-		return []string{}, nil
+// FindBackup returns a stored copy of a file whose hash is sha256, or "" when
+// there is none. The store is content-addressed, so a copy backed up from any
+// file or install will do.
+func (bm *BackupManager) FindBackup(originalPath string, sha256 string) (string, error) {
+	blob, ok := bm.store.BlobPath(sha256)
+	if !ok {
+		return "", nil
 	}
-
-	var backups []string
-	err := filepath.Walk(bm.backupDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Continue walking
-		}
-		if !info.IsDir() && filepath.Ext(path) == ".bak" {
-			relPath, _ := filepath.Rel(bm.backupDir, path)
-			backups = append(backups, relPath)
-		}
-		return nil
-	})
-
-	return backups, err
+	return blob, nil
 }
 
-// CleanupOldBackups removes backups older than the specified duration
+// CleanupOldBackups removes restore points older than the specified duration
+// and returns how many were removed
 func (bm *BackupManager) CleanupOldBackups(maxAge time.Duration) (int, error) {
 	if !bm.enabled {
 		return 0, nil
 	}
-
-	count := 0
-	cutoff := time.Now().Add(-maxAge)
-
-	err := filepath.Walk(bm.backupDir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil // Continue walking
-		}
-		if info.IsDir() {
-			return nil
-		}
-		if filepath.Ext(path) == ".bak" && info.ModTime().Before(cutoff) {
-			if err := os.Remove(path); err == nil {
-				count++
-			}
-		}
-		return nil
-	})
-
-	return count, err
+	result, err := bm.store.Prune(RetentionPolicy{MaxAge: maxAge})
+	if err != nil {
+		return 0, err
+	}
+	return len(result.Points), nil
 }
 
-// GetBackupPath returns the configured backup directory
+// GetBackupPath returns the backup store directory
 func (bm *BackupManager) GetBackupPath() string {
-	return bm.backupDir
+	return bm.store.Dir()
 }
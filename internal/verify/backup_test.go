@@ -0,0 +1,53 @@
+package verify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBackupsShareOneAutoPointPerInstall(t *testing.T) {
+	backupDir := t.TempDir()
+	gameDir := t.TempDir()
+	file := filepath.Join(gameDir, "Client", "data.bin")
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every verification run uses a new manager
+	for _, content := range []string{"first", "second", "third"} {
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := NewBackupManager(backupDir, gameDir, true).BackupFile(file, ""); err != nil {
+			t.Fatalf("BackupFile: %v", err)
+		}
+	}
+
+	other := t.TempDir()
+	if err := os.WriteFile(filepath.Join(other, "x"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBackupManager(backupDir, other, true).BackupFile(filepath.Join(other, "x"), ""); err != nil {
+		t.Fatal(err)
+	}
+
+	points, err := OpenBackupStore(backupDir).ListRestorePoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("%d restore points, want one per install", len(points))
+	}
+
+	bm := NewBackupManager(backupDir, gameDir, true)
+	if err := os.WriteFile(file, []byte("damaged"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bm.RestoreFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "third" {
+		t.Errorf("restored %q, want the latest backup", data)
+	}
+}
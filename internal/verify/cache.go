@@ -0,0 +1,141 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// cacheVersion is bumped whenever the cache format or key changes
+const cacheVersion = 1
+
+// CacheEntry remembers the hash of a file as it was on disk when hashed
+type CacheEntry struct {
+	Size    int64  `json:"size"`
+	ModTime int64  `json:"mtime"` // Unix nanoseconds
+	FileID  uint64 `json:"inode,omitempty"`
+	SHA256  string `json:"sha256"`
+}
+
+// HashCache maps files of one game directory to their last known hash.
+// A file whose size, modification time and inode are unchanged is assumed to
+// still have the cached hash. It is safe for concurrent use.
+type HashCache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]CacheEntry
+	seen    map[string]bool
+	dirty   bool
+}
+
+type cacheFile struct {
+	Version int                   `json:"version"`
+	Files   map[string]CacheEntry `json:"files"`
+}
+
+// LoadHashCache reads the cache at path. A missing, corrupt or outdated cache
+// gives an empty one, so every file is hashed again.
+func LoadHashCache(path string) *HashCache {
+	cache := &HashCache{
+		path:    path,
+		entries: make(map[string]CacheEntry),
+		seen:    make(map[string]bool),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	var file cacheFile
+	if err := json.Unmarshal(data, &file); err != nil || file.Version != cacheVersion {
+		return cache
+	}
+	if file.Files != nil {
+		cache.entries = file.Files
+	}
+	return cache
+}
+
+// Lookup returns the cached hash of relPath if the file is unchanged
+func (c *HashCache) Lookup(relPath string, stat os.FileInfo, id uint64) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen[relPath] = true
+	entry, ok := c.entries[relPath]
+	if !ok {
+		return "", false
+	}
+	if entry.Size != stat.Size() || entry.ModTime != stat.ModTime().UnixNano() || entry.FileID != id {
+		return "", false
+	}
+	return entry.SHA256, true
+}
+
+// Store records the hash of relPath
+func (c *HashCache) Store(relPath string, stat os.FileInfo, id uint64, hash string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seen[relPath] = true
+	c.entries[relPath] = CacheEntry{
+		Size:    stat.Size(),
+		ModTime: stat.ModTime().UnixNano(),
+		FileID:  id,
+		SHA256:  hash,
+	}
+	c.dirty = true
+}
+
+// Save writes the cache back, dropping files that were not looked at since it
+// was loaded. It does nothing when the cache is unchanged.
+func (c *HashCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for relPath := range c.entries {
+		if !c.seen[relPath] {
+			delete(c.entries, relPath)
+			c.dirty = true
+		}
+	}
+	if !c.dirty {
+		return nil
+	}
+
+	data, err := json.Marshal(cacheFile{Version: cacheVersion, Files: c.entries})
+	if err != nil {
+		return fmt.Errorf("encode verification cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("create cache directory: %w", err)
+	}
+
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write verification cache: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace verification cache: %w", err)
+	}
+
+	c.dirty = false
+	return nil
+}
+
+// GetDefaultCachePath returns where the hash cache of a game directory is kept.
+// Like manifests it lives in the launcher directory so game updates don't
+// touch it.
+func GetDefaultCachePath(gameDir string) string {
+	if abs, err := filepath.Abs(gameDir); err == nil {
+		gameDir = abs
+	}
+	sum := sha256.Sum256([]byte(filepath.Clean(gameDir)))
+	name := fmt.Sprintf("verify_%s.json", hex.EncodeToString(sum[:8]))
+	return filepath.Join(getLauncherDir(), "cache", name)
+}
@@ -0,0 +1,133 @@
+package verify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func statFile(t *testing.T, path string) os.FileInfo {
+	t.Helper()
+	st, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st
+}
+
+func TestHashCacheLookup(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "HytaleClient")
+	if err := os.WriteFile(file, []byte("client"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat := statFile(t, file)
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T)
+		id     uint64
+		want   bool
+	}{
+		{"unchanged", func(t *testing.T) {}, 7, true},
+		{"other inode", func(t *testing.T) {}, 8, false},
+		{"rewritten with another size", func(t *testing.T) {
+			if err := os.WriteFile(file, []byte("patched client"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, 7, false},
+		{"same size, touched", func(t *testing.T) {
+			later := stat.ModTime().Add(time.Minute)
+			if err := os.Chtimes(file, later, later); err != nil {
+				t.Fatal(err)
+			}
+		}, 7, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(file, []byte("client"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(file, stat.ModTime(), stat.ModTime()); err != nil {
+				t.Fatal(err)
+			}
+
+			cache := LoadHashCache(filepath.Join(dir, "cache.json"))
+			cache.Store("Client/HytaleClient", statFile(t, file), 7, "abc123")
+
+			tt.change(t)
+			hash, ok := cache.Lookup("Client/HytaleClient", statFile(t, file), tt.id)
+			if ok != tt.want || (ok && hash != "abc123") {
+				t.Fatalf("Lookup = %q, %v, want hit %v", hash, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashCacheSaveAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "cache", "verify.json")
+	file := filepath.Join(dir, "a.bin")
+	if err := os.WriteFile(file, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stat := statFile(t, file)
+
+	cache := LoadHashCache(path)
+	cache.Store("a.bin", stat, 1, "hash-a")
+	cache.Store("gone.bin", stat, 2, "hash-gone")
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only a.bin is looked at, gone.bin is dropped on the next save
+	cache = LoadHashCache(path)
+	if hash, ok := cache.Lookup("a.bin", stat, 1); !ok || hash != "hash-a" {
+		t.Fatalf("Lookup after reload = %q, %v", hash, ok)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	cache = LoadHashCache(path)
+	if _, ok := cache.Lookup("gone.bin", stat, 2); ok {
+		t.Error("entry not looked at survived a save")
+	}
+	if _, ok := cache.Lookup("a.bin", stat, 1); !ok {
+		t.Error("entry that was looked at was dropped")
+	}
+
+	// Nothing changed, so Save leaves the file alone
+	before := statFile(t, path).ModTime()
+	if err := os.Chtimes(path, before.Add(-time.Hour), before.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if !statFile(t, path).ModTime().Equal(before.Add(-time.Hour)) {
+		t.Error("unchanged cache was rewritten")
+	}
+}
+
+func TestLoadHashCacheDiscardsBadFiles(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"corrupt", "{not json"},
+		{"outdated", `{"version":0,"files":{"a.bin":{"size":1,"mtime":1,"sha256":"x"}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "cache.json")
+			if err := os.WriteFile(path, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if cache := LoadHashCache(path); len(cache.entries) != 0 {
+				t.Fatalf("loaded %d entries", len(cache.entries))
+			}
+		})
+	}
+}
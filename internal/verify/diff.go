@@ -0,0 +1,127 @@
+package verify
+
+import (
+	"sort"
+	"time"
+)
+
+// ChangeKind describes how a file differs between two reports
+type ChangeKind string
+
+const (
+	// ChangeAdded is a file on disk that the older report did not see
+	ChangeAdded ChangeKind = "added"
+	// ChangeRemoved is a file that existed before and is gone now
+	ChangeRemoved ChangeKind = "removed"
+	// ChangeModified is a file whose content changed
+	ChangeModified ChangeKind = "modified"
+	// ChangeStatus is a file with the same content but another result,
+	// e.g. after the manifest changed
+	ChangeStatus ChangeKind = "status"
+)
+
+// FileChange is one file that differs between two reports. Before or After
+// is nil when the file is not in that report.
+type FileChange struct {
+	Path   string      `json:"path"`
+	Change ChangeKind  `json:"change"`
+	Before *FileStatus `json:"before,omitempty"`
+	After  *FileStatus `json:"after,omitempty"`
+}
+
+// ReportDiff lists what changed between an older and a newer report
+type ReportDiff struct {
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	FromVersion string             `json:"from_version"`
+	ToVersion   string             `json:"to_version"`
+	FromStatus  VerificationStatus `json:"from_status"`
+	ToStatus    VerificationStatus `json:"to_status"`
+	Changes     []FileChange       `json:"changes"`
+}
+
+// Count returns how many files changed in the given way
+func (d *ReportDiff) Count(kind ChangeKind) int {
+	n := 0
+	for _, c := range d.Changes {
+		if c.Change == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// DiffReports compares two reports of the same install file by file.
+// Files whose content and result are the same in both are left out.
+func DiffReports(from, to *Report) *ReportDiff {
+	diff := &ReportDiff{
+		From:        from.Timestamp,
+		To:          to.Timestamp,
+		FromVersion: from.Version,
+		ToVersion:   to.Version,
+		FromStatus:  from.OverallStatus,
+		ToStatus:    to.OverallStatus,
+		Changes:     []FileChange{},
+	}
+
+	before := make(map[string]*FileStatus, len(from.Files))
+	for i := range from.Files {
+		before[from.Files[i].Path] = &from.Files[i]
+	}
+	after := make(map[string]*FileStatus, len(to.Files))
+	for i := range to.Files {
+		after[to.Files[i].Path] = &to.Files[i]
+	}
+
+	for path, b := range before {
+		a := after[path]
+		if kind, changed := compareFile(b, a); changed {
+			diff.Changes = append(diff.Changes, FileChange{Path: path, Change: kind, Before: b, After: a})
+		}
+	}
+	for path, a := range after {
+		if _, ok := before[path]; ok {
+			continue
+		}
+		if kind, changed := compareFile(nil, a); changed {
+			diff.Changes = append(diff.Changes, FileChange{Path: path, Change: kind, After: a})
+		}
+	}
+
+	sort.Slice(diff.Changes, func(i, j int) bool {
+		return diff.Changes[i].Path < diff.Changes[j].Path
+	})
+	return diff
+}
+
+// compareFile classifies how a file changed between two results, either of
+// which may be nil
+func compareFile(before, after *FileStatus) (ChangeKind, bool) {
+	existedBefore := before != nil && before.Exists
+	existsAfter := after != nil && after.Exists
+
+	switch {
+	case !existedBefore && !existsAfter:
+		// Missing in both, only the result can differ
+		if before == nil || after == nil || before.Status != after.Status {
+			return ChangeStatus, true
+		}
+		return "", false
+	case !existedBefore:
+		return ChangeAdded, true
+	case !existsAfter:
+		return ChangeRemoved, true
+	}
+
+	if before.Size != after.Size {
+		return ChangeModified, true
+	}
+	// Files failing the size check or extra files have no hash to compare
+	if before.Hash != "" && after.Hash != "" && before.Hash != after.Hash {
+		return ChangeModified, true
+	}
+	if before.Status != after.Status || before.Extra != after.Extra {
+		return ChangeStatus, true
+	}
+	return "", false
+}
@@ -0,0 +1,302 @@
+package verify
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ReportFormat is a file format a report can be exported to
+type ReportFormat string
+
+const (
+	FormatJSON  ReportFormat = "json"
+	FormatJUnit ReportFormat = "junit"
+	FormatSARIF ReportFormat = "sarif"
+	FormatHTML  ReportFormat = "html"
+)
+
+// ReportFormats lists the supported export formats
+var ReportFormats = []ReportFormat{FormatJSON, FormatJUnit, FormatSARIF, FormatHTML}
+
+// ParseReportFormat checks a format name given by the user
+func ParseReportFormat(name string) (ReportFormat, error) {
+	for _, f := range ReportFormats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown report format %q", name)
+}
+
+// WriteReport exports a report in the given format
+func WriteReport(w io.Writer, report *Report, format ReportFormat) error {
+	switch format {
+	case FormatJSON:
+		return writeJSON(w, report)
+	case FormatJUnit:
+		return writeJUnit(w, report)
+	case FormatSARIF:
+		return writeSARIF(w, report)
+	case FormatHTML:
+		return writeHTML(w, report)
+	}
+	return fmt.Errorf("unknown report format %q", format)
+}
+
+func writeJSON(w io.Writer, v any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// issueRule names what is wrong with a file that did not pass
+func issueRule(f FileStatus) string {
+	switch {
+	case f.Extra:
+		return "extra-file"
+	case !f.Exists:
+		return "missing-file"
+	case f.ExpectedSize != 0 && f.Size != f.ExpectedSize:
+		return "size-mismatch"
+	case f.Hash == "":
+		return "unreadable-file"
+	}
+	return "modified-file"
+}
+
+// JUnit XML, one test case per file
+
+type junitSuites struct {
+	XMLName xml.Name     `xml:"testsuites"`
+	Suites  []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Timestamp string      `xml:"timestamp,attr"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *struct{}     `xml:"skipped,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit exports a report as JUnit XML. Warnings count as failures of
+// type "warning" since they are files that differ from the manifest.
+func writeJUnit(w io.Writer, report *Report) error {
+	suite := junitSuite{
+		Name:      fmt.Sprintf("verify %s", report.Version),
+		Tests:     len(report.Files),
+		Timestamp: report.Timestamp.Format(time.RFC3339),
+	}
+
+	for _, f := range report.Files {
+		tc := junitCase{ClassName: "game", Name: f.Path}
+		if f.Role != "" {
+			tc.ClassName = "game." + string(f.Role)
+		}
+		switch f.Status {
+		case StatusFailed, StatusWarning:
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: f.Message,
+				Type:    strings.ToLower(string(f.Status)),
+				Text:    issueRule(f),
+			}
+		case StatusSkipped:
+			suite.Skipped++
+			tc.Skipped = &struct{}{}
+		}
+		suite.Cases = append(suite.Cases, tc)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitSuites{Suites: []junitSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// SARIF 2.1.0, one result per file that did not pass
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// sarifRules describes the rule ids issueRule gives
+var sarifRules = []struct{ id, text string }{
+	{"missing-file", "A file listed in the manifest is missing"},
+	{"size-mismatch", "A file does not have the size listed in the manifest"},
+	{"modified-file", "A file does not have the hash listed in the manifest"},
+	{"unreadable-file", "A file could not be read to check it"},
+	{"extra-file", "A file on disk is not listed in the manifest"},
+}
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                   `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactURI `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult               `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifArtifactURI struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactURI `json:"artifactLocation"`
+}
+
+func writeSARIF(w io.Writer, report *Report) error {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "HyLauncher verify"}},
+		Results: []sarifResult{},
+	}
+	for _, r := range sarifRules {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: r.id, ShortDescription: sarifMessage{Text: r.text}})
+	}
+	if report.GameDir != "" {
+		if abs, err := filepath.Abs(report.GameDir); err == nil {
+			u := url.URL{Scheme: "file", Path: filepath.ToSlash(abs) + "/"}
+			run.OriginalURIBaseIDs = map[string]sarifArtifactURI{"GAMEDIR": {URI: u.String()}}
+		}
+	}
+
+	for _, f := range report.Files {
+		level := ""
+		switch f.Status {
+		case StatusFailed:
+			level = "error"
+		case StatusWarning:
+			level = "warning"
+		default:
+			continue
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  issueRule(f),
+			Level:   level,
+			Message: sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactURI{URI: escapeURIPath(f.Path), URIBaseID: "GAMEDIR"},
+			}}},
+		})
+	}
+
+	return writeJSON(w, sarifLog{Version: "2.1.0", Schema: sarifSchema, Runs: []sarifRun{run}})
+}
+
+// escapeURIPath escapes each segment of a slash-separated relative path
+func escapeURIPath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
+
+// HTML, a single page support can open in a browser
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"rule": issueRule,
+	"time": func(t time.Time) string { return t.Format("2006-01-02 15:04:05") },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Verification report {{.Version}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.Failed { color: #b00020; }
+.Warning { color: #a06000; }
+.OK { color: #107010; }
+</style>
+</head>
+<body>
+<h1>Verification report</h1>
+<table>
+<tr><th>Version</th><td>{{.Version}}</td></tr>
+<tr><th>Game directory</th><td>{{.GameDir}}</td></tr>
+<tr><th>Time</th><td>{{time .Timestamp}}</td></tr>
+{{- if .Manifest}}
+<tr><th>Manifest</th><td>{{.Manifest}}{{if .ManifestSigned}} (signed){{end}}</td></tr>
+{{- end}}
+<tr><th>Status</th><td class="{{.OverallStatus}}">{{.OverallStatus}}</td></tr>
+</table>
+<h2>Summary</h2>
+<table>
+<tr><th>Total</th><th>Passed</th><th>Failed</th><th>Warnings</th><th>Missing</th><th>Modified</th><th>Extra</th></tr>
+<tr><td>{{.Summary.TotalFiles}}</td><td>{{.Summary.Passed}}</td><td>{{.Summary.Failed}}</td><td>{{.Summary.Warnings}}</td><td>{{.Summary.MissingFiles}}</td><td>{{.Summary.ModifiedFiles}}</td><td>{{.Summary.ExtraFiles}}</td></tr>
+</table>
+<h2>Files needing attention</h2>
+<table>
+<tr><th>Status</th><th>Path</th><th>Issue</th><th>Message</th></tr>
+{{- range .Files}}{{if .NeedsAttention}}
+<tr><td class="{{.Status}}">{{.Status}}</td><td>{{.Path}}</td><td>{{rule .}}</td><td>{{.Message}}</td></tr>
+{{- end}}{{end}}
+</table>
+</body>
+</html>
+`))
+
+func writeHTML(w io.Writer, report *Report) error {
+	return htmlTemplate.Execute(w, report)
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package verify
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileID returns the inode of a file, so a file replaced by another with the
+// same size and modification time is still hashed again
+func fileID(_ string, stat os.FileInfo) uint64 {
+	if st, ok := stat.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
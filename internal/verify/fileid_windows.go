@@ -0,0 +1,25 @@
+//go:build windows
+
+package verify
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// fileID returns the NTFS file index of a file, the closest Windows has to an
+// inode. Stat doesn't expose it, so the file is opened to ask for it.
+func fileID(path string, _ os.FileInfo) uint64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(windows.Handle(f.Fd()), &info); err != nil {
+		return 0
+	}
+	return uint64(info.FileIndexHigh)<<32 | uint64(info.FileIndexLow)
+}
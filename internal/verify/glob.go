@@ -0,0 +1,54 @@
+package verify
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// MatchGlob reports whether a relative path matches a glob pattern.
+// Patterns use forward slashes; "**" matches any number of directories and a
+// pattern without a slash matches the base name at any depth, so "*.log"
+// covers every log file.
+func MatchGlob(pattern, relPath string) bool {
+	relPath = filepath.ToSlash(relPath)
+	pattern = filepath.ToSlash(pattern)
+
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(relPath))
+		return matched
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(relPath, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try every split of the remaining path, including none
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], parts[0]); !matched {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// matchAny reports whether relPath matches one of the patterns
+func matchAny(patterns []string, relPath string) bool {
+	for _, pattern := range patterns {
+		if MatchGlob(pattern, relPath) {
+			return true
+		}
+	}
+	return false
+}
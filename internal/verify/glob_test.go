@@ -0,0 +1,55 @@
+package verify
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, path string
+		want          bool
+	}{
+		{"*.log", "client.log", true},
+		{"*.log", "logs/deep/client.log", true},
+		{"*.log", "client.log.1", false},
+		{"Client/*.dll", "Client/HytaleClient.dll", true},
+		{"Client/*.dll", "Client/x64/native.dll", false},
+		{"Client/**/*.dll", "Client/native.dll", true},
+		{"Client/**/*.dll", "Client/x64/sub/native.dll", true},
+		{"Client/**", "Client/Data/assets.zip", true},
+		{"Client/**", "Server/HytaleServer.jar", false},
+		{"**/config.json", "config.json", true},
+		{"**/config.json", "UserData/Saves/world/config.json", true},
+		{".backups/**", ".backups/points/auto/manifest.json", true},
+		{"Server/Hytale?erver.jar", "Server/HytaleServer.jar", true},
+		{"Server/[A-G]*.jar", "Server/HytaleServer.jar", false},
+	}
+	for _, tt := range tests {
+		if got := MatchGlob(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestManifestSelects(t *testing.T) {
+	tests := []struct {
+		name             string
+		include, exclude []string
+		path             string
+		want             bool
+	}{
+		{"everything by default", nil, nil, "Client/HytaleClient", true},
+		{"default excludes", nil, DefaultExcludes, ".patch-state.json", false},
+		{"default excluded logs", nil, DefaultExcludes, "logs/2026-10-17.log", false},
+		{"included", []string{"Client/**", "Server/**"}, nil, "Server/HytaleServer.jar", true},
+		{"not included", []string{"Client/**"}, nil, "UserData/settings.json", false},
+		{"exclude wins over include", []string{"Client/**"}, []string{"Client/**/*.pdb"}, "Client/x64/HytaleClient.pdb", false},
+		{"include with unrelated exclude", []string{"Client/**"}, []string{"*.tmp"}, "Client/HytaleClient", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manifest{Include: tt.include, Exclude: tt.exclude}
+			if got := m.Selects(tt.path); got != tt.want {
+				t.Errorf("Selects(%q) = %v, want %v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"runtime"
+	"sync"
 )
 
 // HashCalculator provides progress-aware file hashing
@@ -124,6 +126,38 @@ func QuickVerify(filePath string, expectedSize int64) (bool, int64, error) {
 	return stat.Size() == expectedSize, stat.Size(), nil
 }
 
+// hashWorkers returns how many files to hash at once, one per CPU by default
+func hashWorkers(workers int) int {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	return workers
+}
+
+// forEachParallel calls fn for every index below n from up to workers goroutines
+func forEachParallel(n, workers int, fn func(i int)) {
+	if workers > n {
+		workers = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
 // CalculateHashSimple computes SHA-256 hash without progress reporting
 func CalculateHashSimple(filePath string) (string, int64, error) {
 	calculator := NewHashCalculator(nil, 0)
@@ -0,0 +1,157 @@
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryDirName is the directory in the game dir that keeps past reports,
+// so every install slot has its own history
+const HistoryDirName = ".verify-history"
+
+// DefaultHistoryLimit is how many reports are kept per install
+const DefaultHistoryLimit = 30
+
+// HistoryEntry describes a stored report without its file list
+type HistoryEntry struct {
+	Path          string             `json:"path"`
+	Timestamp     time.Time          `json:"timestamp"`
+	Version       string             `json:"version"`
+	OverallStatus VerificationStatus `json:"overall_status"`
+	Summary       Summary            `json:"summary"`
+}
+
+// GetDefaultHistoryDir returns where the reports of a game directory are kept
+func GetDefaultHistoryDir(gameDir string) string {
+	return filepath.Join(gameDir, HistoryDirName)
+}
+
+// SaveReport stores a report in the history directory and drops the oldest
+// reports beyond limit (DefaultHistoryLimit when 0). Returns the report path.
+func SaveReport(historyDir string, report *Report, limit int) (string, error) {
+	if err := os.MkdirAll(historyDir, 0755); err != nil {
+		return "", fmt.Errorf("create history directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode report: %w", err)
+	}
+
+	ts := report.Timestamp
+	name := fmt.Sprintf("report_%s_%03d.json", ts.Format("20060102_150405"), ts.Nanosecond()/int(time.Millisecond))
+	path := filepath.Join(historyDir, name)
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", fmt.Errorf("write report: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("store report: %w", err)
+	}
+
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	pruneHistory(historyDir, limit)
+	return path, nil
+}
+
+// LoadReport reads a stored report
+func LoadReport(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read report: %w", err)
+	}
+	var report Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("parse report %s: %w", filepath.Base(path), err)
+	}
+	return &report, nil
+}
+
+// ListHistory returns the stored reports of a history directory, newest
+// first. Reports that can't be read are skipped.
+func ListHistory(historyDir string) ([]HistoryEntry, error) {
+	paths, err := historyFiles(historyDir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]HistoryEntry, 0, len(paths))
+	for i := len(paths) - 1; i >= 0; i-- {
+		report, err := LoadReport(paths[i])
+		if err != nil {
+			continue
+		}
+		entries = append(entries, HistoryEntry{
+			Path:          paths[i],
+			Timestamp:     report.Timestamp,
+			Version:       report.Version,
+			OverallStatus: report.OverallStatus,
+			Summary:       report.Summary,
+		})
+	}
+	return entries, nil
+}
+
+// LastGoodReport returns the newest stored report older than before in which
+// every file passed. A zero before searches the whole history.
+func LastGoodReport(historyDir string, before time.Time) (*Report, error) {
+	entries, err := ListHistory(historyDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if !before.IsZero() && !entry.Timestamp.Before(before) {
+			continue
+		}
+		if entry.OverallStatus == StatusOK {
+			return LoadReport(entry.Path)
+		}
+	}
+	return nil, &VerificationError{
+		Op:      "find report",
+		Message: "no earlier verification passed",
+	}
+}
+
+// historyFiles lists the report files of a history directory, oldest first.
+// The names sort by time.
+func historyFiles(historyDir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(historyDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read history directory: %w", err)
+	}
+
+	var paths []string
+	for _, e := range dirEntries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, "report_") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		paths = append(paths, filepath.Join(historyDir, name))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// pruneHistory removes the oldest reports so at most limit remain
+func pruneHistory(historyDir string, limit int) {
+	paths, err := historyFiles(historyDir)
+	if err != nil || len(paths) <= limit {
+		return
+	}
+	for _, path := range paths[:len(paths)-limit] {
+		_ = os.Remove(path)
+	}
+}
@@ -6,16 +6,29 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 )
 
+// FileRole tells what a file is used for in the installation
+type FileRole string
+
+const (
+	RoleExecutable FileRole = "executable"
+	RoleLibrary    FileRole = "library"
+	RoleConfig     FileRole = "config"
+	RoleAsset      FileRole = "asset"
+)
+
 // FileInfo represents expected file metadata in the manifest
 type FileInfo struct {
-	Size   int64  `json:"size"`
-	SHA256 string `json:"sha256"`
+	Size   int64    `json:"size"`
+	SHA256 string   `json:"sha256"`
+	Role   FileRole `json:"role,omitempty"`
 }
 
 // Manifest contains the expected file states for verification
@@ -24,6 +37,44 @@ type Manifest struct {
 	CreatedAt time.Time           `json:"created_at"`
 	Files     map[string]FileInfo `json:"files"`
 	Ignore    []string            `json:"ignore,omitempty"`
+
+	// Include and Exclude are the globs the manifest was generated with
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// Complete is set when Files lists every file the globs select, so
+	// anything else on disk is extra
+	Complete bool `json:"complete,omitempty"`
+	// Generated marks a manifest hashed from a local install, which may
+	// already be damaged, so it is not trusted by default
+	Generated bool `json:"generated,omitempty"`
+}
+
+// ManifestOptions controls which files GenerateManifestWithOptions hashes
+type ManifestOptions struct {
+	Version string
+	// Include selects files to hash, every file when empty
+	Include []string
+	// Exclude drops files selected by Include
+	Exclude []string
+	// Ignore is stored in the manifest and skipped during verification
+	Ignore []string
+	// Workers is the number of files hashed at once (default: CPU count)
+	Workers int
+}
+
+// DefaultExcludes are launcher bookkeeping files that live in the game dir
+// but are not part of the game
+var DefaultExcludes = []string{
+	".backups/**",
+	"*.original",
+	"*.tmp",
+	"*.repair",
+	"*.log",
+	"logs/**",
+	".version",
+	".linked",
+	".patch-state.json",
+	HistoryDirName + "/**",
 }
 
 // ManifestEntry represents a single file entry with its relative path
@@ -101,72 +152,118 @@ func (m *Manifest) GetExpectedInfo(relativePath string) (FileInfo, bool) {
 
 // GenerateManifest creates a new manifest by scanning a directory
 func GenerateManifest(gameDir string, version string, ignoreList []string) (*Manifest, error) {
+	return GenerateManifestWithOptions(gameDir, ManifestOptions{
+		Version: version,
+		Exclude: DefaultExcludes,
+		Ignore:  ignoreList,
+	})
+}
+
+// GenerateManifestWithOptions walks the whole game directory and hashes every
+// regular file the include and exclude globs select
+func GenerateManifestWithOptions(gameDir string, options ManifestOptions) (*Manifest, error) {
 	manifest := &Manifest{
-		Version:   version,
+		Version:   options.Version,
 		CreatedAt: time.Now(),
 		Files:     make(map[string]FileInfo),
-		Ignore:    ignoreList,
+		Ignore:    options.Ignore,
+		Include:   options.Include,
+		Exclude:   options.Exclude,
+		Complete:  true,
+		Generated: true,
 	}
 
-	// Key files to include in priority order
-	keyFiles := []string{
-		"Assets.zip",
-		filepath.Join("Client", "HytaleClient.jar"),
-		filepath.Join("Client", "HytaleClient.exe"),
-		filepath.Join("config", "client.json"),
+	type entry struct {
+		relPath, fullPath string
+		d                 fs.DirEntry
 	}
-
-	// Add key files first
-	for _, relPath := range keyFiles {
-		fullPath := filepath.Join(gameDir, relPath)
-		if _, err := os.Stat(fullPath); err == nil {
-			info, err := calculateFileInfo(fullPath)
-			if err != nil {
-				continue // Skip files we can't read
-			}
-			manifest.Files[filepath.ToSlash(relPath)] = info
+	var entries []entry
+	err := manifest.walk(gameDir, func(relPath, fullPath string, d fs.DirEntry) {
+		entries = append(entries, entry{relPath, fullPath, d})
+	})
+	if err != nil {
+		return nil, &VerificationError{
+			Op:      "scan game directory",
+			Path:    gameDir,
+			Err:     err,
+			Message: fmt.Sprintf("failed to scan %s: %v", gameDir, err),
 		}
 	}
 
-	// Scan Client/lib for JAR files
-	libDir := filepath.Join(gameDir, "Client", "lib")
-	if entries, err := os.ReadDir(libDir); err == nil {
-		for _, entry := range entries {
-			if entry.IsDir() {
-				continue
-			}
-			if filepath.Ext(entry.Name()) == ".jar" {
-				relPath := filepath.Join("Client", "lib", entry.Name())
-				fullPath := filepath.Join(gameDir, relPath)
-				info, err := calculateFileInfo(fullPath)
-				if err != nil {
-					continue
-				}
-				manifest.Files[filepath.ToSlash(relPath)] = info
-			}
+	infos := make([]FileInfo, len(entries))
+	ok := make([]bool, len(entries))
+	forEachParallel(len(entries), hashWorkers(options.Workers), func(i int) {
+		info, err := calculateFileInfo(entries[i].fullPath)
+		if err != nil {
+			return // Skip files we can't read
+		}
+		if stat, err := entries[i].d.Info(); err == nil {
+			info.Role = classifyRole(entries[i].relPath, stat.Mode())
+		}
+		infos[i], ok[i] = info, true
+	})
+	for i, e := range entries {
+		if ok[i] {
+			manifest.Files[e.relPath] = infos[i]
 		}
 	}
 
-	// Scan patches directory for .pw files
-	patchesDir := filepath.Join(gameDir, "patches")
-	if entries, err := os.ReadDir(patchesDir); err == nil {
-		for _, entry := range entries {
-			if entry.IsDir() {
-				continue
-			}
-			if filepath.Ext(entry.Name()) == ".pw" {
-				relPath := filepath.Join("patches", entry.Name())
-				fullPath := filepath.Join(gameDir, relPath)
-				info, err := calculateFileInfo(fullPath)
-				if err != nil {
-					continue
-				}
-				manifest.Files[filepath.ToSlash(relPath)] = info
+	return manifest, nil
+}
+
+// Selects reports whether the manifest's globs cover relPath
+func (m *Manifest) Selects(relPath string) bool {
+	if len(m.Include) > 0 && !matchAny(m.Include, relPath) {
+		return false
+	}
+	return !matchAny(m.Exclude, relPath)
+}
+
+// walk calls fn for every regular file under gameDir the manifest selects,
+// with its slash-separated relative path
+func (m *Manifest) walk(gameDir string, fn func(relPath, fullPath string, d fs.DirEntry)) error {
+	return filepath.WalkDir(gameDir, func(fullPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if fullPath == gameDir {
+				return err
 			}
+			return nil // Skip unreadable directories
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(gameDir, fullPath)
+		if err != nil {
+			return nil
 		}
+		relPath := filepath.ToSlash(rel)
+		if m.Selects(relPath) {
+			fn(relPath, fullPath, d)
+		}
+		return nil
+	})
+}
+
+// classifyRole guesses the role of a file from its name and mode
+func classifyRole(relPath string, mode fs.FileMode) FileRole {
+	name := strings.ToLower(filepath.Base(relPath))
+	ext := filepath.Ext(name)
+
+	switch {
+	case ext == ".exe", strings.Contains(relPath, ".app/Contents/MacOS/"):
+		return RoleExecutable
+	case ext == ".dll", ext == ".so", ext == ".dylib", ext == ".jar", strings.Contains(name, ".so."):
+		return RoleLibrary
+	case mode&0111 != 0 && ext == "":
+		return RoleExecutable
 	}
 
-	return manifest, nil
+	switch ext {
+	case ".json", ".toml", ".xml", ".ini", ".cfg", ".properties", ".yml", ".yaml", ".plist":
+		return RoleConfig
+	}
+	return RoleAsset
 }
 
 // calculateFileInfo computes size and SHA-256 hash for a file
@@ -216,16 +313,5 @@ func getLauncherDir() string {
 
 // IsIgnored checks if a file path matches any pattern in the ignore list
 func (m *Manifest) IsIgnored(relativePath string) bool {
-	for _, pattern := range m.Ignore {
-		matched, _ := filepath.Match(pattern, relativePath)
-		if matched {
-			return true
-		}
-		// Also check with ToSlash for cross-platform compatibility
-		matched, _ = filepath.Match(pattern, filepath.ToSlash(relativePath))
-		if matched {
-			return true
-		}
-	}
-	return false
+	return matchAny(m.Ignore, relativePath)
 }
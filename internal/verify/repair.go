@@ -0,0 +1,194 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"HyLauncher/pkg/fileutil"
+)
+
+// repairSuffix marks a repaired copy that is still being checked
+const repairSuffix = ".repair"
+
+// RepairFile is a damaged file and where a source puts its replacement
+type RepairFile struct {
+	// Path is the slash-separated path in the game directory
+	Path string
+	// Target is the damaged file on disk
+	Target string
+	// Expected is what the file should be, from the manifest
+	Expected FileInfo
+	// Dest is where the source writes the replacement
+	Dest string
+}
+
+// RepairSource provides good copies of damaged files
+type RepairSource interface {
+	Name() string
+	// Fetch writes every file it can provide to its Dest and leaves the
+	// others alone, they are passed on to the next source
+	Fetch(ctx context.Context, files []RepairFile) error
+}
+
+// RepairResult is the outcome of repairing one file
+type RepairResult struct {
+	Path     string `json:"path"`
+	Repaired bool   `json:"repaired"`
+	Source   string `json:"source,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RepairReport lists what a repair did and the verification that followed it
+type RepairReport struct {
+	Files        []RepairResult `json:"files"`
+	Repaired     int            `json:"repaired"`
+	Failed       int            `json:"failed"`
+	Verification *Report        `json:"verification,omitempty"`
+}
+
+// Repair restores the files a verification report flagged, trying a matching
+// backup first and then each source in order. Every replacement must match
+// the manifest hash before it is moved into place. The game directory is
+// verified again afterwards.
+func Repair(ctx context.Context, options Options, report *Report, sources []RepairSource) (*RepairReport, error) {
+	chain := append([]RepairSource{&backupSource{manager: NewBackupManager(options.BackupDir, options.GameDir, true)}}, sources...)
+
+	results := make(map[string]*RepairResult)
+	var pending []RepairFile
+	for _, f := range report.Files {
+		if !f.NeedsAttention() || f.Extra || f.ExpectedHash == "" {
+			continue
+		}
+		target := filepath.Join(options.GameDir, filepath.FromSlash(f.Path))
+		_ = os.MkdirAll(filepath.Dir(target), 0755)
+		pending = append(pending, RepairFile{
+			Path:     f.Path,
+			Target:   target,
+			Expected: FileInfo{Size: f.ExpectedSize, SHA256: f.ExpectedHash, Role: f.Role},
+			Dest:     target + repairSuffix,
+		})
+		results[f.Path] = &RepairResult{Path: f.Path}
+	}
+
+	for _, source := range chain {
+		if len(pending) == 0 {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		for _, f := range pending {
+			_ = os.Remove(f.Dest)
+		}
+
+		if err := source.Fetch(ctx, pending); err != nil {
+			for _, f := range pending {
+				results[f.Path].Error = fmt.Sprintf("%s: %v", source.Name(), err)
+			}
+		}
+
+		var left []RepairFile
+		for _, f := range pending {
+			if err := installRepaired(f); err != nil {
+				if !os.IsNotExist(err) {
+					results[f.Path].Error = fmt.Sprintf("%s: %v", source.Name(), err)
+				}
+				_ = os.Remove(f.Dest)
+				left = append(left, f)
+				continue
+			}
+			results[f.Path].Repaired = true
+			results[f.Path].Source = source.Name()
+			results[f.Path].Error = ""
+		}
+		pending = left
+	}
+
+	repair := &RepairReport{}
+	for _, f := range report.Files {
+		result, ok := results[f.Path]
+		if !ok {
+			continue
+		}
+		if result.Repaired {
+			repair.Repaired++
+		} else {
+			repair.Failed++
+			if result.Error == "" {
+				result.Error = "no source could provide this file"
+			}
+		}
+		repair.Files = append(repair.Files, *result)
+	}
+
+	// Nothing changed on disk, the old report still holds
+	if repair.Repaired == 0 {
+		repair.Verification = report
+		return repair, nil
+	}
+
+	verification, err := VerifyWithOptions(options)
+	if err != nil {
+		return repair, fmt.Errorf("verify after repair: %w", err)
+	}
+	repair.Verification = verification
+	return repair, nil
+}
+
+// installRepaired checks the replacement of f against the manifest and moves
+// it over the damaged file
+func installRepaired(f RepairFile) error {
+	stat, err := os.Stat(f.Dest)
+	if err != nil {
+		return err
+	}
+	if stat.Size() != f.Expected.Size {
+		return fmt.Errorf("size mismatch: expected %d, got %d", f.Expected.Size, stat.Size())
+	}
+
+	hash, _, err := CalculateHashSimple(f.Dest)
+	if err != nil {
+		return err
+	}
+	if hash != f.Expected.SHA256 {
+		return fmt.Errorf("hash mismatch")
+	}
+
+	mode := os.FileMode(0644)
+	if f.Expected.Role == RoleExecutable {
+		mode = 0755
+	}
+	if err := os.Chmod(f.Dest, mode); err != nil {
+		return err
+	}
+
+	return fileutil.MoveFile(f.Dest, f.Target)
+}
+
+// backupSource restores files from the backups verification made of them
+type backupSource struct {
+	manager *BackupManager
+}
+
+func (b *backupSource) Name() string {
+	return "backup"
+}
+
+func (b *backupSource) Fetch(ctx context.Context, files []RepairFile) error {
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		backupPath, err := b.manager.FindBackup(f.Target, f.Expected.SHA256)
+		if err != nil || backupPath == "" {
+			continue
+		}
+		if err := fileutil.CopyFile(backupPath, f.Dest); err != nil {
+			_ = os.Remove(f.Dest)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+package verify
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"HyLauncher/pkg/fileutil"
+)
+
+// RestorePointKind says why a restore point was made
+type RestorePointKind string
+
+const (
+	// RestorePointManual covers a whole install at a moment chosen by the user
+	RestorePointManual RestorePointKind = "manual"
+	// RestorePointAuto holds files verification found modified before they
+	// are touched
+	RestorePointAuto RestorePointKind = "auto"
+)
+
+// BackupEntry is a file of a restore point
+type BackupEntry struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+	Mode   uint32 `json:"mode,omitempty"` // permission bits
+}
+
+// RestorePoint records the content of the files of an install at one moment.
+// The contents themselves are blobs in the backup store.
+type RestorePoint struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name,omitempty"`
+	Kind      RestorePointKind `json:"kind"`
+	CreatedAt time.Time        `json:"created_at"`
+	GameDir   string           `json:"game_dir"`
+	Version   string           `json:"version,omitempty"`
+	// Partial points hold only some files of the install
+	Partial bool                   `json:"partial,omitempty"`
+	Files   map[string]BackupEntry `json:"files"`
+}
+
+// newRestorePoint starts an empty restore point of a game directory
+func newRestorePoint(kind RestorePointKind, gameDir string) *RestorePoint {
+	if abs, err := filepath.Abs(gameDir); err == nil {
+		gameDir = abs
+	}
+	now := time.Now()
+	return &RestorePoint{
+		ID:        newPointID(now),
+		Kind:      kind,
+		CreatedAt: now,
+		GameDir:   filepath.Clean(gameDir),
+		Files:     make(map[string]BackupEntry),
+	}
+}
+
+// Size returns the total size of the files, before deduplication
+func (p *RestorePoint) Size() int64 {
+	var size int64
+	for _, f := range p.Files {
+		size += f.Size
+	}
+	return size
+}
+
+// RestorePointOptions configures CreateRestorePoint
+type RestorePointOptions struct {
+	// Name labels the restore point, it can be used instead of the ID
+	Name string
+	// Version is the game version of the install
+	Version string
+	// Exclude are globs of files to leave out, added to DefaultExcludes
+	Exclude []string
+	// Workers is the number of files stored at once (default: CPU count)
+	Workers int
+}
+
+// CreateRestorePoint stores every file of an install and records them in a
+// new restore point. Files already in the store are not copied again, and
+// files the verification cache knows as unchanged are not hashed again.
+func (s *BackupStore) CreateRestorePoint(gameDir string, options RestorePointOptions) (*RestorePoint, error) {
+	point := newRestorePoint(RestorePointManual, gameDir)
+	point.Name = options.Name
+	point.Version = options.Version
+
+	selector := &Manifest{Exclude: append(append([]string{}, DefaultExcludes...), options.Exclude...)}
+	type entry struct {
+		relPath, fullPath string
+	}
+	var entries []entry
+	err := selector.walk(point.GameDir, func(relPath, fullPath string, d fs.DirEntry) {
+		entries = append(entries, entry{relPath, fullPath})
+	})
+	if err != nil {
+		return nil, &VerificationError{
+			Op:      "scan game directory",
+			Path:    gameDir,
+			Err:     err,
+			Message: fmt.Sprintf("failed to scan %s: %v", gameDir, err),
+		}
+	}
+
+	cache := LoadHashCache(GetDefaultCachePath(point.GameDir))
+
+	var mu sync.Mutex
+	var firstErr error
+	forEachParallel(len(entries), hashWorkers(options.Workers), func(i int) {
+		e := entries[i]
+		stat, err := os.Stat(e.fullPath)
+		if err != nil {
+			return // Removed while scanning
+		}
+		id := fileID(e.fullPath, stat)
+		cached, _ := cache.Lookup(e.relPath, stat, id)
+
+		hash, size, err := s.PutFile(e.fullPath, cached)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("back up %s: %w", e.relPath, err)
+			}
+			return
+		}
+		point.Files[e.relPath] = BackupEntry{Size: size, SHA256: hash, Mode: uint32(stat.Mode().Perm())}
+		if hash != cached {
+			cache.Store(e.relPath, stat, id, hash)
+		}
+	})
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	// Only the cache is lost if this fails
+	_ = cache.Save()
+
+	if err := s.SaveRestorePoint(point); err != nil {
+		return nil, err
+	}
+	return point, nil
+}
+
+// RestoreOptions configures Restore
+type RestoreOptions struct {
+	// GameDir is where to restore to (default: the install the point was made of)
+	GameDir string
+	// Paths are globs of the files to restore (default: all)
+	Paths []string
+	// RemoveExtra deletes files the restore point does not hold, so the
+	// install ends up exactly as it was. Only full restore points allow it.
+	RemoveExtra bool
+}
+
+// RestoreResult lists what a restore did
+type RestoreResult struct {
+	Restored  []string          `json:"restored"`
+	Unchanged int               `json:"unchanged"`
+	Removed   []string          `json:"removed,omitempty"`
+	Failed    map[string]string `json:"failed,omitempty"`
+}
+
+// Restore puts the files of a restore point back. Files that still have the
+// recorded content are left alone.
+func (s *BackupStore) Restore(point *RestorePoint, options RestoreOptions) (*RestoreResult, error) {
+	gameDir := options.GameDir
+	if gameDir == "" {
+		gameDir = point.GameDir
+	}
+	if options.RemoveExtra && (point.Partial || len(options.Paths) > 0) {
+		return nil, &VerificationError{
+			Op:      "restore",
+			Message: "extra files can only be removed when restoring a whole install",
+		}
+	}
+
+	result := &RestoreResult{Restored: []string{}, Failed: make(map[string]string)}
+	for relPath, entry := range point.Files {
+		if len(options.Paths) > 0 && !matchAny(options.Paths, relPath) {
+			continue
+		}
+
+		target := filepath.Join(gameDir, filepath.FromSlash(relPath))
+		if stat, err := os.Stat(target); err == nil && stat.Size() == entry.Size {
+			if hash, _, err := CalculateHashSimple(target); err == nil && hash == entry.SHA256 {
+				result.Unchanged++
+				continue
+			}
+		}
+
+		if err := s.restoreBlob(entry, target); err != nil {
+			result.Failed[relPath] = err.Error()
+			continue
+		}
+		result.Restored = append(result.Restored, relPath)
+	}
+
+	if options.RemoveExtra {
+		selector := &Manifest{Exclude: DefaultExcludes}
+		err := selector.walk(gameDir, func(relPath, fullPath string, d fs.DirEntry) {
+			if _, ok := point.Files[relPath]; ok {
+				return
+			}
+			if err := os.Remove(fullPath); err != nil {
+				result.Failed[relPath] = err.Error()
+				return
+			}
+			result.Removed = append(result.Removed, relPath)
+		})
+		if err != nil {
+			return result, fmt.Errorf("scan for extra files: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// restoreBlob copies a stored file into place through a temporary file
+func (s *BackupStore) restoreBlob(entry BackupEntry, target string) error {
+	blob, ok := s.BlobPath(entry.SHA256)
+	if !ok {
+		return fmt.Errorf("content %s is not in the backup store", entry.SHA256[:min(12, len(entry.SHA256))])
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	tmp := target + ".tmp"
+	if err := fileutil.CopyFile(blob, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("copy from backup: %w", err)
+	}
+	if entry.Mode != 0 {
+		_ = os.Chmod(tmp, fs.FileMode(entry.Mode))
+	}
+	if err := fileutil.MoveFile(tmp, target); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("replace file: %w", err)
+	}
+	return nil
+}
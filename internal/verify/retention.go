@@ -0,0 +1,195 @@
+package verify
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RetentionPolicy limits how many restore points the backup store keeps.
+// Zero fields don't limit anything. Auto and manual points are counted
+// separately, and manual points are only pruned when IncludeManual is set.
+type RetentionPolicy struct {
+	// KeepLast keeps only the newest restore points of each kind
+	KeepLast int `json:"keep_last,omitempty"`
+	// MaxSize caps the stored content in bytes. Points that are not pruned
+	// count first, then the newest prunable point is kept even if it is
+	// larger on its own.
+	MaxSize int64 `json:"max_size,omitempty"`
+	// MaxAge drops restore points older than this
+	MaxAge time.Duration `json:"max_age,omitempty"`
+	// IncludeManual applies the policy to manual restore points as well
+	IncludeManual bool `json:"include_manual,omitempty"`
+}
+
+// DefaultRetention is applied after verification backs up files. It never
+// touches the restore points a user made.
+var DefaultRetention = RetentionPolicy{
+	KeepLast: 20,
+	MaxAge:   90 * 24 * time.Hour,
+}
+
+// IsZero reports whether the policy keeps everything
+func (p RetentionPolicy) IsZero() bool {
+	return p.KeepLast <= 0 && p.MaxSize <= 0 && p.MaxAge <= 0
+}
+
+// prunable reports whether the policy may remove a point
+func (p RetentionPolicy) prunable(point *RestorePoint) bool {
+	return point.Kind != RestorePointManual || p.IncludeManual
+}
+
+// PruneResult lists what a prune removed
+type PruneResult struct {
+	Points []string `json:"points"`
+	Blobs  int      `json:"blobs"`
+	Freed  int64    `json:"freed"`
+}
+
+// incomingMaxAge is how long a half-written blob may stay before a prune
+// assumes its writer is gone
+const incomingMaxAge = time.Hour
+
+// Prune deletes the restore points the policy doesn't keep, then every
+// stored content no remaining point uses
+func (s *BackupStore) Prune(policy RetentionPolicy) (*PruneResult, error) {
+	points, err := s.ListRestorePoints()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PruneResult{Points: []string{}}
+	var kept []*RestorePoint
+	cutoff := time.Now().Add(-policy.MaxAge)
+	seenOfKind := make(map[RestorePointKind]int)
+	for _, p := range points {
+		if !policy.prunable(p) {
+			kept = append(kept, p)
+			continue
+		}
+		seenOfKind[p.Kind]++
+		if (policy.KeepLast > 0 && seenOfKind[p.Kind] > policy.KeepLast) || (policy.MaxAge > 0 && p.CreatedAt.Before(cutoff)) {
+			result.Points = append(result.Points, p.ID)
+			continue
+		}
+		kept = append(kept, p)
+	}
+
+	if policy.MaxSize > 0 {
+		// The points the policy can't remove take their space first
+		seen := make(map[string]bool)
+		var size int64
+		add := func(p *RestorePoint) int64 {
+			var added int64
+			for _, f := range p.Files {
+				if !seen[f.SHA256] {
+					added += f.Size
+				}
+			}
+			return added
+		}
+		mark := func(p *RestorePoint) {
+			for _, f := range p.Files {
+				seen[f.SHA256] = true
+			}
+		}
+		for _, p := range kept {
+			if !policy.prunable(p) {
+				size += add(p)
+				mark(p)
+			}
+		}
+
+		// Newest first, a prunable point stays while the content it adds fits
+		var fits []*RestorePoint
+		first := true
+		for _, p := range kept {
+			if !policy.prunable(p) {
+				fits = append(fits, p)
+				continue
+			}
+			added := add(p)
+			if !first && size+added > policy.MaxSize {
+				result.Points = append(result.Points, p.ID)
+				continue
+			}
+			first = false
+			mark(p)
+			size += added
+			fits = append(fits, p)
+		}
+		kept = fits
+	}
+
+	for _, id := range result.Points {
+		if err := s.DeleteRestorePoint(id); err != nil {
+			return result, err
+		}
+	}
+
+	used := make(map[string]bool)
+	for _, p := range kept {
+		for _, f := range p.Files {
+			used[f.SHA256] = true
+		}
+	}
+	result.Blobs, result.Freed = s.removeBlobs(func(name string) bool { return !used[name] })
+	return result, nil
+}
+
+// Usage returns the size and number of stored contents
+func (s *BackupStore) Usage() (int64, int, error) {
+	var size int64
+	var count int
+	err := filepath.WalkDir(filepath.Join(s.dir, blobsDirName), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !isSHA256(d.Name()) {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+			count++
+		}
+		return nil
+	})
+	return size, count, err
+}
+
+// removeBlobs deletes the stored contents drop selects, and half-written
+// ones left behind by a crash
+func (s *BackupStore) removeBlobs(drop func(name string) bool) (int, int64) {
+	count := 0
+	var freed int64
+	_ = filepath.WalkDir(filepath.Join(s.dir, blobsDirName), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		name := d.Name()
+		if strings.HasPrefix(name, ".incoming-") {
+			if time.Since(info.ModTime()) < incomingMaxAge {
+				return nil
+			}
+		} else if !isSHA256(name) || !drop(name) {
+			return nil
+		}
+
+		if err := os.Remove(path); err == nil {
+			count++
+			freed += info.Size()
+		}
+		return nil
+	})
+	return count, freed
+}
@@ -0,0 +1,183 @@
+package verify
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// testPoint describes a restore point for the fixture store
+type testPoint struct {
+	id    string
+	kind  RestorePointKind
+	age   time.Duration
+	files map[string]string // path -> content
+}
+
+func newTestStore(t *testing.T, points []testPoint) *BackupStore {
+	t.Helper()
+	store := OpenBackupStore(t.TempDir())
+	src := t.TempDir()
+
+	for _, tp := range points {
+		point := &RestorePoint{
+			ID:        tp.id,
+			Kind:      tp.kind,
+			CreatedAt: time.Now().Add(-tp.age),
+			GameDir:   "/game",
+			Files:     make(map[string]BackupEntry),
+		}
+		for rel, content := range tp.files {
+			path := filepath.Join(src, "content")
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			hash, size, err := store.PutFile(path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			point.Files[rel] = BackupEntry{Size: size, SHA256: hash, Mode: 0644}
+		}
+		if err := store.SaveRestorePoint(point); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+func pointIDs(t *testing.T, store *BackupStore) []string {
+	t.Helper()
+	points, err := store.ListRestorePoints()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 0, len(points))
+	for _, p := range points {
+		ids = append(ids, p.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestPrune(t *testing.T) {
+	day := 24 * time.Hour
+	mixed := []testPoint{
+		{"auto-1", RestorePointAuto, 1 * day, map[string]string{"a": "auto one"}},
+		{"auto-2", RestorePointAuto, 2 * day, map[string]string{"a": "auto two"}},
+		{"auto-3", RestorePointAuto, 3 * day, map[string]string{"a": "auto three"}},
+		{"manual-1", RestorePointManual, 4 * day, map[string]string{"a": "manual one", "b": "shared"}},
+		{"manual-2", RestorePointManual, 200 * day, map[string]string{"a": "manual two", "b": "shared"}},
+	}
+
+	tests := []struct {
+		name   string
+		points []testPoint
+		policy RetentionPolicy
+		want   []string
+	}{
+		{
+			name:   "keep last spares manual points",
+			points: mixed,
+			policy: RetentionPolicy{KeepLast: 1},
+			want:   []string{"auto-1", "manual-1", "manual-2"},
+		},
+		{
+			name:   "max age spares manual points",
+			points: mixed,
+			policy: RetentionPolicy{MaxAge: 2*day + time.Hour},
+			want:   []string{"auto-1", "auto-2", "manual-1", "manual-2"},
+		},
+		{
+			name:   "keep last counts each kind",
+			points: mixed,
+			policy: RetentionPolicy{KeepLast: 1, IncludeManual: true},
+			want:   []string{"auto-1", "manual-1"},
+		},
+		{
+			name:   "max age with manual points",
+			points: mixed,
+			policy: RetentionPolicy{MaxAge: 90 * day, IncludeManual: true},
+			want:   []string{"auto-1", "auto-2", "auto-3", "manual-1"},
+		},
+		{
+			name:   "max size drops auto points after manual ones count",
+			points: mixed,
+			// The manual points alone take 26 bytes, the newest auto point stays anyway
+			policy: RetentionPolicy{MaxSize: 30},
+			want:   []string{"auto-1", "manual-1", "manual-2"},
+		},
+		{
+			name:   "max size keeps what fits",
+			points: mixed,
+			policy: RetentionPolicy{MaxSize: 26 + 8 + 8},
+			want:   []string{"auto-1", "auto-2", "manual-1", "manual-2"},
+		},
+		{
+			name:   "zero policy keeps everything",
+			points: mixed,
+			want:   []string{"auto-1", "auto-2", "auto-3", "manual-1", "manual-2"},
+		},
+		{
+			name: "default retention never removes manual points",
+			points: func() []testPoint {
+				var points []testPoint
+				for i := 0; i < 25; i++ {
+					points = append(points, testPoint{fmt.Sprintf("auto-%02d", i), RestorePointAuto, time.Duration(i) * time.Hour, map[string]string{"a": fmt.Sprint(i)}})
+				}
+				return append(points,
+					testPoint{"manual-old", RestorePointManual, 365 * day, map[string]string{"a": "old"}},
+					testPoint{"manual-new", RestorePointManual, 30 * day, map[string]string{"a": "new"}})
+			}(),
+			policy: DefaultRetention,
+			want: func() []string {
+				var ids []string
+				for i := 0; i < 20; i++ {
+					ids = append(ids, fmt.Sprintf("auto-%02d", i))
+				}
+				return append(ids, "manual-new", "manual-old")
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t, tt.points)
+			if _, err := store.Prune(tt.policy); err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			got := pointIDs(t, store)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("kept %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneRemovesUnusedBlobs(t *testing.T) {
+	store := newTestStore(t, []testPoint{
+		{"new", RestorePointAuto, time.Hour, map[string]string{"a": "kept", "b": "shared"}},
+		{"old", RestorePointAuto, 2 * time.Hour, map[string]string{"a": "dropped", "b": "shared"}},
+	})
+
+	result, err := store.Prune(RetentionPolicy{KeepLast: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Points) != 1 || result.Points[0] != "old" {
+		t.Errorf("pruned %v, want [old]", result.Points)
+	}
+	if result.Blobs != 1 || result.Freed != int64(len("dropped")) {
+		t.Errorf("removed %d blobs freeing %d bytes, want 1 and %d", result.Blobs, result.Freed, len("dropped"))
+	}
+
+	_, blobs, err := store.Usage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blobs != 2 {
+		t.Errorf("%d blobs left, want 2", blobs)
+	}
+}
@@ -0,0 +1,82 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"HyLauncher/internal/bootstrap"
+	"HyLauncher/internal/config"
+)
+
+// ManifestSignatureSuffix is appended to a manifest path for its detached
+// Ed25519 signature
+const ManifestSignatureSuffix = ".sig"
+
+// ErrUntrustedManifest means a manifest is neither signed nor explicitly
+// allowed, so it can't be used as the truth for an install
+var ErrUntrustedManifest = errors.New("untrusted manifest")
+
+// manifestPublicKey parses the verification manifest signing key
+func manifestPublicKey() (ed25519.PublicKey, error) {
+	key, err := bootstrap.ParsePublicKey(config.GetVerifyManifestPublicKeyHex())
+	if err != nil {
+		return nil, fmt.Errorf("verification manifest %w", err)
+	}
+	return key, nil
+}
+
+// VerifyManifestSignature checks the detached signature of a raw manifest.
+// It fails when no signing key is configured.
+func VerifyManifestSignature(raw, sig []byte) error {
+	key, err := manifestPublicKey()
+	if err != nil {
+		return err
+	}
+	return bootstrap.VerifyMetadataSignature(key, raw, sig)
+}
+
+// LoadTrustedManifest loads a manifest and checks it may be trusted: it needs
+// a valid signature next to it, made with the configured key. allowLocal
+// accepts unsigned and locally generated manifests too. signed reports
+// whether the signature was verified.
+func LoadTrustedManifest(path string, allowLocal bool) (manifest *Manifest, signed bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, &VerificationError{
+			Op:      "load manifest",
+			Path:    path,
+			Err:     err,
+			Message: fmt.Sprintf("failed to load manifest from %s: %v", path, err),
+		}
+	}
+
+	manifest = &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, false, &VerificationError{
+			Op:      "parse manifest",
+			Path:    path,
+			Err:     err,
+			Message: fmt.Sprintf("failed to parse manifest JSON: %v", err),
+		}
+	}
+
+	sig, sigErr := os.ReadFile(path + ManifestSignatureSuffix)
+	if sigErr == nil {
+		sigErr = VerifyManifestSignature(data, sig)
+	}
+	if sigErr == nil {
+		return manifest, true, nil
+	}
+	if allowLocal {
+		return manifest, false, nil
+	}
+	return nil, false, &VerificationError{
+		Op:      "verify manifest signature",
+		Path:    path,
+		Err:     ErrUntrustedManifest,
+		Message: fmt.Sprintf("manifest %s is not signed by the launcher key: %v", path, sigErr),
+	}
+}
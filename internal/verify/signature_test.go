@@ -0,0 +1,67 @@
+package verify
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"HyLauncher/internal/config"
+)
+
+func TestLoadTrustedManifest(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := []byte(`{"version":"8","files":{"Client/HytaleClient":{"size":3,"sha256":"00"}}}`)
+
+	tests := []struct {
+		name       string
+		key        string
+		sig        []byte
+		allowLocal bool
+		wantSigned bool
+		wantErr    bool
+	}{
+		{"signed", hex.EncodeToString(pub), ed25519.Sign(priv, raw), false, true, false},
+		{"bad signature", hex.EncodeToString(pub), ed25519.Sign(priv, []byte("other")), false, false, true},
+		{"unsigned", hex.EncodeToString(pub), nil, false, false, true},
+		{"unsigned, local allowed", hex.EncodeToString(pub), nil, true, false, false},
+		{"no key", "", ed25519.Sign(priv, raw), false, false, true},
+		{"no key, local allowed", "", nil, true, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldVerify, oldManifest := config.VerifyManifestPublicKeyHex, config.PatchManifestPublicKeyHex
+			config.VerifyManifestPublicKeyHex, config.PatchManifestPublicKeyHex = tt.key, ""
+			t.Cleanup(func() { config.VerifyManifestPublicKeyHex, config.PatchManifestPublicKeyHex = oldVerify, oldManifest })
+
+			path := filepath.Join(t.TempDir(), "build_8.json")
+			if err := os.WriteFile(path, raw, 0644); err != nil {
+				t.Fatal(err)
+			}
+			if tt.sig != nil {
+				if err := os.WriteFile(path+ManifestSignatureSuffix, tt.sig, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			manifest, signed, err := LoadTrustedManifest(path, tt.allowLocal)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrUntrustedManifest) {
+					t.Errorf("error %v is not ErrUntrustedManifest", err)
+				}
+				return
+			}
+			if signed != tt.wantSigned || manifest.Version != "8" {
+				t.Errorf("signed = %v, version = %q", signed, manifest.Version)
+			}
+		})
+	}
+}
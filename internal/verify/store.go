@@ -0,0 +1,215 @@
+package verify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	blobsDirName  = "blobs"
+	pointsDirName = "points"
+)
+
+// BackupStore keeps backed up file contents once per SHA-256 under blobs/,
+// and the restore points that reference them under points/. It is shared by
+// every install.
+type BackupStore struct {
+	dir string
+}
+
+// GetDefaultBackupDir returns the backup store in the launcher directory
+func GetDefaultBackupDir() string {
+	return filepath.Join(getLauncherDir(), "backups")
+}
+
+// OpenBackupStore returns the store at dir, the default store when dir is
+// empty. Directories are created on first write.
+func OpenBackupStore(dir string) *BackupStore {
+	if dir == "" {
+		dir = GetDefaultBackupDir()
+	}
+	return &BackupStore{dir: dir}
+}
+
+// Dir returns the store directory
+func (s *BackupStore) Dir() string {
+	return s.dir
+}
+
+func (s *BackupStore) blobPath(hash string) string {
+	return filepath.Join(s.dir, blobsDirName, hash[:2], hash)
+}
+
+// BlobPath returns the stored content with the given hash
+func (s *BackupStore) BlobPath(hash string) (string, bool) {
+	if !isSHA256(hash) {
+		return "", false
+	}
+	path := s.blobPath(hash)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+// PutFile stores the content of a file and returns its hash and size. When
+// hash is given and already stored the file is not read at all.
+func (s *BackupStore) PutFile(path, hash string) (string, int64, error) {
+	if blob, ok := s.BlobPath(hash); ok {
+		if stat, err := os.Stat(blob); err == nil {
+			return hash, stat.Size(), nil
+		}
+	}
+
+	src, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer src.Close()
+
+	blobsDir := filepath.Join(s.dir, blobsDirName)
+	if err := os.MkdirAll(blobsDir, 0755); err != nil {
+		return "", 0, fmt.Errorf("create blob directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(blobsDir, ".incoming-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("create blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	// Hash what is copied, the file may have changed since it was hashed
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hasher), src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("copy %s: %w", filepath.Base(path), err)
+	}
+	sum := hex.EncodeToString(hasher.Sum(nil))
+
+	dest := s.blobPath(sum)
+	if _, err := os.Stat(dest); err == nil {
+		return sum, size, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return "", 0, fmt.Errorf("create blob directory: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), dest); err != nil {
+		return "", 0, fmt.Errorf("store blob: %w", err)
+	}
+	return sum, size, nil
+}
+
+// SaveRestorePoint writes a restore point, replacing an older version of it
+func (s *BackupStore) SaveRestorePoint(point *RestorePoint) error {
+	dir := filepath.Join(s.dir, pointsDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create restore point directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(point, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode restore point: %w", err)
+	}
+
+	path := filepath.Join(dir, point.ID+".json")
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("write restore point: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("store restore point: %w", err)
+	}
+	return nil
+}
+
+// ListRestorePoints returns every restore point in the store, newest first.
+// Points that can't be read are skipped.
+func (s *BackupStore) ListRestorePoints() ([]*RestorePoint, error) {
+	dir := filepath.Join(s.dir, pointsDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read restore points: %w", err)
+	}
+
+	var points []*RestorePoint
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var point RestorePoint
+		if err := json.Unmarshal(data, &point); err != nil || point.ID == "" {
+			continue
+		}
+		points = append(points, &point)
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].CreatedAt.After(points[j].CreatedAt)
+	})
+	return points, nil
+}
+
+// FindRestorePoint returns the restore point with the given ID, or the newest
+// one with the given name
+func (s *BackupStore) FindRestorePoint(ref string) (*RestorePoint, error) {
+	points, err := s.ListRestorePoints()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range points {
+		if p.ID == ref {
+			return p, nil
+		}
+	}
+	for _, p := range points {
+		if p.Name != "" && p.Name == ref {
+			return p, nil
+		}
+	}
+	return nil, &VerificationError{
+		Op:      "find restore point",
+		Message: fmt.Sprintf("no restore point %q", ref),
+	}
+}
+
+// DeleteRestorePoint removes a restore point. Its contents stay until the
+// next Prune, other points may still use them.
+func (s *BackupStore) DeleteRestorePoint(id string) error {
+	err := os.Remove(filepath.Join(s.dir, pointsDirName, id+".json"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete restore point: %w", err)
+	}
+	return nil
+}
+
+// isSHA256 checks that s is a hex SHA-256, so it is safe to use as a path
+func isSHA256(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// newPointID returns a restore point ID that sorts by creation time
+func newPointID(t time.Time) string {
+	return fmt.Sprintf("%s_%03d", t.Format("20060102_150405"), t.Nanosecond()/int(time.Millisecond))
+}
@@ -27,6 +27,11 @@ type FileStatus struct {
 	Match        bool               `json:"match"`
 	Status       VerificationStatus `json:"status"`
 	Message      string             `json:"message"`
+	Role         FileRole           `json:"role,omitempty"`
+	// Extra is set for files on disk that the manifest does not list
+	Extra bool `json:"extra,omitempty"`
+	// Cached is set when the hash came from the verification cache
+	Cached bool `json:"cached,omitempty"`
 }
 
 // IsCritical returns true if the file verification failed critically
@@ -48,6 +53,11 @@ type Report struct {
 	OverallStatus VerificationStatus `json:"overall_status"`
 	Summary       Summary            `json:"summary"`
 	LogFile       string             `json:"log_file,omitempty"`
+	// Manifest is the manifest the files were checked against
+	Manifest       string `json:"manifest,omitempty"`
+	ManifestSigned bool   `json:"manifest_signed"`
+	// HistoryFile is where the report was stored in the history
+	HistoryFile string `json:"history_file,omitempty"`
 }
 
 // Summary provides a quick overview of verification results
@@ -59,6 +69,8 @@ type Summary struct {
 	Skipped       int `json:"skipped"`
 	MissingFiles  int `json:"missing_files"`
 	ModifiedFiles int `json:"modified_files"`
+	ExtraFiles    int `json:"extra_files"`
+	CachedFiles   int `json:"cached_files"`
 }
 
 // Options configures the verification behavior
@@ -73,14 +85,35 @@ type Options struct {
 	SkipVerify bool
 	// CreateBackups creates backups of modified files before any action
 	CreateBackups bool
-	// BackupDir is the directory to store backups (default: gameDir/.backups)
+	// BackupDir is the backup store (default: launcher_dir/backups)
 	BackupDir string
+	// Retention limits the backup store after files were backed up (default: DefaultRetention)
+	Retention RetentionPolicy
 	// IgnoreList contains file patterns to ignore during verification
 	IgnoreList []string
+	// NoBackup lists files that are modified on purpose, such as the patched
+	// client, so a mismatch is reported but not backed up
+	NoBackup []string
 	// ProgressCallback is called periodically during hash calculation
 	ProgressCallback func(current, total int64, fileName string)
 	// ProgressInterval is the minimum bytes between progress updates (default: 100MB)
 	ProgressInterval int64
+	// Workers is the number of files hashed at once (default: CPU count)
+	Workers int
+	// DeepVerify hashes every file even if the cache says it is unchanged
+	DeepVerify bool
+	// CachePath is the hash cache file (default: launcher_dir/cache/verify_<dir hash>.json)
+	CachePath string
+	// NoCache disables reading and writing the hash cache
+	NoCache bool
+	// AllowLocalManifest trusts unsigned and locally generated manifests
+	AllowLocalManifest bool
+	// HistoryDir is where reports are kept (default: gameDir/.verify-history)
+	HistoryDir string
+	// HistoryLimit is how many reports are kept (default: DefaultHistoryLimit)
+	HistoryLimit int
+	// NoHistory disables storing the report in the history
+	NoHistory bool
 }
 
 // DefaultOptions returns options with sensible defaults
@@ -2,9 +2,12 @@ package verify
 
 import (
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -18,6 +21,15 @@ func NewVerifier(options Options) *Verifier {
 	if options.ProgressInterval == 0 {
 		options.ProgressInterval = 100 * 1024 * 1024 // 100 MB
 	}
+	if callback := options.ProgressCallback; callback != nil {
+		// Files are hashed in parallel, keep the callback to one at a time
+		var mu sync.Mutex
+		options.ProgressCallback = func(current, total int64, fileName string) {
+			mu.Lock()
+			defer mu.Unlock()
+			callback(current, total, fileName)
+		}
+	}
 	return &Verifier{
 		options: options,
 	}
@@ -38,8 +50,23 @@ func VerifyWithOptions(options Options) (*Report, error) {
 		return createSkippedReport(options), nil
 	}
 
+	if envEnabled("HYTALE_DEEP_VERIFY") {
+		options.DeepVerify = true
+	}
+
 	verifier := NewVerifier(options)
-	return verifier.runVerification()
+	report, err := verifier.runVerification()
+	if err != nil {
+		return nil, err
+	}
+
+	if !options.NoHistory {
+		// A report that can't be stored is still returned
+		if path, err := SaveReport(verifier.historyDir(), report, options.HistoryLimit); err == nil {
+			report.HistoryFile = path
+		}
+	}
+	return report, nil
 }
 
 // shouldSkipVerification checks if verification should be skipped
@@ -48,10 +75,13 @@ func shouldSkipVerification(options Options) bool {
 		return true
 	}
 	// Check environment variable
-	if os.Getenv("HYTALE_SKIP_VERIFY") == "1" || strings.ToLower(os.Getenv("HYTALE_SKIP_VERIFY")) == "true" {
-		return true
-	}
-	return false
+	return envEnabled("HYTALE_SKIP_VERIFY")
+}
+
+// envEnabled reports whether an environment variable is set to 1 or true
+func envEnabled(name string) bool {
+	value := os.Getenv(name)
+	return value == "1" || strings.ToLower(value) == "true"
 }
 
 // createSkippedReport creates a report indicating verification was skipped
@@ -80,24 +110,34 @@ func (v *Verifier) runVerification() (*Report, error) {
 
 	// Starting file verification
 
-	// Load or generate manifest
-	manifest, err := v.loadManifest()
+	// Load a trusted manifest
+	manifest, manifestPath, signed, err := v.loadManifest()
 	if err != nil {
 		return nil, err
 	}
+	report.Manifest = manifestPath
+	report.ManifestSigned = signed
 
 	// Setup backup manager
-	backupDir := v.options.BackupDir
-	if backupDir == "" {
-		backupDir = filepath.Join(v.options.GameDir, ".backups")
-	}
-	backupManager := NewBackupManager(backupDir, v.options.CreateBackups)
+	backupManager := NewBackupManager(v.options.BackupDir, v.options.GameDir, v.options.CreateBackups)
+	backupDir := backupManager.GetBackupPath()
 
 	// Create hash calculator with progress callback
 	hashCalculator := NewHashCalculator(v.options.ProgressCallback, v.options.ProgressInterval)
 
-	// Verify each file in the manifest
-	for relPath, expectedInfo := range manifest.Files {
+	// Unchanged files keep their hash from the last run
+	var cache *HashCache
+	if !v.options.NoCache {
+		cachePath := v.options.CachePath
+		if cachePath == "" {
+			cachePath = GetDefaultCachePath(v.options.GameDir)
+		}
+		cache = LoadHashCache(cachePath)
+	}
+
+	// Collect the files to check
+	var relPaths []string
+	for relPath := range manifest.Files {
 		// Check if file should be ignored
 		if manifest.IsIgnored(relPath) {
 			// Skipping ignored file
@@ -110,13 +150,42 @@ func (v *Verifier) runVerification() (*Report, error) {
 			continue
 		}
 
+		relPaths = append(relPaths, relPath)
+	}
+
+	// Verify the files on a worker pool
+	statuses := make([]FileStatus, len(relPaths))
+	forEachParallel(len(relPaths), hashWorkers(v.options.Workers), func(i int) {
+		relPath := relPaths[i]
 		fullPath := filepath.Join(v.options.GameDir, relPath)
-		status := v.verifyFile(fullPath, relPath, expectedInfo, hashCalculator, backupManager)
-		report.Files = append(report.Files, status)
+		statuses[i] = v.verifyFile(fullPath, relPath, manifest.Files[relPath], hashCalculator, backupManager, cache)
+	})
+	report.Files = append(report.Files, statuses...)
 
-		// Verified file logged
+	if cache != nil {
+		// A cache that can't be saved only costs a full hash next time
+		_ = cache.Save()
 	}
 
+	// Keep the backup store in bounds once this run added to it
+	if backupManager.BackedUp() {
+		retention := v.options.Retention
+		if retention.IsZero() {
+			retention = DefaultRetention
+		}
+		_, _ = backupManager.Store().Prune(retention)
+	}
+
+	// A complete manifest lists everything its globs select, anything else
+	// on disk was added after install
+	if manifest.Complete {
+		report.Files = append(report.Files, v.findExtraFiles(manifest, backupDir)...)
+	}
+
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].Path < report.Files[j].Path
+	})
+
 	// Calculate summary
 	report.Summary = v.calculateSummary(report.Files)
 	report.OverallStatus = v.determineOverallStatus(report.Files)
@@ -126,64 +195,97 @@ func (v *Verifier) runVerification() (*Report, error) {
 	return report, nil
 }
 
-// loadManifest loads the verification manifest
-func (v *Verifier) loadManifest() (*Manifest, error) {
-	// Try to load from specified path first
+// loadManifest loads the verification manifest for the version. Manifests
+// that are not signed, or were generated from a local install, are refused
+// unless AllowLocalManifest is set, damaged files must not become the truth.
+func (v *Verifier) loadManifest() (*Manifest, string, bool, error) {
+	paths := []string{GetDefaultManifestPath(v.options.GameDir, v.options.Version)}
 	if v.options.ManifestPath != "" {
-		manifest, err := LoadManifest(v.options.ManifestPath)
+		// Try the specified path first
+		paths = append([]string{v.options.ManifestPath}, paths...)
+	}
+
+	var untrusted error
+	for _, path := range paths {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		manifest, signed, err := LoadTrustedManifest(path, v.options.AllowLocalManifest)
 		if err == nil {
-			return manifest, nil
+			return manifest, path, signed, nil
 		}
-		// Failed to load specified manifest, trying default location
-	}
-
-	// Try default location
-	defaultPath := GetDefaultManifestPath(v.options.GameDir, v.options.Version)
-	manifest, err := LoadManifest(defaultPath)
-	if err == nil {
-		return manifest, nil
-	}
-
-	// Try to find any manifest for this version
-	launcherDir := getLauncherDir()
-	manifestDir := filepath.Join(launcherDir, "manifests")
-	if entries, err := os.ReadDir(manifestDir); err == nil {
-		for _, entry := range entries {
-			if !entry.IsDir() && strings.HasPrefix(entry.Name(), "manifest_") {
-				path := filepath.Join(manifestDir, entry.Name())
-				if m, err := LoadManifest(path); err == nil {
-					// Found manifest
-					return m, nil
-				}
-			}
+		if untrusted == nil {
+			untrusted = err
 		}
 	}
 
-	return nil, &VerificationError{
+	if untrusted != nil {
+		return nil, "", false, untrusted
+	}
+	return nil, "", false, &VerificationError{
 		Op:      "load manifest",
 		Message: fmt.Sprintf("no manifest found for version %s", v.options.Version),
 	}
 }
 
+// historyDir returns the report history directory of the game dir
+func (v *Verifier) historyDir() string {
+	if v.options.HistoryDir != "" {
+		return v.options.HistoryDir
+	}
+	return GetDefaultHistoryDir(v.options.GameDir)
+}
+
 // isIgnored checks if a file matches any pattern in the ignore list
 func (v *Verifier) isIgnored(relPath string) bool {
-	for _, pattern := range v.options.IgnoreList {
-		matched, _ := filepath.Match(pattern, relPath)
-		if matched {
-			return true
+	return matchAny(v.options.IgnoreList, relPath)
+}
+
+// findExtraFiles lists files on disk the manifest selects but does not know
+func (v *Verifier) findExtraFiles(manifest *Manifest, backupDir string) []FileStatus {
+	var extra []FileStatus
+	err := manifest.walk(v.options.GameDir, func(relPath, fullPath string, d fs.DirEntry) {
+		if _, ok := manifest.Files[relPath]; ok {
+			return
 		}
-		matched, _ = filepath.Match(pattern, filepath.ToSlash(relPath))
-		if matched {
-			return true
+		if manifest.IsIgnored(relPath) || v.isIgnored(relPath) {
+			return
 		}
+		if isWithin(backupDir, fullPath) || isWithin(v.historyDir(), fullPath) {
+			return
+		}
+
+		status := FileStatus{
+			Path:    relPath,
+			Exists:  true,
+			Extra:   true,
+			Status:  StatusWarning,
+			Message: "File not in manifest",
+		}
+		if info, err := d.Info(); err == nil {
+			status.Size = info.Size()
+			status.Role = classifyRole(relPath, info.Mode())
+		}
+		extra = append(extra, status)
+	})
+	if err != nil {
+		// Scanning for extra files failed
+		return nil
 	}
-	return false
+	return extra
+}
+
+// isWithin reports whether path is inside dir
+func isWithin(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && !strings.HasPrefix(rel, "..")
 }
 
 // verifyFile verifies a single file
-func (v *Verifier) verifyFile(fullPath, relPath string, expected FileInfo, calculator *HashCalculator, backupManager *BackupManager) FileStatus {
+func (v *Verifier) verifyFile(fullPath, relPath string, expected FileInfo, calculator *HashCalculator, backupManager *BackupManager, cache *HashCache) FileStatus {
 	status := FileStatus{
 		Path:         relPath,
+		Role:         expected.Role,
 		ExpectedSize: expected.Size,
 		ExpectedHash: expected.SHA256,
 	}
@@ -212,27 +314,44 @@ func (v *Verifier) verifyFile(fullPath, relPath string, expected FileInfo, calcu
 		return status
 	}
 
+	// Use the cached hash unless the file changed or a deep verify was asked for
+	var id uint64
+	if cache != nil {
+		id = fileID(fullPath, stat)
+		if !v.options.DeepVerify {
+			status.Hash, status.Cached = cache.Lookup(relPath, stat, id)
+		}
+	}
+
 	// Calculate hash
-	hash, _, err := calculator.CalculateHash(fullPath)
-	if err != nil {
-		status.Status = StatusFailed
-		status.Message = fmt.Sprintf("Failed to calculate hash: %v", err)
-		return status
+	if !status.Cached {
+		hash, _, err := calculator.CalculateHash(fullPath)
+		if err != nil {
+			status.Status = StatusFailed
+			status.Message = fmt.Sprintf("Failed to calculate hash: %v", err)
+			return status
+		}
+		status.Hash = hash
+		if cache != nil {
+			cache.Store(relPath, stat, id, hash)
+		}
 	}
 
-	status.Hash = hash
-	status.Match = hash == expected.SHA256
+	status.Match = status.Hash == expected.SHA256
 
 	if status.Match {
 		status.Status = StatusOK
 		status.Message = "File verified successfully"
+		if status.Cached {
+			status.Message = "File unchanged since last verification"
+		}
 	} else {
 		status.Status = StatusWarning
 		status.Message = "File modified or corrupted"
 
-		// Create backup of modified file
-		if v.options.CreateBackups {
-			backupPath, err := backupManager.CreateBackup(fullPath)
+		// Create backup of modified file, unless it is modified on purpose
+		if v.options.CreateBackups && !matchAny(v.options.NoBackup, relPath) {
+			backupPath, err := backupManager.BackupFile(fullPath, status.Hash)
 			if err != nil {
 				// Failed to create backup
 			} else if backupPath != "" {
@@ -251,6 +370,9 @@ func (v *Verifier) calculateSummary(files []FileStatus) Summary {
 	}
 
 	for _, f := range files {
+		if f.Cached {
+			summary.CachedFiles++
+		}
 		switch f.Status {
 		case StatusOK:
 			summary.Passed++
@@ -261,7 +383,9 @@ func (v *Verifier) calculateSummary(files []FileStatus) Summary {
 			}
 		case StatusWarning:
 			summary.Warnings++
-			if f.Exists && !f.Match {
+			if f.Extra {
+				summary.ExtraFiles++
+			} else if f.Exists && !f.Match {
 				summary.ModifiedFiles++
 			}
 		case StatusSkipped:
@@ -297,11 +421,7 @@ func (v *Verifier) determineOverallStatus(files []FileStatus) VerificationStatus
 
 // RestoreFile restores a file from backup
 func RestoreFile(filePath string, backupDir string) (string, error) {
-	if backupDir == "" {
-		backupDir = filepath.Join(filepath.Dir(filePath), ".backups")
-	}
-
-	backupManager := NewBackupManager(backupDir, true)
+	backupManager := NewBackupManager(backupDir, "", true)
 	backupPath, err := backupManager.RestoreFile(filePath)
 	if err != nil {
 		return "", err
@@ -45,6 +45,7 @@ func DownloadWithReporter(
 	}
 
 	var lastErr error
+	resumed := false
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
 		logger.Debug("Download attempt", "attempt", attempt, "max", maxRetries, "file", fileName)
@@ -55,7 +56,7 @@ func DownloadWithReporter(
 		default:
 		}
 
-		if attempt > 1 {
+		if attempt > 1 && !resumed {
 			delay := baseRetryDelay * time.Duration(1<<(attempt-2))
 			if delay > 60*time.Second {
 				delay = 60 * time.Second
@@ -78,12 +79,21 @@ func DownloadWithReporter(
 			}
 		}
 
+		resumed = false
 		err := attemptDownload(ctx, dest, url, fileName, reporter, stage, scaler)
 		if err == nil {
 			logger.Info("Download completed", "file", fileName, "dest", dest)
 			return nil
 		}
 
+		// Waiting for the schedule window is not a failure, reconnect and resume
+		if errors.Is(err, ErrSchedulePaused) {
+			logger.Info("Download window open, resuming", "file", fileName)
+			resumed = true
+			attempt--
+			continue
+		}
+
 		lastErr = err
 		logger.Warn("Download attempt failed", "attempt", attempt, "file", fileName, "error", err)
 
@@ -229,12 +239,14 @@ func attemptDownload(
 	}
 	defer out.Close()
 
-	// Wrap body with timeout reader
-	bodyReader := &timeoutReader{
+	// Wrap body with timeout reader, then the shared rate limit and schedule
+	bodyReader := newThrottledReader(ctx, &timeoutReader{
 		r:          resp.Body,
 		timeout:    readTimeout,
 		lastReadAt: time.Now(),
-	}
+	}, total >= LargeDownloadSize, func(until time.Time) {
+		reportWarning(reporter, scaler, stage, pausedMessage(until))
+	})
 
 	buf := make([]byte, 64*1024)
 	downloaded := resumeFrom
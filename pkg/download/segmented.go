@@ -0,0 +1,648 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"HyLauncher/internal/progress"
+	"HyLauncher/pkg/logger"
+)
+
+const (
+	segmentSize         = 32 * 1024 * 1024 // Bytes per range request
+	segmentWorkers      = 4                // Parallel range requests
+	segmentMinFileSize  = 64 * 1024 * 1024 // Smaller files use a single stream
+	mirrorMaxFailures   = 3                // Failures before a mirror is dropped
+	segmentStateVersion = 1
+)
+
+// segmentState is persisted next to the .part file so an interrupted
+// segmented download can pick up the segments it already has
+type segmentState struct {
+	Version     int    `json:"version"`
+	Size        int64  `json:"size"`
+	SegmentSize int64  `json:"segment_size"`
+	Done        []bool `json:"done"`
+}
+
+type segment struct {
+	index int
+	start int64
+	end   int64 // inclusive
+}
+
+// mirrorSet hands out mirrors round-robin and drops the ones that keep failing
+type mirrorSet struct {
+	mu       sync.Mutex
+	urls     []string
+	failures []int
+	next     int
+}
+
+func newMirrorSet(urls []string) *mirrorSet {
+	return &mirrorSet{
+		urls:     urls,
+		failures: make([]int, len(urls)),
+	}
+}
+
+// pick returns the next usable mirror, skipping avoid if another is available
+func (m *mirrorSet) pick(avoid int) (int, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fallback := -1
+	for i := 0; i < len(m.urls); i++ {
+		idx := (m.next + i) % len(m.urls)
+		if m.failures[idx] >= mirrorMaxFailures {
+			continue
+		}
+		if idx == avoid {
+			fallback = idx
+			continue
+		}
+		m.next = (idx + 1) % len(m.urls)
+		return idx, true
+	}
+
+	if fallback >= 0 {
+		return fallback, true
+	}
+	return -1, false
+}
+
+func (m *mirrorSet) fail(idx int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failures[idx]++
+}
+
+// DownloadFromMirrors downloads a file from one or more mirrors serving identical content.
+// Large files on range-capable mirrors are fetched as parallel byte-range segments,
+// with failed segments retried on another mirror. Everything else falls back to
+// DownloadWithReporter, trying each mirror in turn.
+func DownloadFromMirrors(
+	ctx context.Context,
+	dest string,
+	mirrors []string,
+	fileName string,
+	reporter *progress.Reporter,
+	stage progress.Stage,
+	scaler *progress.Scaler,
+) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	mirrors = uniqueMirrors(mirrors)
+	if len(mirrors) == 0 {
+		return fmt.Errorf("no download mirrors for %s", fileName)
+	}
+
+	client := createSafeClient()
+
+	size, err := probeRangeSupport(ctx, client, mirrors)
+	if err != nil || size < segmentMinFileSize {
+		if err != nil {
+			logger.Info("Segmented download unavailable, using single stream", "file", fileName, "reason", err)
+		}
+		return downloadSequential(ctx, dest, mirrors, fileName, reporter, stage, scaler)
+	}
+
+	logger.Info("Starting segmented download", "file", fileName, "size", size, "mirrors", len(mirrors))
+	if err := downloadSegmented(ctx, client, dest, mirrors, size, fileName, reporter, stage, scaler); err != nil {
+		logger.Error("Segmented download failed", "file", fileName, "error", err)
+		return err
+	}
+
+	logger.Info("Download completed", "file", fileName, "dest", dest)
+	return nil
+}
+
+func downloadSequential(
+	ctx context.Context,
+	dest string,
+	mirrors []string,
+	fileName string,
+	reporter *progress.Reporter,
+	stage progress.Stage,
+	scaler *progress.Scaler,
+) error {
+	var lastErr error
+	for _, url := range mirrors {
+		err := DownloadWithReporter(ctx, dest, url, fileName, reporter, stage, scaler)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		lastErr = err
+		logger.Warn("Mirror failed, trying next", "url", url, "error", err)
+	}
+	return lastErr
+}
+
+// probeRangeSupport asks each mirror for the first byte and returns the total size
+// reported by the first one that honours range requests
+func probeRangeSupport(ctx context.Context, client *http.Client, mirrors []string) (int64, error) {
+	var lastErr error
+
+	for _, url := range mirrors {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("Range", "bytes=0-0")
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+
+		if resp.StatusCode != http.StatusPartialContent {
+			lastErr = fmt.Errorf("mirror %s does not support ranges: %s", url, resp.Status)
+			continue
+		}
+
+		_, _, total, err := parseContentRange(resp.Header.Get("Content-Range"))
+		if err != nil || total <= 0 {
+			lastErr = fmt.Errorf("mirror %s returned unusable Content-Range %q", url, resp.Header.Get("Content-Range"))
+			continue
+		}
+
+		return total, nil
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no mirror reachable")
+	}
+	return 0, lastErr
+}
+
+func downloadSegmented(
+	ctx context.Context,
+	client *http.Client,
+	dest string,
+	mirrors []string,
+	size int64,
+	fileName string,
+	reporter *progress.Reporter,
+	stage progress.Stage,
+	scaler *progress.Scaler,
+) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	partPath := dest + ".part"
+	statePath := partPath + ".segments"
+
+	state := loadSegmentState(statePath, partPath, size)
+
+	if err := checkDiskSpace(dest, size-state.completedBytes()); err != nil {
+		reportWarning(reporter, scaler, stage, fmt.Sprintf("Disk space warning: %v", err))
+	}
+
+	out, err := os.OpenFile(partPath, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := out.Truncate(size); err != nil {
+		return fmt.Errorf("preallocate %s: %w", partPath, err)
+	}
+
+	var pending []segment
+	for i := range state.Done {
+		if state.Done[i] {
+			continue
+		}
+		start := int64(i) * state.SegmentSize
+		end := start + state.SegmentSize - 1
+		if end >= size {
+			end = size - 1
+		}
+		pending = append(pending, segment{index: i, start: start, end: end})
+	}
+
+	if resumed := len(state.Done) - len(pending); resumed > 0 {
+		logger.Info("Resuming segmented download", "file", fileName, "segments", resumed, "of", len(state.Done))
+	}
+
+	if err := waitForWindow(ctx, size, func(until time.Time) {
+		reportWarning(reporter, scaler, stage, pausedMessage(until))
+	}); err != nil {
+		return fmt.Errorf("download canceled: %w", err)
+	}
+
+	segCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var downloaded atomic.Int64
+	downloaded.Store(state.completedBytes())
+
+	progressDone := make(chan struct{})
+	go func() {
+		defer close(progressDone)
+		reportSegmentedProgress(segCtx, &downloaded, size, fileName, reporter, stage, scaler)
+	}()
+
+	mirrorsSet := newMirrorSet(mirrors)
+	queue := make(chan segment)
+	var (
+		stateMu  sync.Mutex
+		firstErr error
+		errOnce  sync.Once
+		wg       sync.WaitGroup
+	)
+
+	workers := segmentWorkers
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seg := range queue {
+				if err := fetchSegmentWithFailover(segCtx, client, out, mirrorsSet, seg, &downloaded); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+
+				stateMu.Lock()
+				state.Done[seg.index] = true
+				if err := saveSegmentState(statePath, state); err != nil {
+					logger.Warn("Failed to save segment state", "path", statePath, "error", err)
+				}
+				stateMu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, seg := range pending {
+		select {
+		case queue <- seg:
+		case <-segCtx.Done():
+			break feed
+		}
+	}
+	close(queue)
+	wg.Wait()
+	cancel()
+	<-progressDone
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("download canceled: %w", err)
+	}
+	if firstErr != nil {
+		return firstErr
+	}
+
+	for _, done := range state.Done {
+		if !done {
+			return fmt.Errorf("download canceled: incomplete segments")
+		}
+	}
+
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	if err := renameWithRetry(partPath, dest); err != nil {
+		return err
+	}
+	_ = os.Remove(statePath)
+
+	if scaler != nil {
+		scaler.ReportDownload(stage, 100, "Download complete", fileName, "", size, size)
+	} else if reporter != nil {
+		reporter.ReportDownload(stage, 100, "Download complete", fileName, "", size, size)
+	}
+
+	return nil
+}
+
+// fetchSegmentWithFailover downloads one segment, moving to another mirror on each failure
+func fetchSegmentWithFailover(
+	ctx context.Context,
+	client *http.Client,
+	out *os.File,
+	mirrors *mirrorSet,
+	seg segment,
+	downloaded *atomic.Int64,
+) error {
+	var lastErr error
+	lastMirror := -1
+
+	for attempt := 1; attempt <= maxRetries; attempt++ {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("download canceled: %w", err)
+		}
+
+		idx, ok := mirrors.pick(lastMirror)
+		if !ok {
+			break
+		}
+		lastMirror = idx
+
+		written, err := fetchSegment(ctx, client, out, mirrors.urls[idx], seg, downloaded)
+		if err == nil {
+			return nil
+		}
+
+		// Undo the progress of the partial attempt, it will be rewritten
+		downloaded.Add(-written)
+
+		if ctx.Err() != nil {
+			return fmt.Errorf("download canceled: %w", ctx.Err())
+		}
+
+		// Paused by the schedule, not the mirror's fault
+		if errors.Is(err, ErrSchedulePaused) {
+			lastMirror = -1
+			attempt--
+			continue
+		}
+
+		lastErr = err
+		mirrors.fail(idx)
+		logger.Warn("Segment failed, retrying on another mirror",
+			"segment", seg.index, "mirror", mirrors.urls[idx], "attempt", attempt, "error", err)
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("all mirrors failed")
+	}
+	return fmt.Errorf("segment %d (%d-%d): %w", seg.index, seg.start, seg.end, lastErr)
+}
+
+func fetchSegment(
+	ctx context.Context,
+	client *http.Client,
+	out *os.File,
+	url string,
+	seg segment,
+	downloaded *atomic.Int64,
+) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", seg.start, seg.end))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("bad HTTP status for range request: %s", resp.Status)
+	}
+
+	start, end, _, err := parseContentRange(resp.Header.Get("Content-Range"))
+	if err != nil {
+		return 0, err
+	}
+	if start != seg.start || end != seg.end {
+		return 0, fmt.Errorf("server returned wrong range (expected %d-%d, got %d-%d)", seg.start, seg.end, start, end)
+	}
+
+	// Segmented downloads are always large enough to follow the schedule
+	bodyReader := newThrottledReader(ctx, &timeoutReader{
+		r:          resp.Body,
+		timeout:    readTimeout,
+		lastReadAt: time.Now(),
+	}, true, nil)
+
+	buf := make([]byte, 64*1024)
+	offset := seg.start
+	expected := seg.end - seg.start + 1
+	var written int64
+
+	for written < expected {
+		n, rerr := bodyReader.Read(buf)
+		if n > 0 {
+			if int64(n) > expected-written {
+				n = int(expected - written)
+			}
+			if _, werr := out.WriteAt(buf[:n], offset); werr != nil {
+				return written, werr
+			}
+			offset += int64(n)
+			written += int64(n)
+			downloaded.Add(int64(n))
+		}
+
+		if rerr != nil {
+			if rerr == io.EOF {
+				break
+			}
+			return written, fmt.Errorf("read error: %w", rerr)
+		}
+	}
+
+	if written != expected {
+		return written, fmt.Errorf("incomplete segment: got %d bytes, expected %d", written, expected)
+	}
+
+	return written, nil
+}
+
+func reportSegmentedProgress(
+	ctx context.Context,
+	downloaded *atomic.Int64,
+	total int64,
+	fileName string,
+	reporter *progress.Reporter,
+	stage progress.Stage,
+	scaler *progress.Scaler,
+) {
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+
+	lastUpdate := time.Now()
+	lastBytes := downloaded.Load()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			current := downloaded.Load()
+			speed := float64(current-lastBytes) / now.Sub(lastUpdate).Seconds()
+			if speed < 0 {
+				speed = 0
+			}
+			progressPct := float64(current) / float64(total) * 100
+
+			message := "Downloading..."
+			if until := defaultThrottle.pausedUntil(); !until.IsZero() {
+				message = pausedMessage(until)
+			}
+
+			if scaler != nil {
+				scaler.ReportDownload(stage, progressPct, message, fileName, formatSpeed(speed), current, total)
+			} else if reporter != nil {
+				reporter.ReportDownload(stage, progressPct, message, fileName, formatSpeed(speed), current, total)
+			}
+
+			lastUpdate = now
+			lastBytes = current
+		}
+	}
+}
+
+// loadSegmentState restores a previous segment journal if it matches the
+// current file. A .part without a journal is left by a single-stream download
+// and keeps the segments it already covers; anything else starts from scratch.
+func loadSegmentState(statePath, partPath string, size int64) *segmentState {
+	count := int((size + segmentSize - 1) / segmentSize)
+	state := &segmentState{
+		Version:     segmentStateVersion,
+		Size:        size,
+		SegmentSize: segmentSize,
+		Done:        make([]bool, count),
+	}
+
+	data, err := os.ReadFile(statePath)
+	if err == nil {
+		var saved segmentState
+		if json.Unmarshal(data, &saved) == nil &&
+			saved.Version == segmentStateVersion &&
+			saved.Size == size &&
+			saved.SegmentSize == segmentSize &&
+			len(saved.Done) == count {
+			if st, err := os.Stat(partPath); err == nil && st.Size() == size {
+				return &saved
+			}
+		}
+		logger.Info("Discarding stale segment state", "path", statePath)
+		_ = os.Remove(partPath)
+		return state
+	}
+
+	// A single stream writes from the start, so its first bytes are good. A
+	// segmented .part is preallocated to the full size and can't be trusted
+	// without its journal.
+	st, err := os.Stat(partPath)
+	if err != nil {
+		return state
+	}
+	if st.Size() >= size {
+		_ = os.Remove(partPath)
+		return state
+	}
+
+	seeded := int(st.Size() / segmentSize)
+	for i := 0; i < seeded; i++ {
+		state.Done[i] = true
+	}
+	if seeded > 0 {
+		logger.Info("Resuming single-stream download in segments", "path", partPath, "bytes", st.Size(), "segments", seeded)
+		if err := saveSegmentState(statePath, state); err != nil {
+			logger.Warn("Failed to save segment state", "path", statePath, "error", err)
+		}
+	}
+	return state
+}
+
+func saveSegmentState(path string, state *segmentState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *segmentState) completedBytes() int64 {
+	var total int64
+	for i, done := range s.Done {
+		if !done {
+			continue
+		}
+		start := int64(i) * s.SegmentSize
+		end := start + s.SegmentSize
+		if end > s.Size {
+			end = s.Size
+		}
+		total += end - start
+	}
+	return total
+}
+
+// parseContentRange parses "bytes START-END/TOTAL"; TOTAL is -1 when reported as "*"
+func parseContentRange(header string) (start, end, total int64, err error) {
+	if !strings.HasPrefix(header, "bytes ") {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+
+	rangePart, totalPart, ok := strings.Cut(header[6:], "/")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+
+	startStr, endStr, ok := strings.Cut(rangePart, "-")
+	if !ok {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range %q", header)
+	}
+
+	if start, err = strconv.ParseInt(startStr, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range start: %w", err)
+	}
+	if end, err = strconv.ParseInt(endStr, 10, 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid Content-Range end: %w", err)
+	}
+
+	total = -1
+	if totalPart != "*" {
+		if total, err = strconv.ParseInt(totalPart, 10, 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid Content-Range total: %w", err)
+		}
+	}
+
+	return start, end, total, nil
+}
+
+func uniqueMirrors(mirrors []string) []string {
+	seen := make(map[string]bool, len(mirrors))
+	result := make([]string, 0, len(mirrors))
+	for _, m := range mirrors {
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		result = append(result, m)
+	}
+	return result
+}
@@ -0,0 +1,96 @@
+package download
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadSegmentState(t *testing.T) {
+	const size = 4*segmentSize + 100
+
+	journal := func(done ...bool) *segmentState {
+		return &segmentState{Version: segmentStateVersion, Size: size, SegmentSize: segmentSize, Done: done}
+	}
+
+	tests := []struct {
+		name     string
+		partSize int64 // -1: no .part
+		saved    *segmentState
+		wantDone []bool
+		wantPart bool
+	}{
+		{"fresh", -1, nil, []bool{false, false, false, false, false}, false},
+		{"single stream part", 2*segmentSize + 10, nil, []bool{true, true, false, false, false}, true},
+		{"short single stream part", segmentSize - 1, nil, []bool{false, false, false, false, false}, true},
+		{"preallocated part without journal", size, nil, []bool{false, false, false, false, false}, false},
+		{"journal", size, journal(true, false, true, false, false), []bool{true, false, true, false, false}, true},
+		{"journal for another size", size, &segmentState{Version: segmentStateVersion, Size: size + 1, SegmentSize: segmentSize, Done: make([]bool, 5)}, []bool{false, false, false, false, false}, false},
+		{"journal without full part", 2 * segmentSize, journal(true, true, false, false, false), []bool{false, false, false, false, false}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			partPath := filepath.Join(dir, "game.zip.part")
+			statePath := partPath + ".segments"
+
+			if tt.partSize >= 0 {
+				f, err := os.Create(partPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				// Sparse, the content doesn't matter
+				if err := f.Truncate(tt.partSize); err != nil {
+					t.Fatal(err)
+				}
+				f.Close()
+			}
+			if tt.saved != nil {
+				if err := saveSegmentState(statePath, tt.saved); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			state := loadSegmentState(statePath, partPath, size)
+			if len(state.Done) != len(tt.wantDone) {
+				t.Fatalf("got %d segments, want %d", len(state.Done), len(tt.wantDone))
+			}
+			for i := range tt.wantDone {
+				if state.Done[i] != tt.wantDone[i] {
+					t.Fatalf("done = %v, want %v", state.Done, tt.wantDone)
+				}
+			}
+
+			_, err := os.Stat(partPath)
+			if gotPart := err == nil; gotPart != tt.wantPart {
+				t.Errorf(".part kept = %v, want %v", gotPart, tt.wantPart)
+			}
+		})
+	}
+}
+
+func TestLoadSegmentStateSavesSeededJournal(t *testing.T) {
+	const size = 3 * segmentSize
+	partPath := filepath.Join(t.TempDir(), "game.zip.part")
+	statePath := partPath + ".segments"
+	if err := os.WriteFile(partPath, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(partPath, segmentSize); err != nil {
+		t.Fatal(err)
+	}
+
+	loadSegmentState(statePath, partPath, size)
+
+	// The .part is preallocated next, only the journal keeps the seeded segment
+	if err := os.Truncate(partPath, size); err != nil {
+		t.Fatal(err)
+	}
+	state := loadSegmentState(statePath, partPath, size)
+	if !state.Done[0] || state.Done[1] || state.Done[2] {
+		t.Fatalf("done = %v, want only the first segment", state.Done)
+	}
+	if got := state.completedBytes(); got != segmentSize {
+		t.Errorf("completedBytes = %d, want %d", got, segmentSize)
+	}
+}
@@ -0,0 +1,277 @@
+package download
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// LargeDownloadSize is the size from which downloads honour the schedule window
+const LargeDownloadSize = 50 * 1024 * 1024
+
+// ErrSchedulePaused is returned by a read that waited for the download window
+// to open. The connection is likely dead by then, so the caller reconnects and
+// resumes without counting it as a failed attempt.
+var ErrSchedulePaused = errors.New("download paused by schedule")
+
+// Schedule is a daily window in local time. End before Start wraps past midnight.
+type Schedule struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// ParseSchedule parses "HH:MM" bounds. Empty bounds disable the schedule.
+func ParseSchedule(start, end string) (*Schedule, error) {
+	if start == "" && end == "" {
+		return nil, nil
+	}
+
+	s, err := parseClock(start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window start: %w", err)
+	}
+	e, err := parseClock(end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid window end: %w", err)
+	}
+	if s == e {
+		return nil, fmt.Errorf("download window start and end are equal")
+	}
+
+	return &Schedule{Start: s, End: e}, nil
+}
+
+func parseClock(v string) (time.Duration, error) {
+	t, err := time.Parse("15:04", v)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains reports whether t falls inside the window
+func (s *Schedule) Contains(t time.Time) bool {
+	now := sinceMidnight(t)
+	if s.Start < s.End {
+		return now >= s.Start && now < s.End
+	}
+	return now >= s.Start || now < s.End
+}
+
+// NextStart returns the next time the window opens after t
+func (s *Schedule) NextStart(t time.Time) time.Time {
+	y, m, d := t.Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, t.Location()).Add(s.Start)
+	if !start.After(t) {
+		start = start.AddDate(0, 0, 1)
+	}
+	return start
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+}
+
+// throttle is a token bucket shared by every download, so the limit applies
+// to the launcher as a whole rather than per connection
+type throttle struct {
+	mu       sync.Mutex
+	rate     int64 // bytes per second, 0 means unlimited
+	tokens   float64
+	last     time.Time
+	schedule *Schedule
+	paused   time.Time // when a paused download resumes, zero if none is waiting
+	changed  chan struct{}
+}
+
+var defaultThrottle = &throttle{changed: make(chan struct{})}
+
+// SetRateLimit caps the combined download speed. Takes effect immediately,
+// including for downloads already running. 0 removes the limit.
+func SetRateLimit(bytesPerSec int64) {
+	defaultThrottle.update(func(t *throttle) {
+		if bytesPerSec < 0 {
+			bytesPerSec = 0
+		}
+		t.rate = bytesPerSec
+		t.tokens = 0
+		t.last = time.Now()
+	})
+}
+
+// SetSchedule restricts large downloads to a daily window. nil disables it.
+func SetSchedule(s *Schedule) {
+	defaultThrottle.update(func(t *throttle) {
+		t.schedule = s
+	})
+}
+
+func (t *throttle) update(fn func(*throttle)) {
+	t.mu.Lock()
+	fn(t)
+	// Wake everyone waiting so they pick up the new settings
+	close(t.changed)
+	t.changed = make(chan struct{})
+	t.mu.Unlock()
+}
+
+// burst is the most a single read may take at once
+func (t *throttle) burst() int {
+	if t.rate == 0 {
+		return 0
+	}
+	b := t.rate / 4
+	if b < 4*1024 {
+		b = 4 * 1024
+	}
+	return int(b)
+}
+
+// waitTokens takes n bytes from the bucket and sleeps off any debt
+func (t *throttle) waitTokens(ctx context.Context, n int) error {
+	t.mu.Lock()
+	if t.rate == 0 {
+		t.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	t.tokens += now.Sub(t.last).Seconds() * float64(t.rate)
+	if t.tokens > float64(t.rate) {
+		t.tokens = float64(t.rate)
+	}
+	t.last = now
+	t.tokens -= float64(n)
+
+	debt := -t.tokens
+	rate := t.rate
+	changed := t.changed
+	t.mu.Unlock()
+
+	if debt <= 0 {
+		return nil
+	}
+	return sleepCtx(ctx, time.Duration(debt/float64(rate)*float64(time.Second)), changed)
+}
+
+// waitWindow blocks while the schedule window is closed. Returns true if it had to wait.
+func (t *throttle) waitWindow(ctx context.Context, onPause func(until time.Time)) (bool, error) {
+	paused := false
+	for {
+		t.mu.Lock()
+		s := t.schedule
+		changed := t.changed
+		t.mu.Unlock()
+
+		now := time.Now()
+		if s == nil || s.Contains(now) {
+			if paused {
+				t.mu.Lock()
+				t.paused = time.Time{}
+				t.mu.Unlock()
+			}
+			return paused, nil
+		}
+
+		until := s.NextStart(now)
+		t.mu.Lock()
+		t.paused = until
+		t.mu.Unlock()
+
+		if !paused && onPause != nil {
+			onPause(until)
+		}
+		paused = true
+
+		if err := sleepCtx(ctx, until.Sub(now), changed); err != nil {
+			t.mu.Lock()
+			t.paused = time.Time{}
+			t.mu.Unlock()
+			return paused, err
+		}
+	}
+}
+
+func (t *throttle) pausedUntil() time.Time {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.paused
+}
+
+func pausedMessage(until time.Time) string {
+	return fmt.Sprintf("Download paused until %s", until.Format("15:04"))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration, wake <-chan struct{}) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-wake:
+		return nil
+	case <-timer.C:
+		return nil
+	}
+}
+
+// throttledReader applies the shared rate limit and, for large downloads,
+// the schedule window to a response body
+type throttledReader struct {
+	ctx       context.Context
+	r         io.Reader
+	scheduled bool
+	onPause   func(until time.Time)
+}
+
+func newThrottledReader(ctx context.Context, r io.Reader, scheduled bool, onPause func(until time.Time)) *throttledReader {
+	return &throttledReader{
+		ctx:       ctx,
+		r:         r,
+		scheduled: scheduled,
+		onPause:   onPause,
+	}
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	if tr.scheduled {
+		paused, err := defaultThrottle.waitWindow(tr.ctx, tr.onPause)
+		if err != nil {
+			return 0, err
+		}
+		if paused {
+			return 0, ErrSchedulePaused
+		}
+	}
+
+	defaultThrottle.mu.Lock()
+	burst := defaultThrottle.burst()
+	defaultThrottle.mu.Unlock()
+
+	if burst > 0 && len(p) > burst {
+		p = p[:burst]
+	}
+
+	n, err := tr.r.Read(p)
+	if n > 0 {
+		if werr := defaultThrottle.waitTokens(tr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// waitForWindow holds a large download until the schedule window is open
+func waitForWindow(ctx context.Context, size int64, onPause func(until time.Time)) error {
+	if size < LargeDownloadSize {
+		return nil
+	}
+	_, err := defaultThrottle.waitWindow(ctx, onPause)
+	return err
+}
@@ -0,0 +1,119 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestScheduleContains(t *testing.T) {
+	day := time.Date(2026, 10, 17, 0, 0, 0, 0, time.UTC)
+	at := func(h, m int) time.Time { return day.Add(time.Duration(h)*time.Hour + time.Duration(m)*time.Minute) }
+
+	tests := []struct {
+		name       string
+		start, end string
+		now        time.Time
+		want       bool
+		wantNext   time.Time
+	}{
+		{"inside", "01:00", "06:00", at(3, 0), true, at(25, 0)},
+		{"before", "01:00", "06:00", at(0, 30), false, at(1, 0)},
+		{"end is exclusive", "01:00", "06:00", at(6, 0), false, at(25, 0)},
+		{"wraps, late", "23:00", "06:00", at(23, 30), true, at(47, 0)},
+		{"wraps, early", "23:00", "06:00", at(5, 59), true, at(23, 0)},
+		{"wraps, outside", "23:00", "06:00", at(12, 0), false, at(23, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSchedule(tt.start, tt.end)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := s.Contains(tt.now); got != tt.want {
+				t.Errorf("Contains = %v, want %v", got, tt.want)
+			}
+			if got := s.NextStart(tt.now); !got.Equal(tt.wantNext) {
+				t.Errorf("NextStart = %v, want %v", got, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestSetRateLimitWakesWaiters(t *testing.T) {
+	t.Cleanup(func() { SetRateLimit(0) })
+
+	// 1 KiB/s with a 4 KiB burst: the first read owes four seconds
+	SetRateLimit(1024)
+	tr := newThrottledReader(context.Background(), bytes.NewReader(make([]byte, 64*1024)), false, nil)
+
+	done := make(chan int, 1)
+	go func() {
+		n, _ := tr.Read(make([]byte, 64*1024))
+		done <- n
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	SetRateLimit(0)
+
+	select {
+	case n := <-done:
+		if n != 4*1024 {
+			t.Errorf("read %d bytes, want one burst of %d", n, 4*1024)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("reader still waiting after the limit was lifted")
+	}
+}
+
+func TestScheduleWindowPausesReads(t *testing.T) {
+	t.Cleanup(func() { SetSchedule(nil) })
+
+	// A window opening two hours from now is closed now
+	now := sinceMidnight(time.Now()).Truncate(time.Minute)
+	SetSchedule(&Schedule{Start: (now + 2*time.Hour) % (24 * time.Hour), End: (now + 3*time.Hour) % (24 * time.Hour)})
+
+	paused := make(chan time.Time, 1)
+	tr := newThrottledReader(context.Background(), bytes.NewReader([]byte("data")), true, func(until time.Time) {
+		paused <- until
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := tr.Read(make([]byte, 4))
+		done <- err
+	}()
+
+	select {
+	case until := <-paused:
+		if time.Until(until) < time.Hour {
+			t.Errorf("paused until %v, want the next window start", until)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("read was not paused")
+	}
+	if got := defaultThrottle.pausedUntil(); got.IsZero() {
+		t.Error("pausedUntil not set while waiting")
+	}
+
+	// Dropping the schedule wakes the reader, which asks to reconnect
+	SetSchedule(nil)
+	select {
+	case err := <-done:
+		if !errors.Is(err, ErrSchedulePaused) {
+			t.Fatalf("got %v, want ErrSchedulePaused", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("reader still paused after the schedule was removed")
+	}
+	if got := defaultThrottle.pausedUntil(); !got.IsZero() {
+		t.Errorf("pausedUntil = %v after resuming", got)
+	}
+
+	// The next read goes through
+	if n, err := tr.Read(make([]byte, 4)); err != nil || n != 4 {
+		t.Fatalf("Read after resume = %d, %v", n, err)
+	}
+}
@@ -5,4 +5,7 @@ type InstanceModel struct {
 	InstanceName string
 	Branch       string
 	BuildVersion string
+	JavaRuntime  string
+	JavaPath     string
+	Launch       LaunchOptions
 }
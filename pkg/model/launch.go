@@ -0,0 +1,17 @@
+package model
+
+// LaunchOptions customise how an instance's game process is started
+type LaunchOptions struct {
+	// MinMemoryMB and MaxMemoryMB set the JVM heap (-Xms/-Xmx), 0 leaves the
+	// JVM default
+	MinMemoryMB int `toml:"min_memory_mb,omitempty" json:"minMemoryMB"`
+	MaxMemoryMB int `toml:"max_memory_mb,omitempty" json:"maxMemoryMB"`
+	// JVMArgs are extra flags for the JVM the client starts
+	JVMArgs []string `toml:"jvm_args,omitempty" json:"jvmArgs"`
+	// GameArgs are appended to the client's command line
+	GameArgs []string `toml:"game_args,omitempty" json:"gameArgs"`
+	// Env overrides environment variables of the game process
+	Env map[string]string `toml:"env,omitempty" json:"env"`
+	// Wrapper runs the client through another command, e.g. gamemoderun
+	Wrapper []string `toml:"wrapper,omitempty" json:"wrapper"`
+}